@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gemiwin/api/internal/config"
+	"gemiwin/api/internal/persistence"
+	"gemiwin/api/internal/services"
+)
+
+// runBackup implements the `backup` subcommand.
+func runBackup(args []string) {
+	fs := flag.NewFlagSet("backup", flag.ExitOnError)
+	out := fs.String("out", services.BackupFileName(time.Now()), "Path of the backup archive to create")
+	loader := config.NewLoader(fs)
+	fs.Parse(args)
+
+	cfg := mustLoadConfig(loader)
+
+	f, err := os.Create(*out)
+	if err != nil {
+		log.Fatalf("Failed to create %s: %v", *out, err)
+	}
+
+	manifest, err := newBackupService(cfg, mustOpenVault(cfg.DataDir)).CreateBackup(f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(*out)
+		log.Fatalf("Backup failed: %v", err)
+	}
+
+	fmt.Printf("Backup written to %s (%d entries)\n", *out, len(manifest.Entries))
+}
+
+// runRestore implements the `restore` subcommand.
+func runRestore(args []string) {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	in := fs.String("in", "", "Path of the backup archive to restore (required)")
+	dryRun := fs.Bool("dry-run", false, "Validate the archive and report changes without writing anything")
+	prune := fs.Bool("prune", false, "Delete chats and files that are not part of the backup")
+	loader := config.NewLoader(fs)
+	fs.Parse(args)
+
+	if *in == "" {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	cfg := mustLoadConfig(loader)
+	dataDir := cfg.DataDir
+	// A restore replaces files the running server reads and writes
+	lock := mustLockDataDir(dataDir)
+	defer lock.Unlock()
+
+	data, err := os.ReadFile(*in)
+	if err != nil {
+		log.Fatalf("Failed to read %s: %v", *in, err)
+	}
+
+	report, err := newBackupService(cfg, mustOpenVault(dataDir)).Restore(data, *dryRun, *prune)
+	if err != nil {
+		log.Fatalf("Restore failed: %v", err)
+	}
+
+	out, _ := json.MarshalIndent(report, "", "  ")
+	fmt.Println(string(out))
+}
+
+// newBackupService creates the BackupService for the data directory of cfg.
+func newBackupService(cfg *config.Config, vault *persistence.Vault) *services.BackupService {
+	secrets := persistence.NewSecretStore(filepath.Join(cfg.DataDir, services.SecretsFile), cfg.SecretKeyFile, vault)
+	return services.NewBackupService(cfg.DataDir, vault, secrets)
+}
+
+func mustOpenVault(dataDir string) *persistence.Vault {
+	vault, err := persistence.NewVault(filepath.Join(dataDir, services.EncryptionMetaFile))
+	if err != nil {
+		log.Fatalf("Failed to load encryption settings: %v", err)
+	}
+	return vault
+}
+
+// mustLockDataDir takes the lock of dataDir, exiting if the server or another command holds it.
+func mustLockDataDir(dataDir string) *persistence.DataDirLock {
+	lock, err := persistence.LockDataDir(dataDir)
+	if errors.Is(err, persistence.ErrDataDirInUse) {
+		log.Fatalf("%v; stop the server first", err)
+	}
+	if err != nil {
+		log.Fatalf("Failed to lock the data directory: %v", err)
+	}
+	return lock
+}
+
+func mustLoadConfig(loader *config.Loader) *config.Config {
+	cfg, err := loader.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+	return cfg
+}
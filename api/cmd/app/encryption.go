@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gemiwin/api/internal/config"
+	"gemiwin/api/internal/services"
+	"gemiwin/api/server"
+)
+
+// NewPassphraseEnv holds the replacement passphrase for the `rekey` subcommand.
+const NewPassphraseEnv = "GEMIWIN_NEW_PASSPHRASE"
+
+// runEncrypt implements the `encrypt` subcommand: it enables encryption at rest and encrypts the
+// existing plaintext data. The server must not be running.
+func runEncrypt(args []string) {
+	fs := flag.NewFlagSet("encrypt", flag.ExitOnError)
+	noBackup := fs.Bool("no-backup", false, "Do not write a backup of the data directory before encrypting it")
+	loader := config.NewLoader(fs)
+	fs.Parse(args)
+
+	cfg := mustLoadConfig(loader)
+	dataDir := cfg.DataDir
+	lock := mustLockDataDir(dataDir)
+	defer lock.Unlock()
+	vault := mustOpenVault(dataDir)
+	if vault.Enabled() {
+		log.Fatal("Encryption is already enabled; use `rekey` to change the passphrase")
+	}
+
+	passphrase := readPassphrase(server.PassphraseEnv, "New passphrase: ")
+	if os.Getenv(server.PassphraseEnv) == "" && readPassphrase("", "Repeat passphrase: ") != passphrase {
+		log.Fatal("Passphrases do not match")
+	}
+
+	if !*noBackup {
+		writeSafetyBackup(dataDir, newBackupService(cfg, vault))
+	}
+
+	count, err := services.NewEncryptionService(dataDir, vault).Enable(passphrase)
+	if err != nil {
+		log.Fatalf("Encryption failed: %v", err)
+	}
+	fmt.Printf("Encrypted %d files in %s\n", count, dataDir)
+}
+
+// runRekey implements the `rekey` subcommand: it re-encrypts all data under a new passphrase.
+// The server must not be running.
+func runRekey(args []string) {
+	fs := flag.NewFlagSet("rekey", flag.ExitOnError)
+	noBackup := fs.Bool("no-backup", false, "Do not write a backup of the data directory before re-encrypting it")
+	loader := config.NewLoader(fs)
+	fs.Parse(args)
+
+	cfg := mustLoadConfig(loader)
+	dataDir := cfg.DataDir
+	lock := mustLockDataDir(dataDir)
+	defer lock.Unlock()
+	vault := mustOpenVault(dataDir)
+	if !vault.Enabled() {
+		log.Fatal("Encryption is not enabled; use `encrypt` first")
+	}
+
+	oldPassphrase := readPassphrase(server.PassphraseEnv, "Current passphrase: ")
+	newPassphrase := readPassphrase(NewPassphraseEnv, "New passphrase: ")
+
+	if !*noBackup {
+		writeSafetyBackup(dataDir, newBackupService(cfg, vault))
+	}
+
+	count, err := services.NewEncryptionService(dataDir, vault).Rotate(oldPassphrase, newPassphrase)
+	if err != nil {
+		log.Fatalf("Key rotation failed: %v", err)
+	}
+	fmt.Printf("Re-encrypted %d files in %s\n", count, dataDir)
+}
+
+// writeSafetyBackup stores a backup next to the data directory so an interrupted migration can
+// be undone with `restore`.
+func writeSafetyBackup(dataDir string, backup *services.BackupService) {
+	out := filepath.Join(filepath.Dir(dataDir), services.BackupFileName(time.Now()))
+	f, err := os.Create(out)
+	if err != nil {
+		log.Fatalf("Failed to create %s: %v", out, err)
+	}
+	_, err = backup.CreateBackup(f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(out)
+		log.Fatalf("Backup failed: %v", err)
+	}
+	fmt.Printf("Backup written to %s\n", out)
+}
+
+var stdin = bufio.NewReader(os.Stdin)
+
+// readPassphrase takes the passphrase from env when set, otherwise prompts for it on stdin.
+func readPassphrase(env string, prompt string) string {
+	if env != "" {
+		if v := os.Getenv(env); v != "" {
+			return v
+		}
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	line, err := stdin.ReadString('\n')
+	if err != nil && line == "" {
+		log.Fatalf("Failed to read passphrase: %v", err)
+	}
+	passphrase := strings.TrimRight(line, "\r\n")
+	if passphrase == "" {
+		log.Fatal("Passphrase must not be empty")
+	}
+	return passphrase
+}
@@ -2,21 +2,53 @@ package main
 
 import (
 	"flag"
-	"fmt"
 	"log"
+	"os"
 
+	"gemiwin/api/internal/config"
 	"gemiwin/api/server"
 )
 
 func main() {
-	// Define the `-port` flag (default 8080)
-	port := flag.String("port", "8080", "Port for the HTTP server")
+	// Subcommands run once and exit instead of starting the server
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "backup":
+			runBackup(os.Args[2:])
+			return
+		case "restore":
+			runRestore(os.Args[2:])
+			return
+		case "encrypt":
+			runEncrypt(os.Args[2:])
+			return
+		case "rekey":
+			runRekey(os.Args[2:])
+			return
+		case "mcp":
+			runMCP(os.Args[2:])
+			return
+		}
+	}
+
+	// Flags override environment variables, which override the config file and defaults
+	loader := config.NewLoader(flag.CommandLine)
 	flag.Parse()
 
-	addr := fmt.Sprintf(":%s", *port)
+	cfg, err := loader.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Using data directory %s", cfg.DataDir)
+	// Held until exit, so offline commands such as restore cannot change the data underneath
+	lock := mustLockDataDir(cfg.DataDir)
+	defer lock.Unlock()
 
-	s := server.New()
-	if err := s.Run(addr); err != nil {
+	s, err := server.New(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := s.Run(cfg.Addr()); err != nil {
 		log.Fatalf("Failed to start server: %v", err)
 	}
 }
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"gemiwin/api/internal/config"
+	"gemiwin/api/internal/handlers"
+	"gemiwin/api/internal/mcp"
+	"gemiwin/api/internal/middlewares"
+	"gemiwin/api/internal/persistence"
+	"gemiwin/api/internal/services"
+	"gemiwin/api/server"
+
+	"github.com/gin-gonic/gin"
+)
+
+// runMCP implements the `mcp` subcommand: it offers gemiwin's chats to other agents as an MCP
+// server, over stdio for one user or over streamable HTTP for every user with -listen. It holds
+// the data directory lock like the HTTP server, so only one of them works on the data at a time.
+// While the HTTP server runs, stdio is relayed to its /mcp endpoint instead.
+func runMCP(args []string) {
+	fs := flag.NewFlagSet("mcp", flag.ExitOnError)
+	username := fs.String("user", "", "Account whose chats are served over stdio (default: the local administrator)")
+	listen := fs.String("listen", "", "Serve streamable HTTP at this address, e.g. 127.0.0.1:8090, instead of stdio")
+	loader := config.NewLoader(fs)
+	fs.Parse(args)
+
+	cfg := mustLoadConfig(loader)
+	lock, err := persistence.LockDataDir(cfg.DataDir)
+	if errors.Is(err, persistence.ErrDataDirInUse) {
+		if *listen != "" {
+			log.Fatalf("%v; the running server already answers MCP at /mcp", err)
+		}
+		forwardMCP(cfg, *username)
+		return
+	}
+	if err != nil {
+		log.Fatalf("Failed to lock the data directory: %v", err)
+	}
+	defer lock.Unlock()
+
+	// Without a launch token, HTTP clients sign in with their own API tokens
+	svc, err := server.NewServices(cfg, os.Getenv(server.APITokenEnv))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if svc.Vault.Locked() {
+		log.Fatalf("Data is encrypted; set %s to unlock it", server.PassphraseEnv)
+	}
+
+	if *listen != "" {
+		gin.SetMode(gin.ReleaseMode)
+		r := gin.New()
+		r.Use(gin.Recovery())
+		r.Use(middlewares.AuthMiddleware(svc.Users))
+		r.Any("/mcp", handlers.ServeMCP(svc.Chats))
+		log.Printf("Serving MCP at http://%s/mcp", *listen)
+		if err := r.Run(*listen); err != nil {
+			log.Fatalf("Failed to start MCP server: %v", err)
+		}
+		return
+	}
+
+	userID := services.LocalUser().ID
+	if *username != "" {
+		userID = mustFindUser(svc.Users, *username)
+	}
+	// stdout carries the protocol; logs go to stderr
+	if err := services.NewChatMCPServer(svc.Chats, userID).ServeStdio(context.Background(), os.Stdin, os.Stdout); err != nil {
+		log.Fatalf("MCP server failed: %v", err)
+	}
+}
+
+// forwardMCP relays stdio to the /mcp endpoint of the HTTP server running on cfg's data directory.
+// It signs in with the token in GEMIWIN_API_TOKEN, which is required for another user than the
+// local administrator, or else the server's launch token.
+func forwardMCP(cfg *config.Config, username string) {
+	token := os.Getenv(server.APITokenEnv)
+	if token == "" {
+		if username != "" {
+			log.Fatalf("The server is running; set %s to an API token of %s to reach its chats", server.APITokenEnv, username)
+		}
+		data, err := os.ReadFile(cfg.TokenFile)
+		if err != nil {
+			log.Fatalf("The server is running but its API token cannot be read: %v", err)
+		}
+		token = strings.TrimSpace(string(data))
+	}
+
+	host := cfg.BindAddress
+	if ip := net.ParseIP(host); host == "" || ip != nil && ip.IsUnspecified() {
+		host = "127.0.0.1"
+	}
+	endpoint := "http://" + net.JoinHostPort(host, cfg.Port) + "/mcp"
+	log.Printf("The server is running; relaying to %s", endpoint)
+	// stdout carries the protocol; logs go to stderr
+	headers := map[string]string{"Authorization": "Bearer " + token}
+	if err := mcp.ForwardStdio(context.Background(), os.Stdin, os.Stdout, endpoint, headers, &http.Client{}); err != nil {
+		log.Fatalf("MCP relay failed: %v", err)
+	}
+}
+
+// mustFindUser returns the id of the account called username.
+func mustFindUser(users *services.UserService, username string) string {
+	all, err := users.ListUsers()
+	if err != nil {
+		log.Fatalf("Failed to read users: %v", err)
+	}
+	for _, user := range all {
+		if user.Username == username {
+			return user.ID
+		}
+	}
+	log.Fatalf("No user called %q", username)
+	return ""
+}
@@ -0,0 +1,487 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gemiwin/api/internal/domain"
+)
+
+// Sources a configuration value can come from, in increasing order of precedence.
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceEnv     = "env"
+	SourceFlag    = "flag"
+)
+
+// Log levels accepted by LogLevel.
+const (
+	LogLevelDebug = "debug"
+	LogLevelInfo  = "info"
+	LogLevelWarn  = "warn"
+	LogLevelError = "error"
+)
+
+// Model backends accepted by Backend.
+const (
+	BackendCLI = "cli"
+	BackendAPI = "api"
+)
+
+// ConfigFileEnv is the environment variable pointing at the configuration file.
+const ConfigFileEnv = "GEMIWIN_CONFIG"
+
+// Config is the process-level configuration resolved at startup. Unlike domain.AppConfig it is
+// read-only while the server runs.
+type Config struct {
+	Port           string   `json:"port"`
+	BindAddress    string   `json:"bind_address"`
+	DataDir        string   `json:"data_dir"`
+	LogLevel       string   `json:"log_level"`
+	DefaultModel   string   `json:"default_model"`
+	CORSOrigins    []string `json:"cors_origins"`
+	MaxUploadBytes int64    `json:"max_upload_bytes"`
+	// TokenFile receives the API bearer token generated at startup.
+	TokenFile string `json:"token_file"`
+	// SecretKeyFile holds the key that encrypts API keys, outside the data directory by default.
+	SecretKeyFile string `json:"secret_key_file"`
+	// Backend selects how the model is called: the Gemini CLI (text only) or the Gemini REST API,
+	// which also accepts images, audio and PDFs.
+	Backend      string `json:"backend"`
+	GeminiAPIURL string `json:"gemini_api_url"`
+	// URLAllowHosts limits the hosts POST /chats/:id/urls may fetch from; empty allows any public
+	// host. Hosts match themselves and their subdomains. URLDenyHosts always wins.
+	URLAllowHosts   []string `json:"url_allow_hosts"`
+	URLDenyHosts    []string `json:"url_deny_hosts"`
+	URLFetchTimeout int      `json:"url_fetch_timeout_seconds"`
+	// WorkspaceRoots are the directories workspaces may be created in, including subdirectories.
+	WorkspaceRoots []string `json:"workspace_roots"`
+	// WorkspaceReindex is how often, in seconds, workspaces are checked for changes; 0 disables it.
+	WorkspaceReindex int `json:"workspace_reindex_seconds"`
+
+	// ConfigFile is the file that was loaded, if any.
+	ConfigFile string `json:"config_file,omitempty"`
+	// Sources records where each setting came from, keyed by its JSON name.
+	Sources map[string]string `json:"sources"`
+}
+
+// Addr returns the address the HTTP server listens on.
+func (c *Config) Addr() string {
+	return net.JoinHostPort(c.BindAddress, c.Port)
+}
+
+// Default returns the built-in configuration.
+func Default() *Config {
+	return &Config{
+		Port:             "8080",
+		BindAddress:      "127.0.0.1",
+		LogLevel:         LogLevelInfo,
+		DefaultModel:     domain.DefaultModel,
+		CORSOrigins:      []string{"http://localhost:3000", "http://127.0.0.1:3000", "file://"},
+		MaxUploadBytes:   10 << 20,
+		Backend:          BackendCLI,
+		GeminiAPIURL:     "https://generativelanguage.googleapis.com/v1beta",
+		URLAllowHosts:    []string{},
+		URLDenyHosts:     []string{},
+		URLFetchTimeout:  20,
+		WorkspaceRoots:   []string{},
+		WorkspaceReindex: 60,
+		Sources: map[string]string{
+			"port":                      SourceDefault,
+			"bind_address":              SourceDefault,
+			"data_dir":                  SourceDefault,
+			"log_level":                 SourceDefault,
+			"default_model":             SourceDefault,
+			"cors_origins":              SourceDefault,
+			"max_upload_bytes":          SourceDefault,
+			"token_file":                SourceDefault,
+			"secret_key_file":           SourceDefault,
+			"backend":                   SourceDefault,
+			"gemini_api_url":            SourceDefault,
+			"url_allow_hosts":           SourceDefault,
+			"url_deny_hosts":            SourceDefault,
+			"url_fetch_timeout_seconds": SourceDefault,
+			"workspace_roots":           SourceDefault,
+			"workspace_reindex_seconds": SourceDefault,
+		},
+	}
+}
+
+// Loader registers the configuration flags on a FlagSet and merges them with the other layers.
+type Loader struct {
+	fs         *flag.FlagSet
+	configFile *string
+	values     map[string]*string
+}
+
+// setting describes one configuration value shared by the file, env and flag layers.
+type setting struct {
+	name  string // JSON key in the config file
+	flag  string
+	env   string
+	usage string
+}
+
+var settings = []setting{
+	{"port", "port", "GEMIWIN_PORT", "Port for the HTTP server"},
+	{"bind_address", "bind", "GEMIWIN_BIND", "Address to bind the HTTP server to"},
+	{"data_dir", "data-dir", DataDirEnv, "Directory holding chats, files and configuration"},
+	{"log_level", "log-level", "GEMIWIN_LOG_LEVEL", "Log level: debug, info, warn or error"},
+	{"default_model", "default-model", "GEMIWIN_DEFAULT_MODEL", "Model used for new chats"},
+	{"cors_origins", "cors-origins", "GEMIWIN_CORS_ORIGINS", "Comma-separated list of allowed CORS origins"},
+	{"max_upload_bytes", "max-upload-bytes", "GEMIWIN_MAX_UPLOAD_BYTES", "Maximum size of an uploaded file in bytes"},
+	{"token_file", "token-file", "GEMIWIN_TOKEN_FILE", "File the API token is written to (default <data-dir>/api_token)"},
+	{"secret_key_file", "secret-key-file", "GEMIWIN_SECRET_KEY_FILE", "File holding the key that encrypts API keys (default <user-config-dir>/gemiwin-keys/secret.key)"},
+	{"backend", "backend", "GEMIWIN_BACKEND", "Model backend: cli (Gemini CLI, text only) or api (Gemini REST API, multimodal)"},
+	{"gemini_api_url", "gemini-api-url", "GEMIWIN_GEMINI_API_URL", "Base URL of the Gemini REST API"},
+	{"url_allow_hosts", "url-allow-hosts", "GEMIWIN_URL_ALLOW_HOSTS", "Comma-separated hosts URLs may be fetched from (default any public host)"},
+	{"url_deny_hosts", "url-deny-hosts", "GEMIWIN_URL_DENY_HOSTS", "Comma-separated hosts URLs are never fetched from"},
+	{"url_fetch_timeout_seconds", "url-fetch-timeout", "GEMIWIN_URL_FETCH_TIMEOUT", "Seconds allowed for fetching a URL"},
+	{"workspace_roots", "workspace-roots", "GEMIWIN_WORKSPACE_ROOTS", "Comma-separated directories workspaces may be created in (default none, which disables workspaces)"},
+	{"workspace_reindex_seconds", "workspace-reindex", "GEMIWIN_WORKSPACE_REINDEX", "Seconds between checks of workspaces for changes; 0 disables"},
+}
+
+// NewLoader registers -config and one flag per setting on fs. Call Load after fs.Parse.
+func NewLoader(fs *flag.FlagSet) *Loader {
+	l := &Loader{fs: fs, values: make(map[string]*string)}
+	l.configFile = fs.String("config", "", "Path to a JSON configuration file (env "+ConfigFileEnv+")")
+	defaults := Default()
+	for _, s := range settings {
+		usage := fmt.Sprintf("%s (env %s)", s.usage, s.env)
+		if def := defaults.get(s.name); def != "" {
+			usage = fmt.Sprintf("%s (env %s, default %s)", s.usage, s.env, def)
+		}
+		l.values[s.name] = fs.String(s.flag, "", usage)
+	}
+	return l
+}
+
+// Load merges, in increasing precedence, the defaults, the configuration file, GEMIWIN_*
+// environment variables and the flags that were explicitly set. The result is validated and
+// the data directory resolved to an absolute path.
+func (l *Loader) Load() (*Config, error) {
+	cfg := Default()
+
+	if err := l.applyFile(cfg); err != nil {
+		return nil, err
+	}
+
+	var problems []string
+	for _, s := range settings {
+		if v, ok := os.LookupEnv(s.env); ok && v != "" {
+			if err := cfg.set(s.name, v, SourceEnv); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %v", s.env, err))
+			}
+		}
+	}
+
+	setFlags := make(map[string]bool)
+	l.fs.Visit(func(f *flag.Flag) { setFlags[f.Name] = true })
+	for _, s := range settings {
+		if setFlags[s.flag] {
+			if err := cfg.set(s.name, *l.values[s.name], SourceFlag); err != nil {
+				problems = append(problems, fmt.Sprintf("-%s: %v", s.flag, err))
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return nil, invalid(problems)
+	}
+
+	dataDir, err := ResolveDataDir(cfg.DataDir)
+	if err != nil {
+		return nil, err
+	}
+	cfg.DataDir = dataDir
+	if cfg.TokenFile == "" {
+		cfg.TokenFile = filepath.Join(dataDir, "api_token")
+	} else if cfg.TokenFile, err = filepath.Abs(cfg.TokenFile); err != nil {
+		return nil, err
+	}
+	if cfg.SecretKeyFile == "" {
+		cfg.SecretKeyFile = DefaultSecretKeyFile(dataDir)
+	} else if cfg.SecretKeyFile, err = filepath.Abs(cfg.SecretKeyFile); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// applyFile loads the configuration file named by -config or GEMIWIN_CONFIG, or the default file
+// in the user configuration directory when it exists.
+func (l *Loader) applyFile(cfg *Config) error {
+	path := *l.configFile
+	if path == "" {
+		path = os.Getenv(ConfigFileEnv)
+	}
+	required := path != ""
+	if path == "" {
+		base, err := os.UserConfigDir()
+		if err != nil {
+			return nil
+		}
+		path = filepath.Join(base, "gemiwin", "config.json")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) && !required {
+			return nil
+		}
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+
+	var problems []string
+	for key, value := range raw {
+		if !isSetting(key) {
+			problems = append(problems, fmt.Sprintf("%s: unknown setting %q", path, key))
+			continue
+		}
+		text, err := fileValue(value)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s: %v", path, key, err))
+			continue
+		}
+		if err := cfg.set(key, text, SourceFile); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s: %v", path, key, err))
+		}
+	}
+	if len(problems) > 0 {
+		return invalid(problems)
+	}
+
+	abs, err := filepath.Abs(path)
+	if err == nil {
+		path = abs
+	}
+	cfg.ConfigFile = path
+	return nil
+}
+
+// fileValue converts a JSON value from the config file into the string form used by env vars and flags.
+func fileValue(raw json.RawMessage) (string, error) {
+	raw = bytes.TrimSpace(raw)
+	switch {
+	case len(raw) > 0 && raw[0] == '"':
+		var s string
+		err := json.Unmarshal(raw, &s)
+		return s, err
+	case len(raw) > 0 && raw[0] == '[':
+		var list []string
+		if err := json.Unmarshal(raw, &list); err != nil {
+			return "", errors.New("expected a list of strings")
+		}
+		return strings.Join(list, ","), nil
+	default:
+		var n json.Number
+		if err := json.Unmarshal(raw, &n); err != nil {
+			return "", errors.New("expected a string, number or list")
+		}
+		return n.String(), nil
+	}
+}
+
+func isSetting(name string) bool {
+	for _, s := range settings {
+		if s.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// set parses value into the named field and records its source.
+func (c *Config) set(name string, value string, source string) error {
+	value = strings.TrimSpace(value)
+	switch name {
+	case "port":
+		c.Port = value
+	case "bind_address":
+		c.BindAddress = value
+	case "data_dir":
+		c.DataDir = value
+	case "log_level":
+		c.LogLevel = strings.ToLower(value)
+	case "default_model":
+		c.DefaultModel = value
+	case "cors_origins":
+		c.CORSOrigins = splitList(value)
+	case "max_upload_bytes":
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a whole number of bytes", value)
+		}
+		c.MaxUploadBytes = n
+	case "token_file":
+		c.TokenFile = value
+	case "secret_key_file":
+		c.SecretKeyFile = value
+	case "backend":
+		c.Backend = strings.ToLower(value)
+	case "gemini_api_url":
+		c.GeminiAPIURL = strings.TrimRight(value, "/")
+	case "url_allow_hosts":
+		c.URLAllowHosts = splitList(strings.ToLower(value))
+	case "url_deny_hosts":
+		c.URLDenyHosts = splitList(strings.ToLower(value))
+	case "url_fetch_timeout_seconds":
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a whole number of seconds", value)
+		}
+		c.URLFetchTimeout = n
+	case "workspace_roots":
+		c.WorkspaceRoots = splitList(value)
+	case "workspace_reindex_seconds":
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a whole number of seconds", value)
+		}
+		c.WorkspaceReindex = n
+	default:
+		return fmt.Errorf("unknown setting %q", name)
+	}
+	c.Sources[name] = source
+	return nil
+}
+
+// get returns the named setting in the string form accepted by set.
+func (c *Config) get(name string) string {
+	switch name {
+	case "port":
+		return c.Port
+	case "bind_address":
+		return c.BindAddress
+	case "data_dir":
+		return c.DataDir
+	case "log_level":
+		return c.LogLevel
+	case "default_model":
+		return c.DefaultModel
+	case "cors_origins":
+		return strings.Join(c.CORSOrigins, ",")
+	case "max_upload_bytes":
+		return strconv.FormatInt(c.MaxUploadBytes, 10)
+	case "token_file":
+		return c.TokenFile
+	case "secret_key_file":
+		return c.SecretKeyFile
+	case "backend":
+		return c.Backend
+	case "gemini_api_url":
+		return c.GeminiAPIURL
+	case "url_allow_hosts":
+		return strings.Join(c.URLAllowHosts, ",")
+	case "url_deny_hosts":
+		return strings.Join(c.URLDenyHosts, ",")
+	case "url_fetch_timeout_seconds":
+		return strconv.Itoa(c.URLFetchTimeout)
+	case "workspace_roots":
+		return strings.Join(c.WorkspaceRoots, ",")
+	case "workspace_reindex_seconds":
+		return strconv.Itoa(c.WorkspaceReindex)
+	}
+	return ""
+}
+
+// Validate checks every setting and reports all problems at once.
+func (c *Config) Validate() error {
+	var problems []string
+
+	if port, err := strconv.Atoi(c.Port); err != nil || port < 1 || port > 65535 {
+		problems = append(problems, fmt.Sprintf("port: %q must be a number between 1 and 65535", c.Port))
+	}
+	if c.BindAddress == "" {
+		problems = append(problems, "bind_address: must not be empty")
+	} else if net.ParseIP(c.BindAddress) == nil && c.BindAddress != "localhost" {
+		problems = append(problems, fmt.Sprintf("bind_address: %q is not an IP address", c.BindAddress))
+	}
+	switch c.LogLevel {
+	case LogLevelDebug, LogLevelInfo, LogLevelWarn, LogLevelError:
+	default:
+		problems = append(problems, fmt.Sprintf("log_level: %q must be one of debug, info, warn, error", c.LogLevel))
+	}
+	// Whether the backend offers the model is only known once it is asked, when chats start
+	if !domain.ValidModelID(c.DefaultModel) {
+		problems = append(problems, fmt.Sprintf("default_model: invalid model id %q", c.DefaultModel))
+	}
+	if len(c.CORSOrigins) == 0 {
+		problems = append(problems, "cors_origins: at least one origin is required")
+	}
+	for _, origin := range c.CORSOrigins {
+		if origin == "*" || origin == "file://" {
+			continue
+		}
+		u, err := url.Parse(origin)
+		if err != nil || u.Scheme == "" || u.Host == "" || u.Path != "" {
+			problems = append(problems, fmt.Sprintf("cors_origins: %q must look like scheme://host[:port]", origin))
+		}
+	}
+	if c.MaxUploadBytes <= 0 {
+		problems = append(problems, "max_upload_bytes: must be greater than zero")
+	}
+	if c.DataDir == "" {
+		problems = append(problems, "data_dir: must not be empty")
+	}
+	switch c.Backend {
+	case BackendCLI, BackendAPI:
+	default:
+		problems = append(problems, fmt.Sprintf("backend: %q must be one of cli, api", c.Backend))
+	}
+	if u, err := url.Parse(c.GeminiAPIURL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		problems = append(problems, fmt.Sprintf("gemini_api_url: %q must be an http(s) URL", c.GeminiAPIURL))
+	}
+	for _, host := range append(append([]string{}, c.URLAllowHosts...), c.URLDenyHosts...) {
+		if net.ParseIP(host) == nil && strings.ContainsAny(host, "/:@[] ") {
+			problems = append(problems, fmt.Sprintf("url hosts: %q must be a host name or IP address without scheme or port", host))
+		}
+	}
+	if c.URLFetchTimeout <= 0 {
+		problems = append(problems, "url_fetch_timeout_seconds: must be greater than zero")
+	}
+	for _, root := range c.WorkspaceRoots {
+		if !filepath.IsAbs(root) {
+			problems = append(problems, fmt.Sprintf("workspace_roots: %q must be an absolute path", root))
+		}
+	}
+	if c.WorkspaceReindex < 0 {
+		problems = append(problems, "workspace_reindex_seconds: must not be negative")
+	}
+
+	if len(problems) > 0 {
+		return invalid(problems)
+	}
+	return nil
+}
+
+func invalid(problems []string) error {
+	return errors.New("invalid configuration:\n  - " + strings.Join(problems, "\n  - "))
+}
+
+func splitList(value string) []string {
+	var out []string
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
@@ -0,0 +1,124 @@
+package config
+
+import (
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// DataDirEnv is the environment variable that overrides the data directory.
+const DataDirEnv = "GEMIWIN_DATA_DIR"
+
+// legacyDataDir is the directory older versions kept their data in, relative to the working
+// directory, which was usually the one holding the executable.
+const legacyDataDir = "data"
+
+// ResolveDataDir returns the absolute data directory to use. The explicit value (usually the
+// -data-dir flag) wins, then the GEMIWIN_DATA_DIR environment variable, and finally the platform
+// default. A data directory left next to the executable by an older install is moved to the
+// platform default the first time it is used.
+func ResolveDataDir(explicit string) (string, error) {
+	dir := explicit
+	if dir == "" {
+		dir = os.Getenv(DataDirEnv)
+	}
+	if dir == "" {
+		var err error
+		if dir, err = DefaultDataDir(); err != nil {
+			return "", err
+		}
+		dir = migrateLegacyDataDir(dir)
+	}
+	return filepath.Abs(dir)
+}
+
+// migrateLegacyDataDir moves the data directory of an older install to target unless target
+// already exists, and returns the directory to use: target, or the old one if it could not be
+// moved. A data directory in the working directory is no longer picked up, so it is only reported.
+func migrateLegacyDataDir(target string) string {
+	if cwd, err := filepath.Abs(legacyDataDir); err == nil && isDir(cwd) && !sameDir(cwd, legacyExecutableDataDir()) {
+		log.Printf("Ignoring %s: data directories are no longer looked up in the working directory; pass -data-dir %s to use it", cwd, cwd)
+	}
+
+	legacy := legacyExecutableDataDir()
+	if legacy == "" || !isDir(legacy) || sameDir(legacy, target) {
+		return target
+	}
+	if _, err := os.Stat(target); err == nil {
+		log.Printf("Ignoring %s left by an older version, since %s exists; pass -data-dir %s to use it", legacy, target, legacy)
+		return target
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err == nil {
+		err = os.Rename(legacy, target)
+		if err == nil {
+			log.Printf("Moved the data directory from %s to %s", legacy, target)
+			return target
+		}
+	}
+	// Renaming fails across file systems; keep using the old directory where it is
+	log.Printf("Could not move %s to %s; using it in place. Move it yourself or pass -data-dir to silence this", legacy, target)
+	return legacy
+}
+
+// legacyExecutableDataDir returns the data directory next to the executable, or "" if the
+// executable cannot be found.
+func legacyExecutableDataDir() string {
+	exe, err := os.Executable()
+	if err != nil {
+		return ""
+	}
+	if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+		exe = resolved
+	}
+	return filepath.Join(filepath.Dir(exe), legacyDataDir)
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// sameDir reports whether a and b name the same existing directory.
+func sameDir(a string, b string) bool {
+	ai, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	bi, err := os.Stat(b)
+	return err == nil && os.SameFile(ai, bi)
+}
+
+// DefaultDataDir returns the platform-specific data directory: $XDG_DATA_HOME/gemiwin (falling back
+// to ~/.local/share/gemiwin) on Linux and the user configuration directory elsewhere, e.g.
+// ~/Library/Application Support/gemiwin on macOS or %AppData%\gemiwin on Windows.
+func DefaultDataDir() (string, error) {
+	if runtime.GOOS == "linux" {
+		if xdg := os.Getenv("XDG_DATA_HOME"); xdg != "" && filepath.IsAbs(xdg) {
+			return filepath.Join(xdg, "gemiwin"), nil
+		}
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", errors.New("cannot determine data directory: set -data-dir or " + DataDirEnv)
+		}
+		return filepath.Join(home, ".local", "share", "gemiwin"), nil
+	}
+
+	base, err := os.UserConfigDir()
+	if err != nil {
+		return "", errors.New("cannot determine data directory: set -data-dir or " + DataDirEnv)
+	}
+	return filepath.Join(base, "gemiwin"), nil
+}
+
+// DefaultSecretKeyFile returns the file the key encrypting API keys is kept in:
+// <user-config-dir>/gemiwin-keys/secret.key, which is outside the default data directory on every
+// platform. It falls back to a file in dataDir when there is no user configuration directory.
+func DefaultSecretKeyFile(dataDir string) string {
+	base, err := os.UserConfigDir()
+	if err != nil {
+		return filepath.Join(dataDir, "secret.key")
+	}
+	return filepath.Join(base, "gemiwin-keys", "secret.key")
+}
@@ -3,5 +3,36 @@ package domain
 // AppConfig represents global configuration for the application.
 // Additional fields can be added over time as new configuration values are required.
 type AppConfig struct {
-	GeminiApiKey string `json:"gemini_api_key"`
+	// GeminiApiKey is accepted on write for backwards compatibility but is kept in the secret
+	// store; it is always empty in the persisted file and in responses.
+	GeminiApiKey string `json:"gemini_api_key,omitempty"`
+	// MCPServers are the Model Context Protocol servers whose tools chats can enable.
+	MCPServers []MCPServer `json:"mcp_servers,omitempty"`
+	// Models add models to the registry or override the properties reported for known ones.
+	Models []Model `json:"models,omitempty"`
+	// Budgets limit how much the user's answers may use per day or month.
+	Budgets []Budget `json:"budgets,omitempty"`
+	// BudgetWebhookURL receives a POST when usage crosses a budget's alert thresholds.
+	BudgetWebhookURL string `json:"budget_webhook_url,omitempty"`
+}
+
+// MCPServer is a Model Context Protocol server, started as a local command (stdio) or reached
+// at a streamable HTTP endpoint. Exactly one of Command and URL is set.
+type MCPServer struct {
+	// Name identifies the server in chat configs and prefixes its tool names.
+	Name    string            `json:"name"`
+	Command string            `json:"command,omitempty"`
+	Args    []string          `json:"args,omitempty"`
+	Env     map[string]string `json:"env,omitempty"`
+	URL     string            `json:"url,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// PublicAppConfig is the client-facing view of AppConfig. Secrets are never returned, only whether
+// they are set and a masked hint to recognise them.
+type PublicAppConfig struct {
+	AppConfig
+	HasKey             bool   `json:"has_key"`
+	GeminiApiKeyMasked string `json:"gemini_api_key_masked,omitempty"`
+	KeyStorage         string `json:"key_storage"`
 }
@@ -0,0 +1,23 @@
+package domain
+
+// Budget periods, in the server's time zone.
+const (
+	BudgetDaily   = "daily"
+	BudgetMonthly = "monthly"
+)
+
+// Budget limits the tokens or estimated cost of a user's answers over a day or a month.
+type Budget struct {
+	Period string `json:"period"`
+	// MaxTokens limits input plus output tokens and MaxCostUSD the estimated cost; set at least one.
+	MaxTokens  int     `json:"max_tokens,omitempty"`
+	MaxCostUSD float64 `json:"max_cost_usd,omitempty"`
+	// ChatID limits the budget to one chat; without it the budget covers all chats together.
+	ChatID string `json:"chat_id,omitempty"`
+	// DowngradeTo is a cheaper model that answers once the budget is used up. Without it,
+	// messages are refused until the period ends.
+	DowngradeTo string `json:"downgrade_to,omitempty"`
+	// AlertAt are fractions of the budget, e.g. 0.8, whose crossing is reported to the budget
+	// webhook. Using up the budget is always reported.
+	AlertAt []float64 `json:"alert_at,omitempty"`
+}
@@ -2,7 +2,7 @@ package domain
 
 import "time"
 
-// Allowed models for chat generation.
+// Models known without asking the backend; see the model registry for the full list.
 const (
 	ModelGemini25Pro   = "gemini-2.5-pro"
 	ModelGemini25Flash = "gemini-2.5-flash"
@@ -12,12 +12,36 @@ const (
 // ChatConfig holds per-chat configuration options.
 type ChatConfig struct {
 	Model string `json:"model"`
+	// WorkspaceIDs select workspaces whose relevant files are added to every question.
+	WorkspaceIDs []string `json:"workspace_ids,omitempty"`
+	// MCPServers enables the tools of these servers from the owner's AppConfig, by name.
+	MCPServers []string `json:"mcp_servers,omitempty"`
+	// Instructions are added to the model's instructions for every answer, e.g. the system
+	// messages of a conversation sent through the OpenAI-compatible API.
+	Instructions string `json:"instructions,omitempty"`
+}
+
+// ChatSource identifies where an imported chat originally came from.
+type ChatSource struct {
+	Provider string `json:"provider"`
+	ID       string `json:"id"`
 }
 
 type Chat struct {
-	ID        string     `json:"id"`
-	Name      string     `json:"name"`
-	CreatedAt time.Time  `json:"created_at"`
-	Config    ChatConfig `json:"config"`
-	Messages  []Message  `json:"messages"`
+	ID        string      `json:"id"`
+	OwnerID   string      `json:"owner_id,omitempty"`
+	Name      string      `json:"name"`
+	CreatedAt time.Time   `json:"created_at"`
+	Config    ChatConfig  `json:"config"`
+	Messages  []Message   `json:"messages"`
+	Source    *ChatSource `json:"source,omitempty"`
+}
+
+// Owner returns the id of the user the chat belongs to. Chats created before multi-user support
+// belong to the local user.
+func (c *Chat) Owner() string {
+	if c.OwnerID == "" {
+		return LocalUserID
+	}
+	return c.OwnerID
 }
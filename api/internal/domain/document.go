@@ -1,8 +1,21 @@
 package domain
 
 type Document struct {
-	ID      string `json:"id"`
-	Name    string `json:"name"`
-	URL     string `json:"url"`
-	Content string `json:"content,omitempty"`
+	ID         string      `json:"id"`
+	Name       string      `json:"name"`
+	URL        string      `json:"url"`
+	MIMEType   string      `json:"mime_type,omitempty"`
+	Content    string      `json:"content,omitempty"`
+	Extraction *Extraction `json:"extraction,omitempty"`
+	// LibraryID is the library entry the document was attached from.
+	LibraryID string `json:"library_id,omitempty"`
+	// SourceURL is the address the document was fetched from, for documents added by URL.
+	SourceURL string `json:"source_url,omitempty"`
+}
+
+// Extraction reports how the text of a Document was obtained. Status is one of "ok", "partial",
+// "empty", "failed" or "unsupported"; Warnings explain anything that was skipped.
+type Extraction struct {
+	Status   string   `json:"status"`
+	Warnings []string `json:"warnings,omitempty"`
 }
@@ -0,0 +1,34 @@
+package domain
+
+import "time"
+
+// LibraryDocument is a file a user uploaded once, together with its extracted text, so it can be
+// attached to messages in any of their chats without being stored or extracted again.
+type LibraryDocument struct {
+	ID         string      `json:"id"`
+	OwnerID    string      `json:"owner_id"`
+	Name       string      `json:"name"`
+	FileID     string      `json:"file_id"`
+	URL        string      `json:"url"`
+	MIMEType   string      `json:"mime_type,omitempty"`
+	Size       int64       `json:"size"`
+	Content    string      `json:"content,omitempty"`
+	Extraction *Extraction `json:"extraction,omitempty"`
+	SourceURL  string      `json:"source_url,omitempty"`
+	CreatedAt  time.Time   `json:"created_at"`
+}
+
+// Attachment returns the document to attach to a message. It carries a copy of the extracted
+// text, so the message is unaffected if the library entry is deleted later.
+func (d *LibraryDocument) Attachment() Document {
+	return Document{
+		ID:         d.FileID,
+		Name:       d.Name,
+		URL:        d.URL,
+		MIMEType:   d.MIMEType,
+		Content:    d.Content,
+		Extraction: d.Extraction,
+		LibraryID:  d.ID,
+		SourceURL:  d.SourceURL,
+	}
+}
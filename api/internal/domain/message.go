@@ -1,6 +1,9 @@
 package domain
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 type Role string
 
@@ -9,10 +12,78 @@ const (
 	BotRole  Role = "bot"
 )
 
+// Message is one entry of a chat. Type is "text", "doc" for messages with documents, or "tool"
+// for a tool the bot ran before answering, described by ToolCall.
 type Message struct {
-	Role      Role      `json:"role"`
-	Type      string    `json:"type"`
-	Content   string    `json:"content"`
-	Document  *Document `json:"document,omitempty"`
+	Role      Role       `json:"role"`
+	Type      string     `json:"type"`
+	Content   string     `json:"content"`
+	Documents []Document `json:"documents,omitempty"`
+	ToolCall  *ToolCall  `json:"tool_call,omitempty"`
+	// Usage is set on bot answers generated by gemiwin; imported and tool messages have none.
+	Usage *Usage `json:"usage,omitempty"`
+	// Alternates are the answers of a model comparison. Content and Usage repeat the selected one.
+	Alternates []Alternate `json:"alternates,omitempty"`
+	Timestamp  time.Time   `json:"timestamp"`
+}
+
+// Alternate is one model's answer in a comparison. Tools the model ran are kept with the answer
+// instead of as messages of their own, so selecting another answer does not change the history.
+type Alternate struct {
+	Model     string     `json:"model"`
+	Content   string     `json:"content,omitempty"`
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	Usage     *Usage     `json:"usage,omitempty"`
+	// Error is why the model gave no answer.
+	Error    string `json:"error,omitempty"`
+	Selected bool   `json:"selected,omitempty"`
+}
+
+// Usage records what generating a bot answer took, including the tool rounds before it.
+type Usage struct {
+	Model string `json:"model"`
+	// DowngradedFrom is the chat's model when a used-up budget had the answer come from Model instead.
+	DowngradedFrom string `json:"downgraded_from,omitempty"`
+	InputTokens    int    `json:"input_tokens"`
+	OutputTokens   int    `json:"output_tokens"`
+	// Estimated is set when the backend reported no token counts and they were derived from the
+	// length of the text.
+	Estimated bool  `json:"estimated,omitempty"`
+	LatencyMs int64 `json:"latency_ms"`
+	// CostUSD is the answer's cost at the model's price when it was generated; nil if the model
+	// had no price.
+	CostUSD *float64 `json:"cost_usd,omitempty"`
+}
+
+// UsageRecord is one answer in a user's usage ledger, which outlives the chat it was given in.
+type UsageRecord struct {
+	// ChatID is empty for completions that were not saved as a chat.
+	ChatID string `json:"chat_id,omitempty"`
+	Usage
 	Timestamp time.Time `json:"timestamp"`
 }
+
+// ToolCall is a tool the model asked to run, with the arguments it passed and the outcome.
+type ToolCall struct {
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+	Result    string          `json:"result,omitempty"`
+	Error     string          `json:"error,omitempty"`
+}
+
+// UnmarshalJSON also accepts the single "document" field written by earlier versions.
+func (m *Message) UnmarshalJSON(data []byte) error {
+	type message Message
+	var raw struct {
+		message
+		Document *Document `json:"document"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*m = Message(raw.message)
+	if raw.Document != nil && len(m.Documents) == 0 {
+		m.Documents = []Document{*raw.Document}
+	}
+	return nil
+}
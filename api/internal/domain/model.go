@@ -0,0 +1,36 @@
+package domain
+
+import "regexp"
+
+// modelIDPattern is the format of model ids, which end up in API paths and CLI environment variables.
+var modelIDPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+// ValidModelID reports whether id is well-formed enough to be sent to a backend.
+func ValidModelID(id string) bool {
+	return modelIDPattern.MatchString(id)
+}
+
+// Model describes a model chats can use. Zero values mean the property is unknown.
+type Model struct {
+	ID          string `json:"id"`
+	DisplayName string `json:"display_name,omitempty"`
+	Description string `json:"description,omitempty"`
+	// ContextWindow is the most input tokens the model accepts, OutputTokens the most it writes.
+	ContextWindow int `json:"context_window,omitempty"`
+	OutputTokens  int `json:"output_tokens,omitempty"`
+	// InputModalities are the kinds of input the model accepts through the configured backend:
+	// text, image, audio, video or pdf.
+	InputModalities  []string      `json:"input_modalities,omitempty"`
+	OutputModalities []string      `json:"output_modalities,omitempty"`
+	Pricing          *ModelPricing `json:"pricing,omitempty"`
+	// Source is where the entry comes from: "builtin", "backend" or "user". Ignored on write.
+	Source string `json:"source,omitempty"`
+	// Default marks the model new chats use unless they pick another. Ignored on write.
+	Default bool `json:"default,omitempty"`
+}
+
+// ModelPricing is what a model costs, in US dollars per million tokens.
+type ModelPricing struct {
+	InputPerMillion  float64 `json:"input_per_million"`
+	OutputPerMillion float64 `json:"output_per_million"`
+}
@@ -0,0 +1,50 @@
+package domain
+
+import "time"
+
+// LocalUserID identifies the built-in account used with the per-launch API token. It owns every
+// chat, file and setting created before multi-user support.
+const LocalUserID = "local"
+
+// User is a local account. Password and token hashes are persisted but never returned to clients.
+type User struct {
+	ID           string     `json:"id"`
+	Username     string     `json:"username"`
+	Admin        bool       `json:"admin"`
+	CreatedAt    time.Time  `json:"created_at"`
+	PasswordHash string     `json:"password_hash,omitempty"`
+	Tokens       []APIToken `json:"tokens,omitempty"`
+}
+
+// APIToken is a long-lived bearer token. Only the SHA-256 hash of the token is stored.
+type APIToken struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Hash      string    `json:"hash"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// PublicUser is the client-facing view of a User.
+type PublicUser struct {
+	ID        string           `json:"id"`
+	Username  string           `json:"username"`
+	Admin     bool             `json:"admin"`
+	CreatedAt time.Time        `json:"created_at"`
+	Tokens    []PublicAPIToken `json:"tokens"`
+}
+
+// PublicAPIToken describes a token without revealing it.
+type PublicAPIToken struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Public returns the client-facing view of u.
+func (u *User) Public() *PublicUser {
+	tokens := make([]PublicAPIToken, 0, len(u.Tokens))
+	for _, t := range u.Tokens {
+		tokens = append(tokens, PublicAPIToken{ID: t.ID, Name: t.Name, CreatedAt: t.CreatedAt})
+	}
+	return &PublicUser{ID: u.ID, Username: u.Username, Admin: u.Admin, CreatedAt: u.CreatedAt, Tokens: tokens}
+}
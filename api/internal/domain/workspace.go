@@ -0,0 +1,35 @@
+package domain
+
+import "time"
+
+// Workspace is a local directory indexed as context for chats: its file tree and the contents of
+// its text files, split into chunks the prompt builder can pick from.
+type Workspace struct {
+	ID      string `json:"id"`
+	OwnerID string `json:"owner_id"`
+	Name    string `json:"name"`
+	Root    string `json:"root"`
+	// GitHead is the checked-out branch or commit when Root is a git repository.
+	GitHead   string          `json:"git_head,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+	IndexedAt time.Time       `json:"indexed_at"`
+	Files     []WorkspaceFile `json:"files"`
+	// Skipped counts text files left out because a size or count limit was reached.
+	Skipped int              `json:"skipped"`
+	Chunks  []WorkspaceChunk `json:"chunks,omitempty"`
+}
+
+// WorkspaceFile is one indexed file. Path is slash-separated and relative to the workspace root.
+type WorkspaceFile struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// WorkspaceChunk is a range of lines of an indexed file.
+type WorkspaceChunk struct {
+	Path      string `json:"path"`
+	StartLine int    `json:"start_line"`
+	EndLine   int    `json:"end_line"`
+	Text      string `json:"text"`
+}
@@ -0,0 +1,105 @@
+package extract
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// extractEpub returns the text of every chapter of an EPUB book in reading order.
+func extractEpub(data []byte) (string, []string, error) {
+	a, err := openArchive(data)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// META-INF/container.xml points at the package document, which lists the chapters
+	container, err := a.read("META-INF/container.xml")
+	if err != nil {
+		return "", nil, err
+	}
+	if container == nil {
+		return "", nil, errors.New("META-INF/container.xml is missing")
+	}
+	var c struct {
+		Rootfiles []struct {
+			FullPath string `xml:"full-path,attr"`
+		} `xml:"rootfiles>rootfile"`
+	}
+	if err := xml.Unmarshal(container, &c); err != nil {
+		return "", nil, fmt.Errorf("META-INF/container.xml: %w", err)
+	}
+	if len(c.Rootfiles) == 0 {
+		return "", nil, errors.New("the book has no package document")
+	}
+
+	opfPath := c.Rootfiles[0].FullPath
+	opfData, err := a.read(opfPath)
+	if err != nil {
+		return "", nil, err
+	}
+	if opfData == nil {
+		return "", nil, fmt.Errorf("%s is missing", opfPath)
+	}
+	var opf struct {
+		Title    string `xml:"metadata>title"`
+		Manifest []struct {
+			ID        string `xml:"id,attr"`
+			Href      string `xml:"href,attr"`
+			MediaType string `xml:"media-type,attr"`
+		} `xml:"manifest>item"`
+		Spine []struct {
+			IDRef string `xml:"idref,attr"`
+		} `xml:"spine>itemref"`
+	}
+	if err := xml.Unmarshal(opfData, &opf); err != nil {
+		return "", nil, fmt.Errorf("%s: %w", opfPath, err)
+	}
+
+	items := make(map[string]string, len(opf.Manifest))
+	for _, item := range opf.Manifest {
+		if item.MediaType == "application/xhtml+xml" || item.MediaType == "text/html" {
+			items[item.ID] = item.Href
+		}
+	}
+
+	var sb strings.Builder
+	var warnings []string
+	if title := strings.TrimSpace(opf.Title); title != "" {
+		sb.WriteString("# " + title + "\n\n")
+	}
+	base := path.Dir(opfPath)
+	skipped := 0
+	for _, ref := range opf.Spine {
+		href, ok := items[ref.IDRef]
+		if !ok {
+			skipped++
+			continue
+		}
+		if unescaped, err := url.PathUnescape(href); err == nil {
+			href = unescaped
+		}
+		chapter, err := a.read(path.Join(base, href))
+		if errors.Is(err, errArchiveTooLarge) {
+			return sb.String(), append(warnings, err.Error()), nil
+		}
+		if err != nil || chapter == nil {
+			warnings = append(warnings, fmt.Sprintf("chapter %s could not be read", href))
+			continue
+		}
+		text, err := htmlText(chapter, false)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("chapter %s is malformed", href))
+			continue
+		}
+		sb.WriteString(strings.TrimSpace(text))
+		sb.WriteString("\n\n")
+	}
+	if skipped > 0 {
+		warnings = append(warnings, fmt.Sprintf("%d non-text spine items were skipped", skipped))
+	}
+	return sb.String(), warnings, nil
+}
@@ -0,0 +1,173 @@
+// Package extract turns uploaded documents into plain text that can be placed in a prompt.
+// Extractors are registered by MIME type and chosen by sniffing the content, never by trusting
+// the file name.
+package extract
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gabriel-vasile/mimetype"
+)
+
+// Extraction statuses reported for every uploaded document.
+const (
+	StatusOK          = "ok"          // all text was extracted
+	StatusPartial     = "partial"     // text was extracted but something was skipped, see the warnings
+	StatusEmpty       = "empty"       // the document was read but contains no text
+	StatusFailed      = "failed"      // the document could not be read
+	StatusUnsupported = "unsupported" // no extractor is registered for the MIME type
+)
+
+// maxTextBytes caps the extracted text so a single document cannot flood the prompt.
+const maxTextBytes = 4 << 20
+
+// Extractor returns the readable text of a document along with warnings about anything that
+// could not be extracted.
+type Extractor interface {
+	Extract(data []byte) (text string, warnings []string, err error)
+}
+
+// Func adapts a function to the Extractor interface.
+type Func func(data []byte) (string, []string, error)
+
+func (f Func) Extract(data []byte) (string, []string, error) { return f(data) }
+
+// Result describes the outcome of extracting a document.
+type Result struct {
+	MIMEType string
+	Text     string
+	Status   string
+	Warnings []string
+}
+
+// Registry maps MIME types to extractors.
+type Registry struct {
+	extractors map[string]Extractor
+}
+
+// NewRegistry creates an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{extractors: make(map[string]Extractor)}
+}
+
+// Default returns a Registry with the built-in extractors for text, PDF, Office Open XML, HTML,
+// EPUB and RTF documents.
+func Default() *Registry {
+	r := NewRegistry()
+	r.Register("text/plain", Func(extractText))
+	r.Register("application/pdf", Func(extractPDF))
+	r.Register(MIMEDocx, Func(extractDocx))
+	r.Register(MIMEXlsx, Func(extractXlsx))
+	r.Register(MIMEPptx, Func(extractPptx))
+	r.Register("text/html", Func(extractHTML))
+	r.Register(MIMEEpub, Func(extractEpub))
+	r.Register("text/rtf", Func(extractRTF))
+	return r
+}
+
+// Register sets the extractor for mimeType, replacing any previous one. Types without their own
+// extractor fall back to the extractor of their parent type, e.g. text/csv uses text/plain.
+func (r *Registry) Register(mimeType string, e Extractor) {
+	r.extractors[mimeType] = e
+}
+
+// With returns a copy of r that uses e for mimeType.
+func (r *Registry) With(mimeType string, e Extractor) *Registry {
+	c := NewRegistry()
+	for m, existing := range r.extractors {
+		c.extractors[m] = existing
+	}
+	c.Register(mimeType, e)
+	return c
+}
+
+// Extract sniffs the MIME type of data and runs the matching extractor.
+func (r *Registry) Extract(data []byte) (result Result) {
+	result.MIMEType = Detect(data)
+
+	e := r.lookup(result.MIMEType)
+	if e == nil {
+		result.Status = StatusUnsupported
+		result.Warnings = []string{fmt.Sprintf("no text extractor for %s", result.MIMEType)}
+		return result
+	}
+
+	// Parsers for binary formats may panic on malformed input; treat that as a failed read.
+	defer func() {
+		if p := recover(); p != nil {
+			result.Text = ""
+			result.Status = StatusFailed
+			result.Warnings = append(result.Warnings, fmt.Sprintf("malformed document: %v", p))
+		}
+	}()
+
+	text, warnings, err := e.Extract(data)
+	result.Warnings = warnings
+	if err != nil {
+		result.Status = StatusFailed
+		result.Warnings = append(result.Warnings, err.Error())
+		return result
+	}
+
+	text = strings.TrimSpace(strings.ToValidUTF8(text, "�"))
+	if len(text) > maxTextBytes {
+		cut := maxTextBytes
+		for cut > 0 && !utf8.RuneStart(text[cut]) {
+			cut--
+		}
+		text = text[:cut]
+		result.Warnings = append(result.Warnings, fmt.Sprintf("text truncated to %d bytes", maxTextBytes))
+	}
+	result.Text = text
+
+	switch {
+	case text == "":
+		result.Status = StatusEmpty
+	case len(result.Warnings) > 0:
+		result.Status = StatusPartial
+	default:
+		result.Status = StatusOK
+	}
+	return result
+}
+
+// lookup returns the extractor for mimeType or the closest parent type that has one.
+func (r *Registry) lookup(mimeType string) Extractor {
+	if e, ok := r.extractors[mimeType]; ok {
+		return e
+	}
+	for m := mimetype.Lookup(mimeType); m != nil; m = m.Parent() {
+		if e, ok := r.extractors[baseType(m.String())]; ok {
+			return e
+		}
+	}
+	return nil
+}
+
+// Detect returns the MIME type of data without parameters. ZIP containers are inspected
+// entry by entry, so Office and EPUB files are recognised even when the sniffer's prefix is too
+// short to see their marker files.
+func Detect(data []byte) string {
+	m := baseType(mimetype.Detect(data).String())
+	if m == "application/zip" {
+		if refined := sniffZip(data); refined != "" {
+			return refined
+		}
+	}
+	return m
+}
+
+func baseType(m string) string {
+	m, _, _ = strings.Cut(m, ";")
+	return strings.TrimSpace(m)
+}
+
+func extractText(data []byte) (string, []string, error) {
+	var warnings []string
+	if !utf8.Valid(data) {
+		warnings = append(warnings, "invalid UTF-8 sequences were replaced")
+	}
+	return string(data), warnings, nil
+}
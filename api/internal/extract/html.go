@@ -0,0 +1,275 @@
+package extract
+
+import (
+	"bytes"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// hiddenElements never contribute readable text.
+var hiddenElements = map[atom.Atom]bool{
+	atom.Head: true, atom.Script: true, atom.Style: true, atom.Noscript: true,
+	atom.Template: true, atom.Svg: true, atom.Iframe: true, atom.Object: true,
+}
+
+// blockElements start and end on their own line.
+var blockElements = map[atom.Atom]bool{
+	atom.P: true, atom.Div: true, atom.Section: true, atom.Article: true, atom.Header: true,
+	atom.Footer: true, atom.Nav: true, atom.Aside: true, atom.Main: true, atom.Blockquote: true,
+	atom.Pre: true, atom.Ul: true, atom.Ol: true, atom.Li: true, atom.Dl: true, atom.Dt: true,
+	atom.Dd: true, atom.Table: true, atom.Tr: true, atom.H1: true, atom.H2: true, atom.H3: true,
+	atom.H4: true, atom.H5: true, atom.H6: true, atom.Hr: true, atom.Figure: true,
+	atom.Figcaption: true, atom.Form: true, atom.Fieldset: true, atom.Address: true,
+}
+
+// boilerplateElements hold navigation and page chrome rather than content. They are skipped when
+// extracting the readable text of a web page.
+var boilerplateElements = map[atom.Atom]bool{
+	atom.Nav: true, atom.Header: true, atom.Footer: true, atom.Aside: true, atom.Form: true,
+	atom.Button: true, atom.Select: true, atom.Dialog: true, atom.Menu: true,
+}
+
+// boilerplateRoles are the ARIA landmark roles of page chrome.
+var boilerplateRoles = map[string]bool{
+	"navigation": true, "banner": true, "contentinfo": true, "complementary": true, "search": true,
+	"dialog": true, "alert": true,
+}
+
+// extractHTML returns the readable text of an HTML page: the title followed by the visible body
+// text, with headings marked Markdown style and list items as bullets.
+func extractHTML(data []byte) (string, []string, error) {
+	text, err := htmlText(data, true)
+	return text, nil, err
+}
+
+// htmlText renders the visible text of an HTML document, optionally preceded by its title.
+func htmlText(data []byte, withTitle bool) (string, error) {
+	doc, err := html.Parse(bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	w := &htmlWriter{}
+	if withTitle {
+		w.writeTitle(doc)
+	}
+	w.walk(doc)
+	return w.String(), nil
+}
+
+// ReadableHTML extracts only the main text of a web page, see Readable. Use it in place of the
+// default HTML extractor for pages fetched from the web.
+var ReadableHTML Extractor = Func(func(data []byte) (string, []string, error) {
+	text, err := Readable(data)
+	return text, nil, err
+})
+
+// Readable returns the main text of a web page: its title and the content of the <main> or
+// <article> element when there is one, without navigation, headers, footers, sidebars or forms.
+func Readable(data []byte) (string, error) {
+	doc, err := html.Parse(bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	w := &htmlWriter{skipBoilerplate: true}
+	w.writeTitle(doc)
+
+	root := findMain(doc)
+	if root == nil {
+		root = doc
+	}
+	w.walk(root)
+	return w.String(), nil
+}
+
+type htmlWriter struct {
+	sb              strings.Builder
+	pre             int
+	skipBoilerplate bool
+}
+
+// writeTitle writes the document title as a top-level heading.
+func (w *htmlWriter) writeTitle(doc *html.Node) {
+	if title := findElement(doc, atom.Title); title != nil {
+		if t := collapseSpace(nodeText(title)); t != "" {
+			w.sb.WriteString("# " + t + "\n\n")
+		}
+	}
+}
+
+func (w *htmlWriter) walk(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		if w.pre > 0 {
+			w.sb.WriteString(n.Data)
+		} else {
+			w.writeInline(n.Data)
+		}
+		return
+	case html.ElementNode:
+		if hiddenElements[n.DataAtom] {
+			return
+		}
+		if w.skipBoilerplate && (boilerplateElements[n.DataAtom] || boilerplateRoles[attr(n, "role")] || attr(n, "aria-hidden") == "true") {
+			return
+		}
+		switch n.DataAtom {
+		case atom.Br:
+			w.sb.WriteByte('\n')
+			return
+		case atom.Td, atom.Th:
+			w.sb.WriteByte('\t')
+		case atom.Img:
+			if alt := attr(n, "alt"); alt != "" {
+				w.writeInline("[" + alt + "]")
+			}
+			return
+		}
+	}
+
+	block := n.Type == html.ElementNode && blockElements[n.DataAtom]
+	if block {
+		w.newline()
+		switch n.DataAtom {
+		case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+			w.sb.WriteString(strings.Repeat("#", int(n.Data[1]-'0')) + " ")
+		case atom.Li:
+			w.sb.WriteString("- ")
+		}
+	}
+	if n.DataAtom == atom.Pre {
+		w.pre++
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		w.walk(c)
+	}
+	if n.DataAtom == atom.Pre {
+		w.pre--
+	}
+	if block {
+		w.newline()
+	}
+}
+
+// writeInline writes text with runs of whitespace collapsed to one space.
+func (w *htmlWriter) writeInline(text string) {
+	if text == "" {
+		return
+	}
+	collapsed := collapseSpace(text)
+	if collapsed == "" {
+		if !w.atLineStart() {
+			w.sb.WriteByte(' ')
+		}
+		return
+	}
+	if isSpace(text[0]) && !w.atLineStart() {
+		w.sb.WriteByte(' ')
+	}
+	w.sb.WriteString(collapsed)
+	if isSpace(text[len(text)-1]) {
+		w.sb.WriteByte(' ')
+	}
+}
+
+func (w *htmlWriter) newline() {
+	if s := w.sb.String(); s != "" && !strings.HasSuffix(s, "\n") {
+		w.sb.WriteByte('\n')
+	}
+}
+
+// atLineStart reports whether the text so far ends in whitespace, so no separating space is needed.
+func (w *htmlWriter) atLineStart() bool {
+	s := w.sb.String()
+	return s == "" || strings.HasSuffix(s, "\n") || strings.HasSuffix(s, " ") || strings.HasSuffix(s, "\t")
+}
+
+// String returns the text with trailing spaces removed and blank lines collapsed.
+func (w *htmlWriter) String() string {
+	lines := strings.Split(w.sb.String(), "\n")
+	out := make([]string, 0, len(lines))
+	blank := false
+	for _, line := range lines {
+		line = strings.TrimRight(line, " \t")
+		if line == "" {
+			if !blank && len(out) > 0 {
+				out = append(out, "")
+			}
+			blank = true
+			continue
+		}
+		blank = false
+		out = append(out, line)
+	}
+	return strings.Join(out, "\n")
+}
+
+func findElement(n *html.Node, a atom.Atom) *html.Node {
+	if n.Type == html.ElementNode && n.DataAtom == a {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := findElement(c, a); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// findMain returns the element holding the main content of a page: <main> or role="main", or
+// else the only <article>.
+func findMain(doc *html.Node) *html.Node {
+	var main *html.Node
+	var articles []*html.Node
+	var visit func(n *html.Node)
+	visit = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			if main == nil && (n.DataAtom == atom.Main || attr(n, "role") == "main") {
+				main = n
+			}
+			if n.DataAtom == atom.Article {
+				articles = append(articles, n)
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			visit(c)
+		}
+	}
+	visit(doc)
+	if main != nil {
+		return main
+	}
+	if len(articles) == 1 {
+		return articles[0]
+	}
+	return nil
+}
+
+func nodeText(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var sb strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		sb.WriteString(nodeText(c))
+	}
+	return sb.String()
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func collapseSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\n' || b == '\t' || b == '\r' || b == '\f'
+}
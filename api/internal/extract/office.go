@@ -0,0 +1,315 @@
+package extract
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Limits on the cells read from a single spreadsheet sheet.
+const (
+	maxSheetRows    = 5000
+	maxSheetColumns = 1024
+)
+
+var docxLayout = xmlLayout{
+	text:   "t",
+	breaks: map[string]bool{"p": true, "br": true, "cr": true},
+	tabs:   map[string]bool{"tab": true},
+	skip:   map[string]bool{"pPr": true, "rPr": true},
+}
+
+var slideLayout = xmlLayout{
+	text:   "t",
+	breaks: map[string]bool{"p": true, "br": true},
+}
+
+// extractDocx returns the body text of a Word document.
+func extractDocx(data []byte) (string, []string, error) {
+	a, err := openArchive(data)
+	if err != nil {
+		return "", nil, err
+	}
+	body, err := a.read("word/document.xml")
+	if err != nil {
+		return "", nil, err
+	}
+	if body == nil {
+		return "", nil, errors.New("word/document.xml is missing")
+	}
+
+	var warnings []string
+	text, err := xmlText(body, docxLayout)
+	if err != nil {
+		warnings = append(warnings, fmt.Sprintf("document body is malformed, text may be incomplete: %v", err))
+	}
+	if n := a.count("word/media/"); n > 0 {
+		warnings = append(warnings, fmt.Sprintf("%d embedded media files were skipped", n))
+	}
+	return text, warnings, nil
+}
+
+// extractPptx returns the text of every slide in presentation order.
+func extractPptx(data []byte) (string, []string, error) {
+	a, err := openArchive(data)
+	if err != nil {
+		return "", nil, err
+	}
+	presentation, err := a.read("ppt/presentation.xml")
+	if err != nil {
+		return "", nil, err
+	}
+	var doc struct {
+		Slides []struct {
+			RID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sldIdLst>sldId"`
+	}
+	if err := xml.Unmarshal(presentation, &doc); err != nil {
+		return "", nil, fmt.Errorf("ppt/presentation.xml: %w", err)
+	}
+	targets, err := a.relationships("ppt/presentation.xml")
+	if err != nil {
+		return "", nil, err
+	}
+
+	var sb strings.Builder
+	var warnings []string
+	for i, slide := range doc.Slides {
+		content, err := a.read(targets[slide.RID])
+		if errors.Is(err, errArchiveTooLarge) {
+			return sb.String(), append(warnings, err.Error()), nil
+		}
+		if err != nil || content == nil {
+			warnings = append(warnings, fmt.Sprintf("slide %d could not be read", i+1))
+			continue
+		}
+		text, err := xmlText(content, slideLayout)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("slide %d is malformed, text may be incomplete", i+1))
+		}
+		fmt.Fprintf(&sb, "## Slide %d\n%s\n", i+1, strings.TrimSpace(text))
+	}
+	if n := a.count("ppt/media/"); n > 0 {
+		warnings = append(warnings, fmt.Sprintf("%d embedded media files were skipped", n))
+	}
+	return sb.String(), warnings, nil
+}
+
+// extractXlsx returns every sheet as tab separated rows.
+func extractXlsx(data []byte) (string, []string, error) {
+	a, err := openArchive(data)
+	if err != nil {
+		return "", nil, err
+	}
+	workbook, err := a.read("xl/workbook.xml")
+	if err != nil {
+		return "", nil, err
+	}
+	var doc struct {
+		Sheets []struct {
+			Name string `xml:"name,attr"`
+			RID  string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	if err := xml.Unmarshal(workbook, &doc); err != nil {
+		return "", nil, fmt.Errorf("xl/workbook.xml: %w", err)
+	}
+	targets, err := a.relationships("xl/workbook.xml")
+	if err != nil {
+		return "", nil, err
+	}
+
+	var warnings []string
+	shared, err := a.sharedStrings()
+	if err != nil {
+		warnings = append(warnings, fmt.Sprintf("shared strings could not be read: %v", err))
+	}
+
+	var sb strings.Builder
+	for _, sheet := range doc.Sheets {
+		content, err := a.read(targets[sheet.RID])
+		if errors.Is(err, errArchiveTooLarge) {
+			return sb.String(), append(warnings, err.Error()), nil
+		}
+		if err != nil || content == nil {
+			warnings = append(warnings, fmt.Sprintf("sheet %q could not be read", sheet.Name))
+			continue
+		}
+		fmt.Fprintf(&sb, "## Sheet: %s\n", sheet.Name)
+		truncated, err := writeSheet(&sb, content, shared)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("sheet %q is malformed, rows may be missing", sheet.Name))
+		}
+		if truncated {
+			warnings = append(warnings, fmt.Sprintf("sheet %q was truncated to %d rows", sheet.Name, maxSheetRows))
+		}
+		sb.WriteByte('\n')
+	}
+	return sb.String(), warnings, nil
+}
+
+type sheetCell struct {
+	Ref    string `xml:"r,attr"`
+	Type   string `xml:"t,attr"`
+	Value  string `xml:"v"`
+	Inline struct {
+		Text string `xml:"t"`
+		Runs []struct {
+			Text string `xml:"t"`
+		} `xml:"r"`
+	} `xml:"is"`
+}
+
+// writeSheet writes the rows of a worksheet as tab separated lines. It reports whether rows were
+// dropped because of maxSheetRows.
+func writeSheet(sb *strings.Builder, data []byte, shared []string) (bool, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	rows := 0
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "row" {
+			continue
+		}
+		if rows == maxSheetRows {
+			return true, nil
+		}
+		var row struct {
+			Cells []sheetCell `xml:"c"`
+		}
+		if err := dec.DecodeElement(&row, &start); err != nil {
+			return false, err
+		}
+		rows++
+
+		var values []string
+		for _, c := range row.Cells {
+			// Keep columns aligned when empty cells are left out of the XML
+			col := columnIndex(c.Ref)
+			if col >= maxSheetColumns {
+				continue
+			}
+			if col > len(values) {
+				values = append(values, make([]string, col-len(values))...)
+			}
+			values = append(values, cellValue(c, shared))
+		}
+		sb.WriteString(strings.Join(values, "\t"))
+		sb.WriteByte('\n')
+	}
+}
+
+func cellValue(c sheetCell, shared []string) string {
+	switch c.Type {
+	case "s":
+		if i, err := strconv.Atoi(c.Value); err == nil && i >= 0 && i < len(shared) {
+			return shared[i]
+		}
+		return ""
+	case "inlineStr":
+		text := c.Inline.Text
+		for _, r := range c.Inline.Runs {
+			text += r.Text
+		}
+		return text
+	case "b":
+		if c.Value == "1" {
+			return "TRUE"
+		}
+		return "FALSE"
+	default:
+		return c.Value
+	}
+}
+
+// columnIndex converts the column letters of a cell reference such as "AB12" to a zero based
+// index, or returns -1 if ref has none.
+func columnIndex(ref string) int {
+	col := 0
+	for _, r := range ref {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		col = col*26 + int(r-'A'+1)
+	}
+	return col - 1
+}
+
+// sharedStrings returns the shared string table of a workbook.
+func (a *archive) sharedStrings() ([]string, error) {
+	data, err := a.read("xl/sharedStrings.xml")
+	if err != nil || data == nil {
+		return nil, err
+	}
+	var doc struct {
+		Items []struct {
+			Text string `xml:"t"`
+			Runs []struct {
+				Text string `xml:"t"`
+			} `xml:"r"`
+		} `xml:"si"`
+	}
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	out := make([]string, len(doc.Items))
+	for i, item := range doc.Items {
+		out[i] = item.Text
+		for _, r := range item.Runs {
+			out[i] += r.Text
+		}
+	}
+	return out, nil
+}
+
+// relationships returns the targets of the relationships of part, keyed by relationship id and
+// resolved to entry names.
+func (a *archive) relationships(part string) (map[string]string, error) {
+	dir, file := path.Split(part)
+	data, err := a.read(dir + "_rels/" + file + ".rels")
+	if err != nil {
+		return nil, err
+	}
+	var doc struct {
+		Relationships []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	if data != nil {
+		if err := xml.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("relationships of %s: %w", part, err)
+		}
+	}
+	targets := make(map[string]string, len(doc.Relationships))
+	for _, r := range doc.Relationships {
+		if strings.HasPrefix(r.Target, "/") {
+			targets[r.ID] = strings.TrimPrefix(r.Target, "/")
+		} else {
+			targets[r.ID] = path.Join(dir, r.Target)
+		}
+	}
+	return targets, nil
+}
+
+// count returns the number of entries whose name starts with prefix.
+func (a *archive) count(prefix string) int {
+	n := 0
+	for _, name := range a.names {
+		if strings.HasPrefix(name, prefix) && !strings.HasSuffix(name, "/") {
+			n++
+		}
+	}
+	return n
+}
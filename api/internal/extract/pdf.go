@@ -0,0 +1,40 @@
+package extract
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	pdf "github.com/ledongthuc/pdf"
+)
+
+// extractPDF returns the text layer of a PDF. Scanned pages without one yield no text.
+func extractPDF(data []byte) (string, []string, error) {
+	r, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", nil, err
+	}
+
+	var sb strings.Builder
+	var warnings []string
+	failed := 0
+	for pageIndex := 1; pageIndex <= r.NumPage(); pageIndex++ {
+		p := r.Page(pageIndex)
+		if p.V.IsNull() {
+			continue
+		}
+		txt, err := p.GetPlainText(nil)
+		if err != nil {
+			failed++
+			continue
+		}
+		sb.WriteString(txt)
+	}
+	if failed > 0 {
+		warnings = append(warnings, fmt.Sprintf("%d of %d pages could not be read", failed, r.NumPage()))
+	}
+	if strings.TrimSpace(sb.String()) == "" && r.NumPage() > 0 {
+		warnings = append(warnings, "the PDF has no text layer; scanned pages are not read")
+	}
+	return sb.String(), warnings, nil
+}
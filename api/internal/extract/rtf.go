@@ -0,0 +1,156 @@
+package extract
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// rtfSkippedDestinations hold formatting data or metadata rather than document text.
+var rtfSkippedDestinations = map[string]bool{
+	"fonttbl": true, "colortbl": true, "stylesheet": true, "info": true, "pict": true,
+	"object": true, "header": true, "headerl": true, "headerr": true, "headerf": true,
+	"footer": true, "footerl": true, "footerr": true, "footerf": true, "listtable": true,
+	"listoverridetable": true, "rsidtbl": true, "themedata": true, "colorschememapping": true,
+	"datastore": true, "latentstyles": true, "xmlnstbl": true, "generator": true,
+	"fldinst": true, "filetbl": true, "revtbl": true, "pgdsctbl": true,
+}
+
+// rtfSymbols are control words that stand for a single character.
+var rtfSymbols = map[string]string{
+	"par": "\n", "line": "\n", "sect": "\n\n", "page": "\n\n", "row": "\n", "cell": "\t",
+	"tab": "\t", "emdash": "—", "endash": "–", "bullet": "•", "lquote": "‘", "rquote": "’",
+	"ldblquote": "“", "rdblquote": "”", "emspace": " ", "enspace": " ",
+}
+
+type rtfGroup struct {
+	skip bool // the group is a destination without document text
+	uc   int  // number of fallback characters that follow a \u escape
+}
+
+// extractRTF returns the plain text of an RTF document. Bytes escaped as \'hh are decoded as
+// Windows-1252, the code page of almost all RTF files.
+func extractRTF(data []byte) (string, []string, error) {
+	if !bytes.HasPrefix(data, []byte(`{\rtf`)) {
+		return "", nil, errors.New("not an RTF document")
+	}
+
+	var sb strings.Builder
+	var warnings []string
+	stack := []rtfGroup{{uc: 1}}
+	pendingSkip := 0 // fallback characters left to skip after a \u escape
+
+	emit := func(s string) {
+		if stack[len(stack)-1].skip {
+			return
+		}
+		if pendingSkip > 0 {
+			pendingSkip--
+			return
+		}
+		sb.WriteString(s)
+	}
+
+	for i := 0; i < len(data); {
+		switch ch := data[i]; ch {
+		case '{':
+			stack = append(stack, stack[len(stack)-1])
+			pendingSkip = 0
+			i++
+		case '}':
+			if len(stack) > 1 {
+				stack = stack[:len(stack)-1]
+			}
+			pendingSkip = 0
+			i++
+		case '\r', '\n':
+			i++
+		case '\\':
+			i++
+			if i >= len(data) {
+				break
+			}
+			next := data[i]
+			switch {
+			case next == '\'':
+				if i+2 < len(data) {
+					if b, err := strconv.ParseUint(string(data[i+1:i+3]), 16, 8); err == nil {
+						emit(string(charmap.Windows1252.DecodeByte(byte(b))))
+					}
+				}
+				i += 3
+			case next == '*':
+				stack[len(stack)-1].skip = true
+				i++
+			case isASCIILetter(next):
+				start := i
+				for i < len(data) && isASCIILetter(data[i]) {
+					i++
+				}
+				word := string(data[start:i])
+				numStart := i
+				if i < len(data) && data[i] == '-' {
+					i++
+				}
+				for i < len(data) && data[i] >= '0' && data[i] <= '9' {
+					i++
+				}
+				param, hasParam := 0, i > numStart
+				if hasParam {
+					param, _ = strconv.Atoi(string(data[numStart:i]))
+				}
+				// A single space delimits the control word and is not part of the text
+				if i < len(data) && data[i] == ' ' {
+					i++
+				}
+
+				switch {
+				case rtfSkippedDestinations[word]:
+					stack[len(stack)-1].skip = true
+				case word == "u" && hasParam:
+					if param < 0 {
+						param += 65536
+					}
+					emit(string(rune(param)))
+					pendingSkip = stack[len(stack)-1].uc
+				case word == "uc" && hasParam:
+					stack[len(stack)-1].uc = param
+				case word == "ansicpg" && hasParam && param != 1252:
+					warnings = append(warnings, fmt.Sprintf("code page %d was decoded as Windows-1252", param))
+				case word == "bin" && param > 0:
+					// Raw binary data follows; it is never text
+					i += param
+				default:
+					if s, ok := rtfSymbols[word]; ok {
+						emit(s)
+					}
+				}
+			default:
+				// Control symbols
+				switch next {
+				case '\\', '{', '}':
+					emit(string(next))
+				case '~':
+					emit(" ")
+				case '_':
+					emit("-")
+				case '\n', '\r':
+					emit("\n")
+				}
+				i++
+			}
+		default:
+			emit(string(charmap.Windows1252.DecodeByte(ch)))
+			i++
+		}
+	}
+	return sb.String(), warnings, nil
+}
+
+func isASCIILetter(b byte) bool {
+	return b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z'
+}
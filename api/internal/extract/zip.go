@@ -0,0 +1,148 @@
+package extract
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// MIME types of the ZIP based formats recognised by Detect.
+const (
+	MIMEDocx = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+	MIMEXlsx = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	MIMEPptx = "application/vnd.openxmlformats-officedocument.presentationml.presentation"
+	MIMEEpub = "application/epub+zip"
+)
+
+// Limits that keep a small archive from expanding into gigabytes of XML.
+const (
+	maxEntryBytes   = 32 << 20
+	maxArchiveBytes = 128 << 20
+)
+
+var errArchiveTooLarge = errors.New("archive expands beyond the size limit")
+
+// sniffZip identifies Office Open XML and EPUB containers by their marker entries.
+func sniffZip(data []byte) string {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return ""
+	}
+	for _, f := range zr.File {
+		switch f.Name {
+		case "word/document.xml":
+			return MIMEDocx
+		case "xl/workbook.xml":
+			return MIMEXlsx
+		case "ppt/presentation.xml":
+			return MIMEPptx
+		case "mimetype":
+			if rc, err := f.Open(); err == nil {
+				head, _ := io.ReadAll(io.LimitReader(rc, 64))
+				rc.Close()
+				if strings.TrimSpace(string(head)) == MIMEEpub {
+					return MIMEEpub
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// archive gives size-limited access to the entries of a ZIP container.
+type archive struct {
+	files    map[string]*zip.File
+	names    []string
+	expanded int64
+}
+
+func openArchive(data []byte) (*archive, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("not a valid ZIP container: %w", err)
+	}
+	a := &archive{files: make(map[string]*zip.File, len(zr.File))}
+	for _, f := range zr.File {
+		a.files[f.Name] = f
+		a.names = append(a.names, f.Name)
+	}
+	return a, nil
+}
+
+// read returns the content of the named entry, or nil if the archive has no such entry.
+func (a *archive) read(name string) ([]byte, error) {
+	f, ok := a.files[name]
+	if !ok {
+		return nil, nil
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(io.LimitReader(rc, maxEntryBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	if len(data) > maxEntryBytes {
+		return nil, fmt.Errorf("%s: %w", name, errArchiveTooLarge)
+	}
+	a.expanded += int64(len(data))
+	if a.expanded > maxArchiveBytes {
+		return nil, errArchiveTooLarge
+	}
+	return data, nil
+}
+
+// xmlLayout tells xmlText how to turn the elements of an XML document into text.
+type xmlLayout struct {
+	text   string          // element whose character data is the text
+	breaks map[string]bool // elements that end a line when they close
+	tabs   map[string]bool // elements that insert a tab
+	skip   map[string]bool // elements whose whole subtree is ignored
+}
+
+// xmlText walks an XML document and collects its text as described by layout.
+func xmlText(data []byte, layout xmlLayout) (string, error) {
+	var sb strings.Builder
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	inText, skipping := 0, 0
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return sb.String(), nil
+		}
+		if err != nil {
+			return sb.String(), err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch {
+			case skipping > 0 || layout.skip[t.Name.Local]:
+				skipping++
+			case t.Name.Local == layout.text:
+				inText++
+			case layout.tabs[t.Name.Local]:
+				sb.WriteByte('\t')
+			}
+		case xml.EndElement:
+			switch {
+			case skipping > 0:
+				skipping--
+			case t.Name.Local == layout.text && inText > 0:
+				inText--
+			case layout.breaks[t.Name.Local]:
+				sb.WriteByte('\n')
+			}
+		case xml.CharData:
+			if inText > 0 && skipping == 0 {
+				sb.Write(t)
+			}
+		}
+	}
+}
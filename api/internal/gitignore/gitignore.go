@@ -0,0 +1,129 @@
+// Package gitignore matches paths against the patterns of .gitignore files.
+package gitignore
+
+import (
+	"bufio"
+	"bytes"
+	"path"
+	"strings"
+)
+
+// rule is one pattern of a .gitignore file.
+type rule struct {
+	base     string // directory of the .gitignore file, relative to the root; "" for the root
+	segments []string
+	negate   bool
+	dirOnly  bool
+	anchored bool // the pattern contains a slash, so it matches from base rather than any depth
+}
+
+// Matcher holds the rules of every .gitignore file added so far. Later rules take precedence,
+// as in git, so files must be added parent directory first.
+type Matcher struct {
+	rules []rule
+}
+
+// Add parses the contents of the .gitignore file in dir, a slash-separated path relative to
+// the root ("" for the root itself).
+func (m *Matcher) Add(dir string, data []byte) {
+	dir = strings.Trim(dir, "/")
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		if r, ok := parseRule(dir, scanner.Text()); ok {
+			m.rules = append(m.rules, r)
+		}
+	}
+}
+
+func parseRule(base string, line string) (rule, bool) {
+	line = strings.TrimRight(line, "\r")
+	if !strings.HasSuffix(line, `\ `) {
+		line = strings.TrimRight(line, " ")
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return rule{}, false
+	}
+
+	r := rule{base: base}
+	if strings.HasPrefix(line, "!") {
+		r.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		r.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if strings.Contains(line, "/") {
+		r.anchored = true
+		line = strings.TrimLeft(line, "/")
+	}
+	if line == "" {
+		return rule{}, false
+	}
+	r.segments = strings.Split(line, "/")
+	return r, true
+}
+
+// Match reports whether the slash-separated path rel, relative to the root, is ignored. The
+// parent directories of rel must not be ignored themselves; callers walking a tree skip
+// ignored directories instead of descending into them.
+func (m *Matcher) Match(rel string, isDir bool) bool {
+	rel = strings.Trim(rel, "/")
+	ignored := false
+	for _, r := range m.rules {
+		if r.dirOnly && !isDir {
+			continue
+		}
+		sub := rel
+		if r.base != "" {
+			if !strings.HasPrefix(rel, r.base+"/") {
+				continue
+			}
+			sub = rel[len(r.base)+1:]
+		}
+		if r.matches(sub) {
+			ignored = !r.negate
+		}
+	}
+	return ignored
+}
+
+func (r rule) matches(rel string) bool {
+	parts := strings.Split(rel, "/")
+	if r.anchored {
+		return matchSegments(r.segments, parts)
+	}
+	// Patterns without a slash match a name at any depth
+	return matchSegment(r.segments[0], parts[len(parts)-1])
+}
+
+// matchSegments matches path segments against pattern segments, where "**" stands for any
+// number of segments.
+func matchSegments(pattern []string, parts []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			rest := pattern[1:]
+			if len(rest) == 0 {
+				return true
+			}
+			for i := 0; i <= len(parts); i++ {
+				if matchSegments(rest, parts[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(parts) == 0 || !matchSegment(pattern[0], parts[0]) {
+			return false
+		}
+		pattern, parts = pattern[1:], parts[1:]
+	}
+	return len(parts) == 0
+}
+
+func matchSegment(pattern string, name string) bool {
+	ok, err := path.Match(pattern, name)
+	return err == nil && ok
+}
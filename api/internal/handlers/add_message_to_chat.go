@@ -1,8 +1,11 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 
+	"gemiwin/api/internal/domain"
 	"gemiwin/api/internal/services"
 
 	"github.com/gin-gonic/gin"
@@ -18,7 +21,26 @@ func AddMessageToChat(service *services.ChatService) gin.HandlerFunc {
 			return
 		}
 
-		chat, err := service.AddMessageToChat(chatID, req.Content, nil)
+		if len(req.DocumentIDs) > maxFilesPerMessage {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("At most %d files can be sent in one message", maxFilesPerMessage)})
+			return
+		}
+
+		var chat *domain.Chat
+		var err error
+		if len(req.DocumentIDs) > 0 {
+			chat, err = service.AddFileToChat(currentUserID(c), chatID, req.Content, nil, req.DocumentIDs, nil)
+		} else {
+			chat, err = service.AddMessageToChat(currentUserID(c), chatID, req.Content, nil)
+		}
+		if budgetExceeded(c, err) {
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, services.ErrDocumentNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send message"})
 			return
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"gemiwin/api/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AddURLRequest struct {
+	URL     string `json:"url" binding:"required"`
+	Content string `json:"content"`
+}
+
+// AddURLToChat handles POST /chats/:id/urls. It downloads a web page or file, adds it to the
+// user's library and sends it to the chat like an uploaded file. Hosts outside the configured
+// policy answer 403, oversized resources 413, disallowed types 415 and failed downloads 502.
+func AddURLToChat(service *services.ChatService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req AddURLRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+
+		chat, err := service.AddURLToChat(currentUserID(c), c.Param("id"), req.URL, req.Content)
+		switch {
+		case errors.Is(err, services.ErrURLNotAllowed):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, services.ErrURLTooLarge):
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, services.ErrUnsupportedFileType):
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, services.ErrURLFetchFailed):
+			c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+			return
+		case budgetExceeded(c, err):
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+			return
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if chat == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Chat not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, chat)
+	}
+}
@@ -0,0 +1,22 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"gemiwin/api/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// budgetExceeded reports whether err is a used-up budget, and if so sets Retry-After to the
+// seconds until the budget resets.
+func budgetExceeded(c *gin.Context, err error) bool {
+	var exceeded *services.BudgetExceededError
+	if !errors.As(err, &exceeded) {
+		return false
+	}
+	c.Header("Retry-After", strconv.Itoa(int(time.Until(exceeded.ResetsAt).Seconds())+1))
+	return true
+}
@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"gemiwin/api/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ChangePasswordRequest carries the current and the new password.
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+// ChangePassword handles PUT /me/password.
+func ChangePassword(service *services.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req ChangePasswordRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+
+		err := service.ChangePassword(currentUserID(c), req.CurrentPassword, req.NewPassword)
+		switch {
+		case err == nil:
+			c.JSON(http.StatusNoContent, nil)
+		case errors.Is(err, services.ErrInvalidCredentials):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Current password is wrong"})
+		case errors.Is(err, services.ErrWeakPassword), errors.Is(err, services.ErrLocalUser):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change password"})
+		}
+	}
+}
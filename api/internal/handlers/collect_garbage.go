@@ -0,0 +1,22 @@
+package handlers
+
+import (
+	"net/http"
+
+	"gemiwin/api/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CollectGarbage handles POST /admin/gc and deletes stored files that no chat references.
+func CollectGarbage(service *services.StorageService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		report, err := service.CollectGarbage()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to collect unreferenced files"})
+			return
+		}
+
+		c.JSON(http.StatusOK, report)
+	}
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"gemiwin/api/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CompareModelsRequest struct {
+	Content string   `json:"content"`
+	Models  []string `json:"models"`
+}
+
+// CompareModels handles POST /chats/:id/compare. It sends the message to every listed model at
+// once and responds with the chat, whose new bot message holds each answer as an alternate with
+// its latency and token counts.
+func CompareModels(service *services.ChatService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		chatID := c.Param("id")
+
+		var req CompareModelsRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+
+		chat, err := service.Compare(currentUserID(c), chatID, req.Content, req.Models)
+		if budgetExceeded(c, err) {
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, services.ErrInvalidComparison) || errors.Is(err, services.ErrUnknownModel) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if chat == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Chat not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, chat)
+	}
+}
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"gemiwin/api/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateAPITokenRequest names a new API token.
+type CreateAPITokenRequest struct {
+	Name string `json:"name"`
+}
+
+// CreateAPIToken handles POST /me/tokens.
+func CreateAPIToken(service *services.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req CreateAPITokenRequest
+		if err := c.ShouldBindJSON(&req); err != nil || req.Name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+
+		token, info, err := service.CreateToken(currentUserID(c), req.Name)
+		if err != nil {
+			if errors.Is(err, services.ErrLocalUser) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create token"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, TokenResponse{Token: token, Info: info})
+	}
+}
@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"net/http"
+	"os"
+	"time"
+
+	"gemiwin/api/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateBackup handles POST /admin/backup and returns a versioned archive of the data directory.
+func CreateBackup(service *services.BackupService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Build the archive in a temporary file first so the storage lock is not held
+		// while a slow client downloads it.
+		tmp, err := os.CreateTemp("", "gemiwin-backup-*.zip")
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create backup"})
+			return
+		}
+		defer os.Remove(tmp.Name())
+		defer tmp.Close()
+
+		if _, err := service.CreateBackup(tmp); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create backup"})
+			return
+		}
+
+		c.FileAttachment(tmp.Name(), services.BackupFileName(time.Now()))
+	}
+}
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"gemiwin/api/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CreateWorkspaceRequest struct {
+	Path string `json:"path" binding:"required"`
+	Name string `json:"name"`
+}
+
+// CreateWorkspace handles POST /workspaces. It indexes a directory on the server's machine;
+// paths outside the configured workspace roots answer 403.
+func CreateWorkspace(service *services.WorkspaceService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req CreateWorkspaceRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+
+		ws, err := service.Create(currentUserID(c), req.Path, req.Name)
+		switch {
+		case errors.Is(err, services.ErrWorkspacePathNotAllowed):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		case errors.Is(err, services.ErrWorkspaceNotDirectory):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusCreated, ws)
+	}
+}
@@ -0,0 +1,22 @@
+package handlers
+
+import (
+	"gemiwin/api/internal/middlewares"
+
+	"github.com/gin-gonic/gin"
+)
+
+// currentUserID returns the id of the authenticated user, or "" when there is none, which matches
+// no data.
+func currentUserID(c *gin.Context) string {
+	if user := middlewares.CurrentUser(c); user != nil {
+		return user.ID
+	}
+	return ""
+}
+
+// currentUserIsAdmin reports whether the authenticated user has administrator rights.
+func currentUserIsAdmin(c *gin.Context) bool {
+	user := middlewares.CurrentUser(c)
+	return user != nil && user.Admin
+}
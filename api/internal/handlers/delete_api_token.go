@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"gemiwin/api/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DeleteAPIToken handles DELETE /me/tokens/:id.
+func DeleteAPIToken(service *services.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		err := service.RevokeToken(currentUserID(c), c.Param("id"))
+		switch {
+		case err == nil:
+			c.JSON(http.StatusNoContent, nil)
+		case errors.Is(err, services.ErrTokenNotFound), errors.Is(err, services.ErrLocalUser):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Token not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke token"})
+		}
+	}
+}
@@ -12,10 +12,15 @@ func DeleteChat(service *services.ChatService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		chatID := c.Param("id")
 
-		if err := service.DeleteChatByID(chatID); err != nil {
+		deleted, err := service.DeleteChatByID(currentUserID(c), chatID)
+		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete chat"})
 			return
 		}
+		if !deleted {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Chat not found"})
+			return
+		}
 
 		c.JSON(http.StatusNoContent, nil)
 	}
@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"net/http"
+
+	"gemiwin/api/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DeleteDocument handles DELETE /documents/:id. Messages the document was attached to keep it.
+func DeleteDocument(service *services.DocumentService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		deleted, err := service.Delete(currentUserID(c), c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete document"})
+			return
+		}
+		if !deleted {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Document not found"})
+			return
+		}
+
+		c.JSON(http.StatusNoContent, nil)
+	}
+}
@@ -20,7 +20,7 @@ func DeleteMessagesFromChat(service *services.ChatService) gin.HandlerFunc {
 			return
 		}
 
-		chat, err := service.DeleteMessagesFromIndex(chatID, idx)
+		chat, err := service.DeleteMessagesFromIndex(currentUserID(c), chatID, idx)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
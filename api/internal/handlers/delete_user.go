@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"gemiwin/api/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DeleteUser handles DELETE /users/:id. The account's chats and files stay on disk.
+func DeleteUser(service *services.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		err := service.DeleteUser(c.Param("id"))
+		switch {
+		case err == nil:
+			c.JSON(http.StatusNoContent, nil)
+		case errors.Is(err, services.ErrUserNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		case errors.Is(err, services.ErrLocalUser):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user"})
+		}
+	}
+}
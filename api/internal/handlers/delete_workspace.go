@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"net/http"
+
+	"gemiwin/api/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DeleteWorkspace handles DELETE /workspaces/:id. Only the index is removed, never the directory.
+func DeleteWorkspace(service *services.WorkspaceService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		deleted, err := service.Delete(currentUserID(c), c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete workspace"})
+			return
+		}
+		if !deleted {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Workspace not found"})
+			return
+		}
+
+		c.JSON(http.StatusNoContent, nil)
+	}
+}
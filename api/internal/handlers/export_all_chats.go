@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"gemiwin/api/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ExportAllChats handles GET /export and streams a zip archive with every chat of the
+// current user and its files.
+func ExportAllChats(service *services.ExportService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Content-Type", "application/zip")
+		c.Header("Content-Disposition", "attachment; filename=\""+services.ExportArchiveName(time.Now())+"\"")
+		c.Status(http.StatusOK)
+
+		// Headers are already sent once streaming starts, so failures can only be logged.
+		if err := service.ExportAll(currentUserID(c), c.Writer); err != nil {
+			log.Printf("failed to export chats: %v", err)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"gemiwin/api/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ExportChat handles GET /chats/:id/export?format=md|html|json|txt|pdf to download a single chat.
+func ExportChat(service *services.ExportService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		chatID := c.Param("id")
+		format := c.DefaultQuery("format", services.ExportFormatMarkdown)
+
+		file, err := service.ExportChat(currentUserID(c), chatID, format, requestBaseURL(c))
+		if err != nil {
+			if errors.Is(err, services.ErrUnsupportedExportFormat) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export chat"})
+			return
+		}
+		if file == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Chat not found"})
+			return
+		}
+
+		c.Header("Content-Disposition", "attachment; filename=\""+file.FileName+"\"")
+		c.Data(http.StatusOK, file.ContentType, file.Data)
+	}
+}
+
+// requestBaseURL returns the scheme and host the client used to reach the API.
+func requestBaseURL(c *gin.Context) string {
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host
+}
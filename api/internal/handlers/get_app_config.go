@@ -11,7 +11,7 @@ import (
 // GetAppConfig handles GET /config to return the current application configuration.
 func GetAppConfig(service *services.AppConfigService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		cfg, err := service.GetConfig()
+		cfg, err := service.GetConfig(currentUserID(c))
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load configuration"})
 			return
@@ -12,7 +12,7 @@ func GetChat(service *services.ChatService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		chatID := c.Param("id")
 
-		chat, err := service.GetChatByID(chatID)
+		chat, err := service.GetChatByID(currentUserID(c), chatID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get chat"})
 			return
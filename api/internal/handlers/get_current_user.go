@@ -0,0 +1,22 @@
+package handlers
+
+import (
+	"net/http"
+
+	"gemiwin/api/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetCurrentUser handles GET /me.
+func GetCurrentUser(service *services.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := service.GetUser(currentUserID(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user"})
+			return
+		}
+
+		c.JSON(http.StatusOK, user)
+	}
+}
@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"net/http"
+
+	"gemiwin/api/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetDocument handles GET /documents/:id and returns a library document with its extracted text.
+func GetDocument(service *services.DocumentService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		doc, err := service.Get(currentUserID(c), c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get document"})
+			return
+		}
+		if doc == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Document not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, doc)
+	}
+}
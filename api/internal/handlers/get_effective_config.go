@@ -0,0 +1,17 @@
+package handlers
+
+import (
+	"net/http"
+
+	"gemiwin/api/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetEffectiveConfig handles GET /config/effective and returns the merged startup configuration
+// together with the source (default, file, env or flag) of every setting.
+func GetEffectiveConfig(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, cfg)
+	}
+}
@@ -0,0 +1,16 @@
+package handlers
+
+import (
+	"net/http"
+
+	"gemiwin/api/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetEncryptionStatus handles GET /encryption.
+func GetEncryptionStatus(service *services.EncryptionService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, service.Status())
+	}
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+
+	"gemiwin/api/internal/persistence"
+	"gemiwin/api/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetFile handles GET /files/:name. Only files attached to the current user's chats are served,
+// decrypted when encryption at rest is enabled. PDFs and text are shown inline (text always as
+// text/plain); anything else is sent as a download.
+func GetFile(service *services.ChatService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		doc, data, err := service.ReadDocument(currentUserID(c), c.Param("name"))
+		if err != nil {
+			if errors.Is(err, persistence.ErrInvalidFileName) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read file"})
+			return
+		}
+		if doc == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+			return
+		}
+
+		contentType, inline := services.ServedContentType(doc.ID)
+		disposition := "attachment"
+		if inline {
+			disposition = "inline"
+		}
+		c.Header("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": doc.Name}))
+		c.Header("X-Content-Type-Options", "nosniff")
+		c.Header("Content-Security-Policy", "default-src 'none'; sandbox")
+		c.Data(http.StatusOK, contentType, data)
+	}
+}
@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"net/http"
+
+	"gemiwin/api/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetStorageUsage handles GET /storage and reports the disk space taken by the current user's
+// chats and their files.
+func GetStorageUsage(service *services.StorageService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		usage, err := service.Usage(currentUserID(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute storage usage"})
+			return
+		}
+
+		c.JSON(http.StatusOK, usage)
+	}
+}
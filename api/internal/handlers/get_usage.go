@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"gemiwin/api/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetUsage handles GET /usage and reports the tokens and estimated cost of the current user's
+// answers. The optional from and to query parameters are dates (YYYY-MM-DD, both included) in the
+// server's time zone; chat_id limits the report to one chat.
+func GetUsage(service *services.UsageService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		filter := services.UsageFilter{ChatID: c.Query("chat_id")}
+		if from := c.Query("from"); from != "" {
+			day, err := time.ParseInLocation("2006-01-02", from, time.Local)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "from must be a date (YYYY-MM-DD)"})
+				return
+			}
+			filter.From = day
+		}
+		if to := c.Query("to"); to != "" {
+			day, err := time.ParseInLocation("2006-01-02", to, time.Local)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "to must be a date (YYYY-MM-DD)"})
+				return
+			}
+			filter.To = day.AddDate(0, 0, 1)
+		}
+
+		report, err := service.Report(currentUserID(c), filter)
+		if errors.Is(err, services.ErrChatNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Chat not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute usage"})
+			return
+		}
+
+		c.JSON(http.StatusOK, report)
+	}
+}
@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"net/http"
+
+	"gemiwin/api/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetWorkspace handles GET /workspaces/:id.
+func GetWorkspace(service *services.WorkspaceService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ws, err := service.Get(currentUserID(c), c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get workspace"})
+			return
+		}
+		if ws == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Workspace not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, ws)
+	}
+}
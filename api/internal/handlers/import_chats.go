@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"gemiwin/api/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ImportChats handles POST /import. It accepts either a multipart "file" field or a raw request body
+// containing a zip archive or JSON export from ChatGPT, Gemini Takeout or gemiwin itself. Exports
+// larger than services.MaxImportBytes, or archives expanding too far, are rejected with 413.
+func ImportChats(service *services.ImportService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, services.MaxImportBytes+multipartOverhead)
+		var reader io.Reader = c.Request.Body
+		if strings.HasPrefix(c.ContentType(), "multipart/") {
+			file, _, err := c.Request.FormFile("file")
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Import is too large"})
+				return
+			}
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Missing file"})
+				return
+			}
+			defer file.Close()
+			reader = file
+		}
+
+		data, err := io.ReadAll(io.LimitReader(reader, services.MaxImportBytes+1))
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) || int64(len(data)) > services.MaxImportBytes {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Import is too large"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read import data"})
+			return
+		}
+
+		report, err := service.Import(currentUserID(c), data)
+		if err != nil {
+			if errors.Is(err, services.ErrImportTooLarge) {
+				c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+				return
+			}
+			if errors.Is(err, services.ErrUnrecognizedImport) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import chats"})
+			return
+		}
+
+		c.JSON(http.StatusOK, report)
+	}
+}
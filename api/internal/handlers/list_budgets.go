@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"net/http"
+
+	"gemiwin/api/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListBudgets handles GET /budgets and reports how much of each of the current user's budgets is
+// used in the current period.
+func ListBudgets(service *services.BudgetService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		statuses, err := service.Status(currentUserID(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute budgets"})
+			return
+		}
+
+		c.JSON(http.StatusOK, statuses)
+	}
+}
@@ -10,7 +10,7 @@ import (
 
 func ListChats(service *services.ChatService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		chats, err := service.ListChats()
+		chats, err := service.ListChats(currentUserID(c))
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list chats"})
 			return
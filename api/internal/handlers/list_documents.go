@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"net/http"
+
+	"gemiwin/api/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListDocuments handles GET /documents and returns the current user's library without the
+// extracted text.
+func ListDocuments(service *services.DocumentService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		docs, err := service.List(currentUserID(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list documents"})
+			return
+		}
+
+		c.JSON(http.StatusOK, docs)
+	}
+}
@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"net/http"
+
+	"gemiwin/api/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListMCPServers handles GET /mcp/servers and connects to each of the current user's MCP servers
+// to report its tools and resources, or why it could not be reached.
+func ListMCPServers(service *services.MCPService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		statuses, err := service.Status(currentUserID(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list MCP servers"})
+			return
+		}
+
+		c.JSON(http.StatusOK, statuses)
+	}
+}
@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"net/http"
+
+	"gemiwin/api/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListModels handles GET /models and lists the models the current user can select, with their
+// limits, modalities and prices where known.
+func ListModels(registry *services.ModelRegistry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		models, err := registry.Models(currentUserID(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list models"})
+			return
+		}
+
+		c.JSON(http.StatusOK, models)
+	}
+}
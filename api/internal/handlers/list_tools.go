@@ -0,0 +1,16 @@
+package handlers
+
+import (
+	"net/http"
+
+	"gemiwin/api/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListTools handles GET /tools and returns the tools the bot can run, with their argument schemas.
+func ListTools(registry *services.ToolRegistry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, registry.Tools())
+	}
+}
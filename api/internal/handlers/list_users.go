@@ -0,0 +1,22 @@
+package handlers
+
+import (
+	"net/http"
+
+	"gemiwin/api/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListUsers handles GET /users.
+func ListUsers(service *services.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		users, err := service.ListUsers()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list users"})
+			return
+		}
+
+		c.JSON(http.StatusOK, users)
+	}
+}
@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"net/http"
+
+	"gemiwin/api/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListWorkspaces handles GET /workspaces and returns the current user's workspaces with their
+// file trees.
+func ListWorkspaces(service *services.WorkspaceService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		workspaces, err := service.List(currentUserID(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list workspaces"})
+			return
+		}
+
+		c.JSON(http.StatusOK, workspaces)
+	}
+}
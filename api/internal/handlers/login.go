@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gemiwin/api/internal/domain"
+	"gemiwin/api/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LoginRequest exchanges a username and password for an API token.
+type LoginRequest struct {
+	Username  string `json:"username"`
+	Password  string `json:"password"`
+	TokenName string `json:"token_name"`
+}
+
+// TokenResponse returns a newly issued API token. The token is only ever shown once.
+type TokenResponse struct {
+	Token string                 `json:"token"`
+	Info  *domain.PublicAPIToken `json:"info"`
+}
+
+// Login handles POST /auth/login. Too many attempts from one address or at one username get 429.
+func Login(service *services.UserService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req LoginRequest
+		if err := c.ShouldBindJSON(&req); err != nil || req.Username == "" || req.Password == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+
+		// The peer address, not X-Forwarded-For, which any client can set
+		token, info, err := service.Login(req.Username, req.Password, req.TokenName, c.RemoteIP())
+		if err != nil {
+			var throttled *services.LoginThrottledError
+			if errors.As(err, &throttled) {
+				c.Header("Retry-After", strconv.Itoa(int(time.Until(throttled.RetryAt).Seconds())+1))
+				c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+				return
+			}
+			if errors.Is(err, services.ErrInvalidCredentials) {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid username or password"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log in"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, TokenResponse{Token: token, Info: info})
+	}
+}
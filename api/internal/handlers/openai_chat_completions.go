@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"gemiwin/api/internal/domain"
+	"gemiwin/api/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ChatCompletionRequest is the OpenAI chat completions request. Sampling parameters are accepted
+// and ignored. Save and ChatID are gemiwin extensions; clients that cannot add fields can send the
+// X-Gemiwin-Save and X-Gemiwin-Chat-Id headers instead.
+type ChatCompletionRequest struct {
+	Model    string                  `json:"model"`
+	Messages []ChatCompletionMessage `json:"messages"`
+	Stream   bool                    `json:"stream"`
+	N        int                     `json:"n"`
+	Tools    []json.RawMessage       `json:"tools"`
+	Save     bool                    `json:"save"`
+	ChatID   string                  `json:"chat_id"`
+}
+
+// ChatCompletionMessage is one message of the conversation. Content is a string or a list of
+// parts, of which only text parts are supported.
+type ChatCompletionMessage struct {
+	Role    string          `json:"role"`
+	Content json.RawMessage `json:"content"`
+}
+
+// OpenAI object names of chat completion responses.
+const (
+	chatCompletionObject      = "chat.completion"
+	chatCompletionChunkObject = "chat.completion.chunk"
+)
+
+// ChatCompletions handles POST /v1/chat/completions, answering OpenAI-style requests through the
+// configured backend. With streaming, the whole answer is sent as one chunk once it is ready.
+func ChatCompletions(service *services.ChatService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req ChatCompletionRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			openAIError(c, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+		if req.N > 1 {
+			openAIError(c, http.StatusBadRequest, "Only one choice (n=1) is supported")
+			return
+		}
+		if len(req.Tools) > 0 {
+			openAIError(c, http.StatusBadRequest, "Client-side tools are not supported; the server runs its own tools")
+			return
+		}
+		if save, err := strconv.ParseBool(c.GetHeader("X-Gemiwin-Save")); err == nil {
+			req.Save = req.Save || save
+		}
+		if req.ChatID == "" {
+			req.ChatID = c.GetHeader("X-Gemiwin-Chat-Id")
+		}
+
+		completion, err := completionFromRequest(req)
+		if err != nil {
+			openAIError(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		chat, err := service.Complete(currentUserID(c), completion)
+		if errors.Is(err, services.ErrUnknownModel) {
+			c.JSON(http.StatusNotFound, gin.H{"error": gin.H{"message": err.Error(), "type": "invalid_request_error", "code": "model_not_found"}})
+			return
+		}
+		if budgetExceeded(c, err) {
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": gin.H{"message": err.Error(), "type": "insufficient_quota", "code": "budget_exceeded"}})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": gin.H{"message": "Failed to generate a response: " + err.Error(), "type": "server_error"}})
+			return
+		}
+		if chat == nil {
+			openAIError(c, http.StatusNotFound, "Chat not found")
+			return
+		}
+
+		id := "chatcmpl-" + uuid.New().String()
+		created := time.Now().Unix()
+		answer := chat.Messages[len(chat.Messages)-1]
+		// The model that answered, which differs from the chat's for per-request overrides
+		model := chat.Config.Model
+		if answer.Usage != nil && answer.Usage.Model != "" {
+			model = answer.Usage.Model
+		}
+		if chat.ID != "" {
+			c.Header("X-Gemiwin-Chat-Id", chat.ID)
+		}
+
+		if !req.Stream {
+			resp := gin.H{
+				"id":      id,
+				"object":  chatCompletionObject,
+				"created": created,
+				"model":   model,
+				"choices": []gin.H{{
+					"index":         0,
+					"message":       gin.H{"role": "assistant", "content": answer.Content},
+					"finish_reason": "stop",
+				}},
+			}
+			if u := answer.Usage; u != nil {
+				resp["usage"] = gin.H{"prompt_tokens": u.InputTokens, "completion_tokens": u.OutputTokens, "total_tokens": u.InputTokens + u.OutputTokens}
+			}
+			if chat.ID != "" {
+				resp["chat_id"] = chat.ID
+			}
+			c.JSON(http.StatusOK, resp)
+			return
+		}
+
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Status(http.StatusOK)
+		chunk := func(delta gin.H, finish any) {
+			data, _ := json.Marshal(gin.H{
+				"id":      id,
+				"object":  chatCompletionChunkObject,
+				"created": created,
+				"model":   model,
+				"choices": []gin.H{{"index": 0, "delta": delta, "finish_reason": finish}},
+			})
+			fmt.Fprintf(c.Writer, "data: %s\n\n", data)
+		}
+		chunk(gin.H{"role": "assistant", "content": answer.Content}, nil)
+		chunk(gin.H{}, "stop")
+		fmt.Fprint(c.Writer, "data: [DONE]\n\n")
+		c.Writer.Flush()
+	}
+}
+
+// completionFromRequest maps the OpenAI conversation to gemiwin messages. System and developer
+// messages become instructions; the conversation must end with a user message.
+func completionFromRequest(req ChatCompletionRequest) (services.Completion, error) {
+	completion := services.Completion{Model: req.Model, Save: req.Save, ChatID: req.ChatID}
+	var instructions []string
+	for i, msg := range req.Messages {
+		text, err := messageText(msg.Content)
+		if err != nil {
+			return completion, fmt.Errorf("messages[%d]: %w", i, err)
+		}
+		var role domain.Role
+		switch msg.Role {
+		case "system", "developer":
+			instructions = append(instructions, text)
+			continue
+		case "user":
+			role = domain.UserRole
+		case "assistant":
+			role = domain.BotRole
+		default:
+			return completion, fmt.Errorf("messages[%d]: role %q is not supported", i, msg.Role)
+		}
+		completion.Messages = append(completion.Messages, domain.Message{Role: role, Type: "text", Content: text, Timestamp: time.Now()})
+	}
+	completion.Instructions = strings.Join(instructions, "\n\n")
+
+	n := len(completion.Messages)
+	if n == 0 || completion.Messages[n-1].Role != domain.UserRole {
+		return completion, errors.New("the last message must come from the user")
+	}
+	// A stored chat already has the history; only the new question is added to it
+	if req.ChatID != "" {
+		completion.Messages = completion.Messages[n-1:]
+	}
+	return completion, nil
+}
+
+// messageText returns the text of a message content, given as a string or a list of text parts.
+func messageText(content json.RawMessage) (string, error) {
+	var text string
+	if err := json.Unmarshal(content, &text); err == nil {
+		return text, nil
+	}
+	var parts []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(content, &parts); err != nil {
+		return "", errors.New("content must be a string or a list of parts")
+	}
+	texts := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if part.Type != "text" {
+			return "", fmt.Errorf("content parts of type %q are not supported", part.Type)
+		}
+		texts = append(texts, part.Text)
+	}
+	return strings.Join(texts, "\n"), nil
+}
+
+// openAIError answers with an error in the shape OpenAI clients expect.
+func openAIError(c *gin.Context, status int, message string) {
+	c.JSON(status, gin.H{"error": gin.H{"message": message, "type": "invalid_request_error"}})
+}
@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"gemiwin/api/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OpenAIListModels handles GET /v1/models and lists the current user's models in the OpenAI format.
+func OpenAIListModels(registry *services.ModelRegistry) gin.HandlerFunc {
+	// OpenAI reports when a model was created; the server's start time stands in for it
+	created := time.Now().Unix()
+	return func(c *gin.Context) {
+		available, err := registry.Models(currentUserID(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": gin.H{"message": "Failed to list models", "type": "server_error"}})
+			return
+		}
+		models := make([]gin.H, 0, len(available))
+		for _, model := range available {
+			models = append(models, gin.H{"id": model.ID, "object": "model", "created": created, "owned_by": "google"})
+		}
+		c.JSON(http.StatusOK, gin.H{"object": "list", "data": models})
+	}
+}
@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"net/http"
+
+	"gemiwin/api/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ReindexWorkspace handles POST /workspaces/:id/reindex and scans the directory again right away.
+func ReindexWorkspace(service *services.WorkspaceService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ws, err := service.Reindex(currentUserID(c), c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if ws == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Workspace not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, ws)
+	}
+}
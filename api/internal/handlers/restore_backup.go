@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"gemiwin/api/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RestoreBackup handles POST /admin/restore?dry_run=true&prune=true. The archive is sent as a
+// multipart "file" field or as the raw request body, up to services.MaxBackupBytes (413).
+func RestoreBackup(service *services.BackupService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, services.MaxBackupBytes+multipartOverhead)
+		var reader io.Reader = c.Request.Body
+		if strings.HasPrefix(c.ContentType(), "multipart/") {
+			file, _, err := c.Request.FormFile("file")
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Backup is too large"})
+				return
+			}
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Missing file"})
+				return
+			}
+			defer file.Close()
+			reader = file
+		}
+
+		data, err := io.ReadAll(io.LimitReader(reader, services.MaxBackupBytes+1))
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) || int64(len(data)) > services.MaxBackupBytes {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Backup is too large"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read backup"})
+			return
+		}
+
+		dryRun := c.Query("dry_run") == "true"
+		prune := c.Query("prune") == "true"
+
+		report, err := service.Restore(data, dryRun, prune)
+		if err != nil {
+			if errors.Is(err, services.ErrInvalidBackup) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore backup"})
+			return
+		}
+
+		c.JSON(http.StatusOK, report)
+	}
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"gemiwin/api/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SelectAlternateRequest struct {
+	Alternate *int `json:"alternate"`
+}
+
+// SelectAlternate handles POST /chats/:id/messages/:index/select, keeping one answer of a model
+// comparison as the message's content.
+func SelectAlternate(service *services.ChatService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		chatID := c.Param("id")
+
+		idx, err := strconv.Atoi(c.Param("index"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid message index"})
+			return
+		}
+		var req SelectAlternateRequest
+		if err := c.ShouldBindJSON(&req); err != nil || req.Alternate == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+
+		chat, err := service.SelectAlternate(currentUserID(c), chatID, idx, *req.Alternate)
+		if errors.Is(err, services.ErrAlternateNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, services.ErrInvalidComparison) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if chat == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Chat not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, chat)
+	}
+}
@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 
 	"gemiwin/api/internal/domain"
@@ -12,6 +14,8 @@ import (
 type SendMessageRequest struct {
 	Content string             `json:"content"`
 	Config  *domain.ChatConfig `json:"config,omitempty"`
+	// DocumentIDs attaches documents from the user's library to the message.
+	DocumentIDs []string `json:"document_ids,omitempty"`
 }
 
 func SendMessage(service *services.ChatService) gin.HandlerFunc {
@@ -23,7 +27,30 @@ func SendMessage(service *services.ChatService) gin.HandlerFunc {
 			return
 		}
 
-		chat, err := service.AddMessageToChat("", req.Content, req.Config)
+		if len(req.DocumentIDs) > maxFilesPerMessage {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("At most %d files can be sent in one message", maxFilesPerMessage)})
+			return
+		}
+
+		var chat *domain.Chat
+		var err error
+		if len(req.DocumentIDs) > 0 {
+			chat, err = service.AddFileToChat(currentUserID(c), "", req.Content, nil, req.DocumentIDs, req.Config)
+		} else {
+			chat, err = service.AddMessageToChat(currentUserID(c), "", req.Content, req.Config)
+		}
+		if budgetExceeded(c, err) {
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, services.ErrUnknownModel) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, services.ErrDocumentNotFound) || errors.Is(err, services.ErrWorkspaceNotFound) || errors.Is(err, services.ErrMCPServerNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send message"})
 			return
@@ -0,0 +1,15 @@
+package handlers
+
+import (
+	"gemiwin/api/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ServeMCP handles /mcp, the streamable HTTP endpoint of the MCP server that lets other agents
+// list, read, search and continue the current user's chats.
+func ServeMCP(service *services.ChatService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		services.NewChatMCPServer(service, currentUserID(c)).ServeHTTP(c.Writer, c.Request)
+	}
+}
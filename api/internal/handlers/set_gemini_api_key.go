@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"gemiwin/api/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetSecretRequest carries a write-only secret value.
+type SetSecretRequest struct {
+	Value string `json:"value"`
+}
+
+// SetGeminiApiKey handles PUT /config/secrets/gemini-api-key. The key is stored encrypted and
+// never returned; the response is the masked configuration.
+func SetGeminiApiKey(service *services.AppConfigService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req SetSecretRequest
+		if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Value) == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+
+		cfg, err := service.SetGeminiApiKey(currentUserID(c), strings.TrimSpace(req.Value))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store API key"})
+			return
+		}
+
+		c.JSON(http.StatusOK, cfg)
+	}
+}
+
+// DeleteGeminiApiKey handles DELETE /config/secrets/gemini-api-key.
+func DeleteGeminiApiKey(service *services.AppConfigService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cfg, err := service.DeleteGeminiApiKey(currentUserID(c))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete API key"})
+			return
+		}
+
+		c.JSON(http.StatusOK, cfg)
+	}
+}
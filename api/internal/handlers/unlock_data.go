@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"gemiwin/api/internal/persistence"
+	"gemiwin/api/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UnlockRequest carries the passphrase used to decrypt the data directory.
+type UnlockRequest struct {
+	Passphrase string `json:"passphrase"`
+}
+
+// UnlockData handles POST /unlock. Until it succeeds, every endpoint touching encrypted data
+// answers 423 Locked.
+func UnlockData(service *services.EncryptionService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req UnlockRequest
+		if err := c.ShouldBindJSON(&req); err != nil || req.Passphrase == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+
+		if err := service.Unlock(req.Passphrase); err != nil {
+			switch {
+			case errors.Is(err, persistence.ErrWrongPassphrase):
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Wrong passphrase"})
+			case errors.Is(err, persistence.ErrEncryptionOff):
+				c.JSON(http.StatusConflict, gin.H{"error": "Encryption is not enabled"})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlock data"})
+			}
+			return
+		}
+
+		c.JSON(http.StatusOK, service.Status())
+	}
+}
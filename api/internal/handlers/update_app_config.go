@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 
 	"gemiwin/api/internal/domain"
@@ -18,7 +19,15 @@ func UpdateAppConfig(service *services.AppConfigService) gin.HandlerFunc {
 			return
 		}
 
-		updatedCfg, err := service.UpdateConfig(&req)
+		updatedCfg, err := service.UpdateConfig(currentUserID(c), currentUserIsAdmin(c), &req)
+		if errors.Is(err, services.ErrInvalidMCPServer) || errors.Is(err, services.ErrInvalidModel) || errors.Is(err, services.ErrInvalidBudget) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, services.ErrMCPCommandNotAllowed) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update configuration"})
 			return
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 
 	"gemiwin/api/internal/domain"
@@ -20,7 +21,15 @@ func UpdateChatConfig(service *services.ChatService) gin.HandlerFunc {
 			return
 		}
 
-		chat, err := service.UpdateChatConfig(chatID, req)
+		chat, err := service.UpdateChatConfig(currentUserID(c), chatID, req)
+		if errors.Is(err, services.ErrUnknownModel) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, services.ErrWorkspaceNotFound) || errors.Is(err, services.ErrMCPServerNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"gemiwin/api/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UploadDocument handles POST /documents and adds the "file" field to the current user's library.
+// Files larger than maxBytes are rejected with 413 and files outside the allowed types with 415.
+func UploadDocument(service *services.DocumentService, maxBytes int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBytes+multipartOverhead)
+
+		header, err := c.FormFile("file")
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "File is too large"})
+				return
+			}
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Missing file"})
+			return
+		}
+		data, err := readUpload(header, maxBytes)
+		if errors.Is(err, errFileTooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "File is too large"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read file"})
+			return
+		}
+
+		doc, err := service.Create(currentUserID(c), services.Upload{Name: header.Filename, Data: data})
+		if errors.Is(err, services.ErrUnsupportedFileType) {
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store document"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, doc)
+	}
+}
@@ -2,7 +2,10 @@ package handlers
 
 import (
 	"encoding/json"
-	"io/ioutil"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
 	"net/http"
 
 	"gemiwin/api/internal/domain"
@@ -11,23 +14,58 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func UploadFileToChat(service *services.ChatService) gin.HandlerFunc {
+// multipartOverhead leaves room for the other form fields and multipart headers on top of the
+// files themselves when limiting the request body.
+const multipartOverhead = 1 << 20
+
+// maxFilesPerMessage caps how many files a single message may carry.
+const maxFilesPerMessage = 10
+
+// UploadFileToChat handles file uploads to new or existing chats. Each "file" field adds one
+// document to the same message and to the user's library, and each "document_id" field attaches
+// a document already in the library. Files larger than maxBytes are rejected with 413 and files
+// outside the allowed types with 415.
+func UploadFileToChat(service *services.ChatService, maxBytes int64) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		chatID := c.Param("id")
 
-		file, header, err := c.Request.FormFile("file")
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxFilesPerMessage*maxBytes+multipartOverhead)
+
+		form, err := c.MultipartForm()
 		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Files are too large"})
+				return
+			}
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Missing file"})
 			return
 		}
-		defer file.Close()
-
-		bytes, err := ioutil.ReadAll(file)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read file"})
+		headers := form.File["file"]
+		documentIDs := form.Value["document_id"]
+		if len(headers) == 0 && len(documentIDs) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Missing file"})
+			return
+		}
+		if len(headers)+len(documentIDs) > maxFilesPerMessage {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("At most %d files can be sent in one message", maxFilesPerMessage)})
 			return
 		}
 
+		uploads := make([]services.Upload, 0, len(headers))
+		for _, header := range headers {
+			data, err := readUpload(header, maxBytes)
+			if errors.Is(err, errFileTooLarge) {
+				c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "File is too large: " + header.Filename})
+				return
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read file"})
+				return
+			}
+			uploads = append(uploads, services.Upload{Name: header.Filename, Data: data})
+		}
+
 		userContent := c.PostForm("content")
 
 		// Optional config field (JSON) for new chat creation
@@ -44,7 +82,23 @@ func UploadFileToChat(service *services.ChatService) gin.HandlerFunc {
 			}
 		}
 
-		chat, _, err := service.AddFileToChat(chatID, userContent, header.Filename, bytes, cfg)
+		chat, err := service.AddFileToChat(currentUserID(c), chatID, userContent, uploads, documentIDs, cfg)
+		if budgetExceeded(c, err) {
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, services.ErrUnsupportedFileType) {
+			c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, services.ErrUnknownModel) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, services.ErrDocumentNotFound) || errors.Is(err, services.ErrWorkspaceNotFound) || errors.Is(err, services.ErrMCPServerNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
@@ -57,3 +111,24 @@ func UploadFileToChat(service *services.ChatService) gin.HandlerFunc {
 		c.JSON(http.StatusOK, chat)
 	}
 }
+
+var errFileTooLarge = errors.New("file is too large")
+
+// readUpload reads one uploaded file, reading one byte past the limit to detect oversized files
+// without trusting header.Size.
+func readUpload(header *multipart.FileHeader, maxBytes int64) ([]byte, error) {
+	file, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxBytes {
+		return nil, errFileTooLarge
+	}
+	return data, nil
+}
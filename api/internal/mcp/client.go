@@ -0,0 +1,165 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+)
+
+// maxListPages bounds how many pages of tools or resources are read from one server.
+const maxListPages = 20
+
+// transport carries messages to one server.
+type transport interface {
+	// roundTrip sends msg and, for requests, waits for the matching response. Notifications
+	// return a nil response.
+	roundTrip(ctx context.Context, msg *Message) (*Message, error)
+	// alive reports whether the connection can still be used.
+	alive() bool
+	close() error
+}
+
+// Client is a connection to an MCP server that has completed the initialize handshake.
+type Client struct {
+	t      transport
+	nextID atomic.Int64
+	// Server describes the server and the features it offers.
+	Server InitializeResult
+}
+
+// connect performs the initialize handshake over t.
+func connect(ctx context.Context, t transport, info Implementation) (*Client, error) {
+	c := &Client{t: t}
+	params := InitializeParams{ProtocolVersion: ProtocolVersion, Capabilities: map[string]any{}, ClientInfo: info}
+	if err := c.call(ctx, "initialize", params, &c.Server); err != nil {
+		t.close()
+		return nil, fmt.Errorf("initialize: %w", err)
+	}
+	if err := c.notify(ctx, "notifications/initialized"); err != nil {
+		t.close()
+		return nil, fmt.Errorf("initialized: %w", err)
+	}
+	return c, nil
+}
+
+// Alive reports whether the connection is still usable, e.g. the server process has not exited.
+func (c *Client) Alive() bool {
+	return c.t.alive()
+}
+
+// Close ends the connection and stops a stdio server.
+func (c *Client) Close() error {
+	return c.t.close()
+}
+
+// ListTools returns every tool the server offers.
+func (c *Client) ListTools(ctx context.Context) ([]Tool, error) {
+	if c.Server.Capabilities.Tools == nil {
+		return []Tool{}, nil
+	}
+	tools := []Tool{}
+	cursor := ""
+	for page := 0; page < maxListPages; page++ {
+		var result ListToolsResult
+		if err := c.call(ctx, "tools/list", cursorParams(cursor), &result); err != nil {
+			return nil, err
+		}
+		tools = append(tools, result.Tools...)
+		if cursor = result.NextCursor; cursor == "" {
+			break
+		}
+	}
+	return tools, nil
+}
+
+// ListResources returns every resource the server offers.
+func (c *Client) ListResources(ctx context.Context) ([]Resource, error) {
+	if c.Server.Capabilities.Resources == nil {
+		return []Resource{}, nil
+	}
+	resources := []Resource{}
+	cursor := ""
+	for page := 0; page < maxListPages; page++ {
+		var result ListResourcesResult
+		if err := c.call(ctx, "resources/list", cursorParams(cursor), &result); err != nil {
+			return nil, err
+		}
+		resources = append(resources, result.Resources...)
+		if cursor = result.NextCursor; cursor == "" {
+			break
+		}
+	}
+	return resources, nil
+}
+
+// CallTool runs a tool. Failures the tool reports itself come back as a result with IsError set.
+func (c *Client) CallTool(ctx context.Context, name string, args json.RawMessage) (*ToolResult, error) {
+	var result ToolResult
+	if err := c.call(ctx, "tools/call", CallToolParams{Name: name, Arguments: args}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ReadResource returns the contents of the resource at uri.
+func (c *Client) ReadResource(ctx context.Context, uri string) ([]ResourceContents, error) {
+	var result ReadResourceResult
+	if err := c.call(ctx, "resources/read", ReadResourceParams{URI: uri}, &result); err != nil {
+		return nil, err
+	}
+	return result.Contents, nil
+}
+
+func cursorParams(cursor string) any {
+	if cursor == "" {
+		return nil
+	}
+	return map[string]string{"cursor": cursor}
+}
+
+func (c *Client) call(ctx context.Context, method string, params any, result any) error {
+	msg, err := newMessage(method, params)
+	if err != nil {
+		return err
+	}
+	msg.ID = json.RawMessage(strconv.FormatInt(c.nextID.Add(1), 10))
+
+	resp, err := c.t.roundTrip(ctx, msg)
+	if err != nil {
+		return err
+	}
+	if resp == nil {
+		return errors.New("no response")
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	if err := json.Unmarshal(resp.Result, result); err != nil {
+		return fmt.Errorf("invalid %s result: %w", method, err)
+	}
+	return nil
+}
+
+func (c *Client) notify(ctx context.Context, method string) error {
+	msg, err := newMessage(method, nil)
+	if err != nil {
+		return err
+	}
+	_, err = c.t.roundTrip(ctx, msg)
+	return err
+}
+
+func newMessage(method string, params any) (*Message, error) {
+	msg := &Message{JSONRPC: "2.0", Method: method}
+	if params != nil {
+		data, err := json.Marshal(params)
+		if err != nil {
+			return nil, err
+		}
+		msg.Params = data
+	}
+	return msg, nil
+}
@@ -0,0 +1,59 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// ForwardStdio relays newline-delimited messages read from in to the streamable HTTP endpoint at
+// url, sending headers with every request, and writes the answers to out until in ends. It lets a
+// client that only speaks stdio reach an HTTP server. Requests are forwarded concurrently.
+func ForwardStdio(ctx context.Context, in io.Reader, out io.Writer, url string, headers map[string]string, client *http.Client) error {
+	t := &httpTransport{url: url, headers: headers, client: client, live: true}
+	defer t.close()
+	write := lineWriter(out)
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64<<10), maxStdioMessage)
+	for scanner.Scan() {
+		var msg Message
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			write(&Message{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &Error{Code: CodeParseError, Message: "invalid JSON"}})
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := t.roundTrip(ctx, &msg)
+			if !msg.IsRequest() {
+				return
+			}
+			if err != nil {
+				resp = &Message{JSONRPC: "2.0", ID: msg.ID, Error: &Error{Code: CodeInternalError, Message: err.Error()}}
+			}
+			write(resp)
+		}()
+	}
+	return scanner.Err()
+}
+
+// lineWriter returns a function that writes messages to out one per line. It is safe for
+// concurrent use.
+func lineWriter(out io.Writer) func(*Message) {
+	var mu sync.Mutex
+	return func(msg *Message) {
+		data, err := json.Marshal(msg)
+		if err != nil {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		_, _ = out.Write(append(data, '\n'))
+	}
+}
@@ -0,0 +1,168 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// maxHTTPMessage caps the body read for one message.
+const maxHTTPMessage = 32 << 20
+
+// sessionHeader carries the session id assigned by a streamable HTTP server.
+const sessionHeader = "Mcp-Session-Id"
+
+// DialHTTP connects to the streamable HTTP endpoint at url, sending headers with every request.
+func DialHTTP(ctx context.Context, info Implementation, url string, headers map[string]string, client *http.Client) (*Client, error) {
+	t := &httpTransport{url: url, headers: headers, client: client, live: true}
+	return connect(ctx, t, info)
+}
+
+// httpTransport posts each message to the endpoint and reads the response from the reply, which
+// is either a JSON message or an event stream.
+type httpTransport struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+
+	mu        sync.Mutex
+	sessionID string
+	live      bool
+}
+
+func (t *httpTransport) roundTrip(ctx context.Context, msg *Message) (*Message, error) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	req, err := t.newRequest(ctx, http.MethodPost, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if id := resp.Header.Get(sessionHeader); id != "" {
+		t.mu.Lock()
+		t.sessionID = id
+		t.mu.Unlock()
+	}
+	if resp.StatusCode == http.StatusNotFound && t.session() != "" {
+		// The server forgot the session; a new connection is needed
+		t.mu.Lock()
+		t.live = false
+		t.mu.Unlock()
+		return nil, errors.New("the server ended the session")
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("server answered %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	// Notifications and responses to the server's requests are only acknowledged
+	if !msg.IsRequest() {
+		return nil, nil
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	body := io.LimitReader(resp.Body, maxHTTPMessage)
+	if mediaType == "text/event-stream" {
+		return readEventStream(body, msg.ID)
+	}
+	var reply Message
+	if err := json.NewDecoder(body).Decode(&reply); err != nil {
+		return nil, fmt.Errorf("invalid response: %w", err)
+	}
+	return &reply, nil
+}
+
+// readEventStream returns the response with the given id from a server-sent event stream,
+// skipping the notifications and requests sent before it.
+func readEventStream(r io.Reader, id json.RawMessage) (*Message, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64<<10), maxHTTPMessage)
+	var data strings.Builder
+	for {
+		more := scanner.Scan()
+		line := scanner.Text()
+		if more && line != "" {
+			if value, ok := strings.CutPrefix(line, "data:"); ok {
+				data.WriteString(strings.TrimPrefix(value, " "))
+				data.WriteString("\n")
+			}
+			continue
+		}
+		// A blank line (or the end of the stream) completes an event
+		if data.Len() > 0 {
+			var msg Message
+			if err := json.Unmarshal([]byte(data.String()), &msg); err == nil && msg.Method == "" && bytes.Equal(msg.ID, id) {
+				return &msg, nil
+			}
+			data.Reset()
+		}
+		if !more {
+			if err := scanner.Err(); err != nil {
+				return nil, err
+			}
+			return nil, errors.New("the event stream ended without a response")
+		}
+	}
+}
+
+func (t *httpTransport) newRequest(ctx context.Context, method string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, t.url, body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+	if id := t.session(); id != "" {
+		req.Header.Set(sessionHeader, id)
+	}
+	return req, nil
+}
+
+func (t *httpTransport) session() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.sessionID
+}
+
+func (t *httpTransport) alive() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.live
+}
+
+// close ends the session on the server, if it assigned one.
+func (t *httpTransport) close() error {
+	t.mu.Lock()
+	t.live = false
+	t.mu.Unlock()
+	if t.session() == "" {
+		return nil
+	}
+	req, err := t.newRequest(context.Background(), http.MethodDelete, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
@@ -0,0 +1,238 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	// fixtureEnv makes the test binary serve the fixture over stdio, or relay stdio to forwardURLEnv
+	// with "forward", instead of running the tests.
+	fixtureEnv    = "GEMIWIN_MCP_FIXTURE"
+	forwardURLEnv = "GEMIWIN_MCP_FIXTURE_URL"
+)
+
+func TestMain(m *testing.M) {
+	var err error
+	switch os.Getenv(fixtureEnv) {
+	case "stdio":
+		err = fixtureServer().ServeStdio(context.Background(), os.Stdin, os.Stdout)
+	case "forward":
+		headers := map[string]string{"Authorization": "Bearer fixture"}
+		err = ForwardStdio(context.Background(), os.Stdin, os.Stdout, os.Getenv(forwardURLEnv), headers, http.DefaultClient)
+	default:
+		os.Exit(m.Run())
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// fixtureServer offers an echo tool and a tool that always fails.
+func fixtureServer() *Server {
+	return NewServer(Implementation{Name: "fixture", Version: "0.1"}, "Test tools",
+		ServerTool{
+			Tool: Tool{Name: "echo", Description: "Echoes text", InputSchema: json.RawMessage(`{"type":"object","properties":{"text":{"type":"string"}}}`)},
+			Handler: func(_ context.Context, args json.RawMessage) (*ToolResult, error) {
+				var params struct {
+					Text string `json:"text"`
+				}
+				if err := json.Unmarshal(args, &params); err != nil {
+					return nil, err
+				}
+				return TextContent(params.Text, false), nil
+			},
+		},
+		ServerTool{
+			Tool: Tool{Name: "fail", InputSchema: json.RawMessage(`{"type":"object"}`)},
+			Handler: func(context.Context, json.RawMessage) (*ToolResult, error) {
+				return nil, errors.New("tool failed")
+			},
+		},
+	)
+}
+
+var testClientInfo = Implementation{Name: "test", Version: "1"}
+
+func TestStdio(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, err := DialStdio(ctx, testClientInfo, os.Args[0], []string{"-test.run=^$"}, map[string]string{fixtureEnv: "stdio"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exerciseFixture(ctx, t, client)
+
+	if err := client.Close(); err != nil {
+		t.Errorf("Close: %v", err)
+	}
+	if client.Alive() {
+		t.Error("client is alive after Close")
+	}
+}
+
+func TestStdioCommandFails(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	// The test binary without the fixture variable runs no tests and exits without answering
+	if _, err := DialStdio(ctx, testClientInfo, os.Args[0], []string{"-test.run=^$"}, nil); err == nil {
+		t.Fatal("connecting to a process that exits succeeded")
+	}
+}
+
+func TestHTTP(t *testing.T) {
+	srv := httptest.NewServer(fixtureServer())
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, err := DialHTTP(ctx, testClientInfo, srv.URL, nil, srv.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	exerciseFixture(ctx, t, client)
+}
+
+func TestHTTPEventStream(t *testing.T) {
+	// Servers may answer with an event stream instead of a JSON body
+	fixture := fixtureServer()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg Message
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		resp := fixture.Handle(r.Context(), &msg)
+		if resp == nil {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		data, _ := json.Marshal(resp)
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set(sessionHeader, "session-1")
+		fmt.Fprintf(w, ": keep-alive\n\nevent: message\ndata: %s\n\n", data)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, err := DialHTTP(ctx, testClientInfo, srv.URL, nil, srv.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	exerciseFixture(ctx, t, client)
+}
+
+func TestForwardStdio(t *testing.T) {
+	fixture := fixtureServer()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer fixture" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		fixture.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	env := map[string]string{fixtureEnv: "forward", forwardURLEnv: srv.URL}
+	client, err := DialStdio(ctx, testClientInfo, os.Args[0], []string{"-test.run=^$"}, env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	exerciseFixture(ctx, t, client)
+}
+
+func TestForwardStdioReportsHTTPErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	in := strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"ping"}` + "\n" + `{"jsonrpc":"2.0","method":"notifications/initialized"}` + "\nnot json\n")
+	var out strings.Builder
+	if err := ForwardStdio(context.Background(), in, &out, srv.URL, nil, srv.Client()); err != nil {
+		t.Fatal(err)
+	}
+	var codes []int
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var msg Message
+		if err := json.Unmarshal([]byte(line), &msg); err != nil || msg.Error == nil {
+			t.Fatalf("got %q, want an error response", line)
+		}
+		codes = append(codes, msg.Error.Code)
+	}
+	// The notification gets no answer; the order of the others is not fixed
+	if len(codes) != 2 || !slices.Contains(codes, CodeInternalError) || !slices.Contains(codes, CodeParseError) {
+		t.Errorf("got error codes %v, want %d and %d", codes, CodeInternalError, CodeParseError)
+	}
+}
+
+// exerciseFixture checks the handshake, tools/list and tools/call against fixtureServer.
+func exerciseFixture(ctx context.Context, t *testing.T, client *Client) {
+	t.Helper()
+	if client.Server.ServerInfo.Name != "fixture" || client.Server.Instructions != "Test tools" {
+		t.Errorf("initialize: got server %+v", client.Server)
+	}
+	if client.Server.ProtocolVersion != ProtocolVersion {
+		t.Errorf("initialize: protocol version %q, want %q", client.Server.ProtocolVersion, ProtocolVersion)
+	}
+
+	tools, err := client.ListTools(ctx)
+	if err != nil {
+		t.Fatalf("tools/list: %v", err)
+	}
+	var names []string
+	for _, tool := range tools {
+		names = append(names, tool.Name)
+	}
+	if strings.Join(names, ",") != "echo,fail" {
+		t.Errorf("tools/list: got %v, want [echo fail]", names)
+	}
+	resources, err := client.ListResources(ctx)
+	if err != nil || len(resources) != 0 {
+		t.Errorf("resources/list: got %v, %v; want none", resources, err)
+	}
+
+	tests := []struct {
+		tool    string
+		args    string
+		text    string
+		isError bool
+	}{
+		{"echo", `{"text":"hello"}`, "hello", false},
+		{"echo", ``, "", false},
+		{"fail", `{}`, "tool failed", true},
+	}
+	for _, tt := range tests {
+		result, err := client.CallTool(ctx, tt.tool, json.RawMessage(tt.args))
+		if err != nil {
+			t.Errorf("tools/call %s: %v", tt.tool, err)
+			continue
+		}
+		if len(result.Content) != 1 || result.Content[0].Text != tt.text || result.IsError != tt.isError {
+			t.Errorf("tools/call %s(%s) = %+v, want text %q and isError %v", tt.tool, tt.args, result, tt.text, tt.isError)
+		}
+	}
+
+	_, err = client.CallTool(ctx, "missing", json.RawMessage(`{}`))
+	var rpcErr *Error
+	if !errors.As(err, &rpcErr) || rpcErr.Code != CodeInvalidParams {
+		t.Errorf("tools/call of an unknown tool: error = %v, want code %d", err, CodeInvalidParams)
+	}
+}
@@ -0,0 +1,168 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"sync"
+)
+
+// supportedVersions are the protocol revisions the server can speak, newest first.
+var supportedVersions = []string{ProtocolVersion, "2025-03-26", "2024-11-05"}
+
+// ToolHandler runs a tool with the arguments chosen by the client. Returned errors are reported to
+// the client as a failed tool result, not as a protocol error.
+type ToolHandler func(ctx context.Context, args json.RawMessage) (*ToolResult, error)
+
+// ServerTool is a tool offered by a Server.
+type ServerTool struct {
+	Tool
+	Handler ToolHandler
+}
+
+// Server answers MCP requests with a fixed set of tools. It keeps no per-session state, so one
+// Server can serve any number of clients.
+type Server struct {
+	info         Implementation
+	instructions string
+	tools        []ServerTool
+}
+
+// NewServer creates a Server that introduces itself as info, with instructions for the client's
+// model.
+func NewServer(info Implementation, instructions string, tools ...ServerTool) *Server {
+	return &Server{info: info, instructions: instructions, tools: tools}
+}
+
+// Handle answers one message. Notifications and responses get no answer and return nil.
+func (s *Server) Handle(ctx context.Context, msg *Message) *Message {
+	if !msg.IsRequest() {
+		return nil
+	}
+	result, err := s.dispatch(ctx, msg)
+	resp := &Message{JSONRPC: "2.0", ID: msg.ID}
+	if err == nil {
+		resp.Result, err = json.Marshal(result)
+	}
+	if err != nil {
+		rpcErr, ok := err.(*Error)
+		if !ok {
+			rpcErr = &Error{Code: CodeInternalError, Message: err.Error()}
+		}
+		resp.Result = nil
+		resp.Error = rpcErr
+	}
+	return resp
+}
+
+func (s *Server) dispatch(ctx context.Context, msg *Message) (any, error) {
+	switch msg.Method {
+	case "initialize":
+		var params InitializeParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, &Error{Code: CodeInvalidParams, Message: "invalid initialize params"}
+		}
+		version := ProtocolVersion
+		if slices.Contains(supportedVersions, params.ProtocolVersion) {
+			version = params.ProtocolVersion
+		}
+		return InitializeResult{
+			ProtocolVersion: version,
+			Capabilities:    ServerCapabilities{Tools: &struct{}{}},
+			ServerInfo:      s.info,
+			Instructions:    s.instructions,
+		}, nil
+	case "ping":
+		return struct{}{}, nil
+	case "tools/list":
+		tools := make([]Tool, 0, len(s.tools))
+		for _, t := range s.tools {
+			tools = append(tools, t.Tool)
+		}
+		return ListToolsResult{Tools: tools}, nil
+	case "tools/call":
+		var params CallToolParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, &Error{Code: CodeInvalidParams, Message: "invalid tools/call params"}
+		}
+		for _, t := range s.tools {
+			if t.Name != params.Name {
+				continue
+			}
+			if len(params.Arguments) == 0 || string(params.Arguments) == "null" {
+				params.Arguments = json.RawMessage("{}")
+			}
+			result, err := t.Handler(ctx, params.Arguments)
+			if err != nil {
+				return TextContent(err.Error(), true), nil
+			}
+			return result, nil
+		}
+		return nil, &Error{Code: CodeInvalidParams, Message: "unknown tool: " + params.Name}
+	default:
+		return nil, &Error{Code: CodeMethodNotFound, Message: "method not supported: " + msg.Method}
+	}
+}
+
+// ServeStdio reads newline-delimited messages from in and writes the answers to out until in ends.
+// Requests are handled concurrently, so a slow tool does not hold up pings.
+func (s *Server) ServeStdio(ctx context.Context, in io.Reader, out io.Writer) error {
+	write := lineWriter(out)
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64<<10), maxStdioMessage)
+	for scanner.Scan() {
+		var msg Message
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			write(&Message{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &Error{Code: CodeParseError, Message: "invalid JSON"}})
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if resp := s.Handle(ctx, &msg); resp != nil {
+				write(resp)
+			}
+		}()
+	}
+	return scanner.Err()
+}
+
+// ServeHTTP implements the streamable HTTP transport without sessions: each POST carries one
+// message and requests are answered with a JSON body. Server-initiated streams are not offered.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "only POST is supported", http.StatusMethodNotAllowed)
+		return
+	}
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+		http.Error(w, "Content-Type must be application/json", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	var msg Message
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxHTTPMessage)).Decode(&msg); err != nil {
+		writeJSON(w, http.StatusBadRequest, &Message{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &Error{Code: CodeParseError, Message: fmt.Sprintf("invalid message: %v", err)}})
+		return
+	}
+	resp := s.Handle(r.Context(), &msg)
+	if resp == nil {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func writeJSON(w http.ResponseWriter, status int, msg *Message) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(msg)
+}
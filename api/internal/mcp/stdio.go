@@ -0,0 +1,210 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+const (
+	// maxStdioMessage is the longest line accepted from a stdio server.
+	maxStdioMessage = 32 << 20
+	// stderrTail is how much of a server's stderr is kept to explain failures.
+	stderrTail = 4 << 10
+)
+
+// DialStdio starts command with args and env (added to the server's own environment) and
+// connects to it over stdin and stdout.
+func DialStdio(ctx context.Context, info Implementation, command string, args []string, env map[string]string) (*Client, error) {
+	cmd := exec.Command(command, args...)
+	cmd.Env = os.Environ()
+	for k, v := range env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	t := &stdioTransport{
+		cmd:     cmd,
+		stdin:   stdin,
+		pending: map[string]chan *Message{},
+		done:    make(chan struct{}),
+	}
+	cmd.Stderr = &t.stderr
+	// Do not wait forever for stderr held open by processes the server started
+	cmd.WaitDelay = time.Second
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start %s: %w", command, err)
+	}
+	go t.readLoop(stdout)
+	return connect(ctx, t, info)
+}
+
+// stdioTransport exchanges newline-delimited JSON messages with a child process.
+type stdioTransport struct {
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	writeMu sync.Mutex
+	stderr  tailBuffer
+
+	mu      sync.Mutex
+	pending map[string]chan *Message
+	done    chan struct{}
+	err     error
+}
+
+func (t *stdioTransport) roundTrip(ctx context.Context, msg *Message) (*Message, error) {
+	var ch chan *Message
+	if len(msg.ID) > 0 {
+		ch = make(chan *Message, 1)
+		t.mu.Lock()
+		if t.err != nil {
+			t.mu.Unlock()
+			return nil, t.err
+		}
+		t.pending[string(msg.ID)] = ch
+		t.mu.Unlock()
+		defer func() {
+			t.mu.Lock()
+			delete(t.pending, string(msg.ID))
+			t.mu.Unlock()
+		}()
+	}
+
+	if err := t.write(msg); err != nil {
+		return nil, err
+	}
+	if ch == nil {
+		return nil, nil
+	}
+	select {
+	case resp := <-ch:
+		return resp, nil
+	case <-t.done:
+		return nil, t.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (t *stdioTransport) write(msg *Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+	if _, err := t.stdin.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write to server: %w", err)
+	}
+	return nil
+}
+
+// readLoop delivers responses to the waiting requests until the server closes its output.
+func (t *stdioTransport) readLoop(stdout io.Reader) {
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64<<10), maxStdioMessage)
+	for scanner.Scan() {
+		var msg Message
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			// Servers sometimes log to stdout; anything that is not a message is skipped
+			continue
+		}
+		switch {
+		case msg.IsRequest():
+			t.answer(&msg)
+		case msg.Method != "":
+			// Notifications such as progress or list changes are not used
+		default:
+			t.mu.Lock()
+			ch := t.pending[string(msg.ID)]
+			t.mu.Unlock()
+			if ch != nil {
+				ch <- &msg
+			}
+		}
+	}
+
+	err := scanner.Err()
+	if err != nil {
+		// The server may be blocked writing the rest of the message
+		_ = t.cmd.Process.Kill()
+	} else {
+		err = errors.New("server closed its output")
+	}
+	_ = t.cmd.Wait()
+	if tail := bytes.TrimSpace(t.stderr.Bytes()); len(tail) > 0 {
+		err = fmt.Errorf("%w: %s", err, tail)
+	}
+	t.mu.Lock()
+	t.err = err
+	t.mu.Unlock()
+	close(t.done)
+}
+
+// answer replies to requests from the server. Only ping is supported.
+func (t *stdioTransport) answer(req *Message) {
+	resp := &Message{JSONRPC: "2.0", ID: req.ID}
+	if req.Method == "ping" {
+		resp.Result = json.RawMessage("{}")
+	} else {
+		resp.Error = &Error{Code: CodeMethodNotFound, Message: "method not supported: " + req.Method}
+	}
+	_ = t.write(resp)
+}
+
+func (t *stdioTransport) alive() bool {
+	select {
+	case <-t.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// close closes the server's input, which asks it to exit, and kills it if it does not.
+func (t *stdioTransport) close() error {
+	_ = t.stdin.Close()
+	select {
+	case <-t.done:
+	case <-time.After(2 * time.Second):
+		_ = t.cmd.Process.Kill()
+		<-t.done
+	}
+	return nil
+}
+
+// tailBuffer keeps the last stderrTail bytes written to it.
+type tailBuffer struct {
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > stderrTail {
+		b.buf = b.buf[len(b.buf)-stderrTail:]
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]byte(nil), b.buf...)
+}
@@ -0,0 +1,66 @@
+package middlewares
+
+import (
+	"net/http"
+	"strings"
+
+	"gemiwin/api/internal/domain"
+	"gemiwin/api/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// userKey is the gin context key holding the authenticated *domain.User.
+const userKey = "user"
+
+// AuthMiddleware requires "Authorization: Bearer <token>" carrying either the per-launch token or
+// a user's API token, and records the user for CurrentUser. Paths in exempt skip the check.
+func AuthMiddleware(users *services.UserService, exempt ...string) gin.HandlerFunc {
+	allowed := make(map[string]bool, len(exempt))
+	for _, path := range exempt {
+		allowed[path] = true
+	}
+
+	return func(c *gin.Context) {
+		if allowed[c.FullPath()] {
+			c.Next()
+			return
+		}
+
+		token, _ := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		user, err := users.Authenticate(strings.TrimSpace(token))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to authenticate"})
+			return
+		}
+		if user == nil {
+			c.Header("WWW-Authenticate", `Bearer realm="gemiwin"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Missing or invalid API token"})
+			return
+		}
+
+		c.Set(userKey, user)
+		c.Next()
+	}
+}
+
+// RequireAdmin rejects requests from users without administrator rights.
+func RequireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if user := CurrentUser(c); user == nil || !user.Admin {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Administrator rights required"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// CurrentUser returns the user authenticated by AuthMiddleware, or nil.
+func CurrentUser(c *gin.Context) *domain.User {
+	if v, ok := c.Get(userKey); ok {
+		if user, ok := v.(*domain.User); ok {
+			return user
+		}
+	}
+	return nil
+}
@@ -7,9 +7,19 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func CORSMiddleware() gin.HandlerFunc {
+// CORSMiddleware allows cross-origin requests from the given origins. "file://" admits pages
+// loaded from disk, such as the packaged Electron app, and "*" allows any origin.
+func CORSMiddleware(origins []string) gin.HandlerFunc {
+	allowFiles := false
+	for _, origin := range origins {
+		if origin == "file://" {
+			allowFiles = true
+		}
+	}
+
 	return cors.New(cors.Config{
-		AllowOrigins:     []string{"*"},
+		AllowOrigins:     origins,
+		AllowFiles:       allowFiles,
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
 		ExposeHeaders:    []string{"Content-Length"},
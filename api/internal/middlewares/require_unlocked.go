@@ -0,0 +1,26 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"gemiwin/api/internal/persistence"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireUnlocked rejects requests with 423 Locked while encrypted data cannot be read. Paths in
+// exempt (such as the unlock endpoint itself) are always let through.
+func RequireUnlocked(vault *persistence.Vault, exempt ...string) gin.HandlerFunc {
+	allowed := make(map[string]bool, len(exempt))
+	for _, path := range exempt {
+		allowed[path] = true
+	}
+
+	return func(c *gin.Context) {
+		if vault.Locked() && !allowed[c.FullPath()] && c.Request.Method != http.MethodOptions {
+			c.AbortWithStatusJSON(http.StatusLocked, gin.H{"error": "Data is encrypted. Unlock it with POST /unlock"})
+			return
+		}
+		c.Next()
+	}
+}
@@ -9,34 +9,41 @@ import (
 	"gemiwin/api/internal/domain"
 )
 
-const appConfigFile = "data/app_config.json"
-
-// AppConfigRepository handles persistence of the global AppConfig.
-type AppConfigRepository struct{}
+// AppConfigRepository handles persistence of each user's AppConfig.
+type AppConfigRepository struct {
+	root  string
+	vault *Vault
+}
 
-// NewAppConfigRepository returns a new instance of AppConfigRepository.
-func NewAppConfigRepository() *AppConfigRepository {
-	return &AppConfigRepository{}
+// NewAppConfigRepository returns a new instance of AppConfigRepository rooted at the data directory.
+// The local user's configuration lives in app_config.json, other users' in configs/<id>.json.
+func NewAppConfigRepository(root string, vault *Vault) *AppConfigRepository {
+	return &AppConfigRepository{root: root, vault: vault}
 }
 
-// Save writes the provided configuration to disk in JSON format.
-func (r *AppConfigRepository) Save(cfg *domain.AppConfig) error {
-	// Ensure the data directory exists
-	if err := os.MkdirAll(filepath.Dir(appConfigFile), 0755); err != nil {
-		return err
+func (r *AppConfigRepository) path(userID string) string {
+	if userID == domain.LocalUserID {
+		return filepath.Join(r.root, "app_config.json")
 	}
+	return filepath.Join(r.root, "configs", userID+".json")
+}
 
+// Save writes the provided configuration for userID to disk in JSON format.
+func (r *AppConfigRepository) Save(userID string, cfg *domain.AppConfig) error {
 	data, err := json.MarshalIndent(cfg, "", "  ")
 	if err != nil {
 		return err
 	}
+	if data, err = r.vault.Encode(data); err != nil {
+		return err
+	}
 
-	return ioutil.WriteFile(appConfigFile, data, 0644)
+	return WriteFile(r.path(userID), data)
 }
 
-// Load retrieves the configuration from disk. If no configuration exists, it returns an empty AppConfig instance.
-func (r *AppConfigRepository) Load() (*domain.AppConfig, error) {
-	data, err := ioutil.ReadFile(appConfigFile)
+// Load retrieves the configuration of userID from disk. If no configuration exists, it returns an empty AppConfig instance.
+func (r *AppConfigRepository) Load(userID string) (*domain.AppConfig, error) {
+	data, err := ioutil.ReadFile(r.path(userID))
 	if err != nil {
 		if os.IsNotExist(err) {
 			// Return default empty configuration when the file doesn't exist
@@ -44,6 +51,9 @@ func (r *AppConfigRepository) Load() (*domain.AppConfig, error) {
 		}
 		return nil, err
 	}
+	if data, err = r.vault.Decode(data); err != nil {
+		return nil, err
+	}
 
 	var cfg domain.AppConfig
 	if err := json.Unmarshal(data, &cfg); err != nil {
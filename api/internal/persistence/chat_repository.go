@@ -2,6 +2,7 @@ package persistence
 
 import (
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -10,14 +11,16 @@ import (
 	"gemiwin/api/internal/domain"
 )
 
-const dataDir = "data/chats"
-
-type ChatRepository struct{}
+type ChatRepository struct {
+	dir   string
+	vault *Vault
+}
 
-func NewChatRepository() *ChatRepository {
+// NewChatRepository stores one JSON file per chat inside dir, encrypted through vault when enabled.
+func NewChatRepository(dir string, vault *Vault) *ChatRepository {
 	// Ensure chat directory exists to avoid errors when reading or writing files.
-	_ = os.MkdirAll(dataDir, 0755)
-	return &ChatRepository{}
+	_ = os.MkdirAll(dir, 0755)
+	return &ChatRepository{dir: dir, vault: vault}
 }
 
 func (r *ChatRepository) Create(chat *domain.Chat) error {
@@ -25,7 +28,7 @@ func (r *ChatRepository) Create(chat *domain.Chat) error {
 }
 
 func (r *ChatRepository) FindByID(id string) (*domain.Chat, error) {
-	filePath := filepath.Join(dataDir, id+".json")
+	filePath := filepath.Join(r.dir, id+".json")
 	data, err := ioutil.ReadFile(filePath)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -33,6 +36,9 @@ func (r *ChatRepository) FindByID(id string) (*domain.Chat, error) {
 		}
 		return nil, err
 	}
+	if data, err = r.vault.Decode(data); err != nil {
+		return nil, err
+	}
 
 	var chat domain.Chat
 	if err := json.Unmarshal(data, &chat); err != nil {
@@ -46,12 +52,22 @@ func (r *ChatRepository) Update(chat *domain.Chat) error {
 }
 
 func (r *ChatRepository) Delete(id string) error {
-	filePath := filepath.Join(dataDir, id+".json")
-	return os.Remove(filePath)
+	filePath := filepath.Join(r.dir, id+".json")
+	return RemoveFile(filePath)
 }
 
 func (r *ChatRepository) FindAll() ([]*domain.Chat, error) {
-	files, err := ioutil.ReadDir(dataDir)
+	return r.findAll(false)
+}
+
+// FindAllStrict is like FindAll but fails on the first chat that cannot be read instead of
+// skipping it. Use it when a missing chat would lead to wrong decisions, such as deleting files.
+func (r *ChatRepository) FindAllStrict() ([]*domain.Chat, error) {
+	return r.findAll(true)
+}
+
+func (r *ChatRepository) findAll(strict bool) ([]*domain.Chat, error) {
+	files, err := ioutil.ReadDir(r.dir)
 	if err != nil {
 		return make([]*domain.Chat, 0), err
 	}
@@ -61,7 +77,9 @@ func (r *ChatRepository) FindAll() ([]*domain.Chat, error) {
 		if !file.IsDir() && filepath.Ext(file.Name()) == ".json" {
 			chat, err := r.FindByID(strings.TrimSuffix(file.Name(), ".json"))
 			if err != nil {
-				// Decide if you want to skip faulty files or return an error
+				if strict {
+					return nil, fmt.Errorf("chat %s: %w", file.Name(), err)
+				}
 				continue
 			}
 			if chat != nil {
@@ -72,12 +90,55 @@ func (r *ChatRepository) FindAll() ([]*domain.Chat, error) {
 	return chats, nil
 }
 
+// Size returns the size of the stored chat in bytes, or 0 if it does not exist.
+func (r *ChatRepository) Size(id string) (int64, error) {
+	info, err := os.Stat(filepath.Join(r.dir, id+".json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// FindByIDForOwner returns the chat with the given id if it belongs to ownerID, or nil otherwise.
+func (r *ChatRepository) FindByIDForOwner(id string, ownerID string) (*domain.Chat, error) {
+	if strings.ContainsAny(id, `/\`) || strings.Contains(id, "..") {
+		return nil, nil
+	}
+	chat, err := r.FindByID(id)
+	if err != nil || chat == nil || chat.Owner() != ownerID {
+		return nil, err
+	}
+	return chat, nil
+}
+
+// FindAllByOwner returns the chats that belong to ownerID.
+func (r *ChatRepository) FindAllByOwner(ownerID string) ([]*domain.Chat, error) {
+	chats, err := r.FindAll()
+	if err != nil {
+		return chats, err
+	}
+	owned := make([]*domain.Chat, 0, len(chats))
+	for _, chat := range chats {
+		if chat.Owner() == ownerID {
+			owned = append(owned, chat)
+		}
+	}
+	return owned, nil
+}
+
 func (r *ChatRepository) save(chat *domain.Chat) error {
 	data, err := json.MarshalIndent(chat, "", "  ")
 	if err != nil {
 		return err
 	}
 
-	filePath := filepath.Join(dataDir, chat.ID+".json")
-	return ioutil.WriteFile(filePath, data, 0644)
+	if data, err = r.vault.Encode(data); err != nil {
+		return err
+	}
+
+	filePath := filepath.Join(r.dir, chat.ID+".json")
+	return WriteFile(filePath, data)
 }
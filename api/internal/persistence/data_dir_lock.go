@@ -0,0 +1,45 @@
+package persistence
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// DataDirLockFile is held by the process using a data directory.
+const DataDirLockFile = "gemiwin.lock"
+
+// ErrDataDirInUse is returned when another process holds the lock of a data directory.
+var ErrDataDirInUse = errors.New("the data directory is in use by another gemiwin process")
+
+// DataDirLock keeps other processes from using a data directory; storageLock only coordinates
+// the goroutines of one process. The operating system releases it when the process exits.
+type DataDirLock struct {
+	f *os.File
+}
+
+// LockDataDir takes the lock of the data directory dir without waiting. ErrDataDirInUse is
+// returned if another process holds it.
+func LockDataDir(dir string) (*DataDirLock, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, DataDirLockFile), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(f); err != nil {
+		f.Close()
+		if errors.Is(err, errLockHeld) {
+			return nil, fmt.Errorf("%w: %s", ErrDataDirInUse, dir)
+		}
+		return nil, err
+	}
+	return &DataDirLock{f: f}, nil
+}
+
+// Unlock releases the lock.
+func (l *DataDirLock) Unlock() error {
+	return l.f.Close()
+}
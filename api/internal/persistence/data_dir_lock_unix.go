@@ -0,0 +1,19 @@
+//go:build !windows
+
+package persistence
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+var errLockHeld = syscall.EWOULDBLOCK
+
+func lockFile(f *os.File) error {
+	err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		return errLockHeld
+	}
+	return err
+}
@@ -0,0 +1,116 @@
+package persistence
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gemiwin/api/internal/domain"
+)
+
+// DocumentRepository stores one JSON file per library document inside dir, encrypted through the
+// vault when enabled.
+type DocumentRepository struct {
+	dir   string
+	vault *Vault
+}
+
+// NewDocumentRepository stores library documents inside dir.
+func NewDocumentRepository(dir string, vault *Vault) *DocumentRepository {
+	_ = os.MkdirAll(dir, 0755)
+	return &DocumentRepository{dir: dir, vault: vault}
+}
+
+// Save creates or replaces the document with doc.ID.
+func (r *DocumentRepository) Save(doc *domain.LibraryDocument) error {
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	if data, err = r.vault.Encode(data); err != nil {
+		return err
+	}
+	return WriteFile(filepath.Join(r.dir, doc.ID+".json"), data)
+}
+
+// FindByID returns the document with the given id, or nil if it does not exist.
+func (r *DocumentRepository) FindByID(id string) (*domain.LibraryDocument, error) {
+	if id == "" || strings.ContainsAny(id, `/\`) || strings.Contains(id, "..") {
+		return nil, nil
+	}
+	data, err := os.ReadFile(filepath.Join(r.dir, id+".json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if data, err = r.vault.Decode(data); err != nil {
+		return nil, err
+	}
+
+	var doc domain.LibraryDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+// FindByIDForOwner returns the document with the given id if it belongs to ownerID, or nil.
+func (r *DocumentRepository) FindByIDForOwner(id string, ownerID string) (*domain.LibraryDocument, error) {
+	doc, err := r.FindByID(id)
+	if err != nil || doc == nil || doc.OwnerID != ownerID {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// FindAll returns every document, failing on the first one that cannot be read so that no file
+// is ever treated as unreferenced because its library entry was unreadable.
+func (r *DocumentRepository) FindAll() ([]*domain.LibraryDocument, error) {
+	entries, err := os.ReadDir(r.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []*domain.LibraryDocument{}, nil
+		}
+		return nil, err
+	}
+
+	docs := make([]*domain.LibraryDocument, 0, len(entries))
+	for _, e := range entries {
+		name := e.Name()
+		if !e.Type().IsRegular() || strings.HasPrefix(name, ".") || filepath.Ext(name) != ".json" {
+			continue
+		}
+		doc, err := r.FindByID(strings.TrimSuffix(name, ".json"))
+		if err != nil {
+			return nil, fmt.Errorf("document %s: %w", name, err)
+		}
+		if doc != nil {
+			docs = append(docs, doc)
+		}
+	}
+	return docs, nil
+}
+
+// FindAllByOwner returns the documents that belong to ownerID.
+func (r *DocumentRepository) FindAllByOwner(ownerID string) ([]*domain.LibraryDocument, error) {
+	docs, err := r.FindAll()
+	if err != nil {
+		return nil, err
+	}
+	owned := make([]*domain.LibraryDocument, 0, len(docs))
+	for _, doc := range docs {
+		if doc.OwnerID == ownerID {
+			owned = append(owned, doc)
+		}
+	}
+	return owned, nil
+}
+
+// Delete removes the document with the given id.
+func (r *DocumentRepository) Delete(id string) error {
+	return RemoveFile(filepath.Join(r.dir, id+".json"))
+}
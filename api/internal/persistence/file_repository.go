@@ -0,0 +1,127 @@
+package persistence
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrInvalidFileName is returned for names that would escape the files directory.
+var ErrInvalidFileName = errors.New("invalid file name")
+
+// FileRepository stores uploaded documents, encrypted through the vault when enabled.
+type FileRepository struct {
+	dir   string
+	vault *Vault
+}
+
+// NewFileRepository stores files inside dir.
+func NewFileRepository(dir string, vault *Vault) *FileRepository {
+	return &FileRepository{dir: dir, vault: vault}
+}
+
+// Save writes data under name, replacing any existing file.
+func (r *FileRepository) Save(name string, data []byte) error {
+	filePath, err := r.path(name)
+	if err != nil {
+		return err
+	}
+	if data, err = r.vault.Encode(data); err != nil {
+		return err
+	}
+	return WriteFile(filePath, data)
+}
+
+// Read returns the contents of the named file, or nil if it does not exist.
+func (r *FileRepository) Read(name string) ([]byte, error) {
+	filePath, err := r.path(name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return r.vault.Decode(data)
+}
+
+// Exists reports whether a file with the given name is stored.
+func (r *FileRepository) Exists(name string) bool {
+	filePath, err := r.path(name)
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(filePath)
+	return err == nil
+}
+
+// FileInfo describes a stored file.
+type FileInfo struct {
+	Name    string
+	Size    int64
+	ModTime time.Time
+}
+
+// Stat returns information about the named file, or nil if it does not exist.
+func (r *FileRepository) Stat(name string) (*FileInfo, error) {
+	filePath, err := r.path(name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &FileInfo{Name: name, Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+// List returns every stored file.
+func (r *FileRepository) List() ([]FileInfo, error) {
+	entries, err := os.ReadDir(r.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	files := make([]FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		// Skip directories and temporary files left behind by interrupted writes
+		if !entry.Type().IsRegular() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, FileInfo{Name: entry.Name(), Size: info.Size(), ModTime: info.ModTime()})
+	}
+	return files, nil
+}
+
+// Delete removes the named file. Deleting a file that does not exist is not an error.
+func (r *FileRepository) Delete(name string) error {
+	filePath, err := r.path(name)
+	if err != nil {
+		return err
+	}
+	if err := RemoveFile(filePath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (r *FileRepository) path(name string) (string, error) {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return "", ErrInvalidFileName
+	}
+	return filepath.Join(r.dir, name), nil
+}
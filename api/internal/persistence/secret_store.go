@@ -0,0 +1,300 @@
+package persistence
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"gemiwin/api/internal/domain"
+)
+
+// SecretKeyEnv optionally holds the base64-encoded 32-byte key used to encrypt secrets. When it is
+// not set a random key is generated and stored in the key file.
+const SecretKeyEnv = "GEMIWIN_SECRET_KEY"
+
+// ErrWrongSecretKey is returned when secrets were encrypted with another key than the current one.
+var ErrWrongSecretKey = errors.New("secrets were encrypted with a different key")
+
+// Names of the secrets kept in the SecretStore.
+const SecretGeminiApiKey = "gemini_api_key"
+
+// UserSecret returns the name under which userID's copy of the named secret is stored. The local
+// user keeps the plain name so secrets stored by older versions still apply.
+func UserSecret(name string, userID string) string {
+	if userID == "" || userID == domain.LocalUserID {
+		return name
+	}
+	return name + ":" + userID
+}
+
+// SecretStore keeps secrets such as API keys in an AES-256-GCM encrypted file, separate from the
+// plain configuration so they are never written to disk in clear text. The key is kept outside the
+// data directory, and when the data is encrypted the file is also encrypted by the vault, so a copy
+// of the data directory alone does not reveal the secrets.
+type SecretStore struct {
+	path    string
+	keyPath string
+	vault   *Vault
+	mu      sync.Mutex
+}
+
+// NewSecretStore stores encrypted secrets at path, using the key at keyPath unless GEMIWIN_SECRET_KEY is set.
+func NewSecretStore(path string, keyPath string, vault *Vault) *SecretStore {
+	return &SecretStore{path: path, keyPath: keyPath, vault: vault}
+}
+
+// Kind describes where secrets are stored, for display to clients.
+func (s *SecretStore) Kind() string {
+	return "encrypted_file"
+}
+
+// Get returns the named secret, or an empty string if it is not set.
+func (s *SecretStore) Get(name string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	secrets, err := s.load()
+	if err != nil {
+		return "", err
+	}
+	return secrets[name], nil
+}
+
+// Set stores the named secret, replacing any previous value.
+func (s *SecretStore) Set(name string, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	secrets, err := s.load()
+	if err != nil {
+		return err
+	}
+	secrets[name] = value
+	return s.save(secrets)
+}
+
+// Delete removes the named secret.
+func (s *SecretStore) Delete(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	secrets, err := s.load()
+	if err != nil {
+		return err
+	}
+	delete(secrets, name)
+	return s.save(secrets)
+}
+
+func (s *SecretStore) load() (map[string]string, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return map[string]string{}, nil
+		}
+		return nil, err
+	}
+
+	key, err := s.key(false)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := s.open(key, data)
+	if err != nil {
+		return nil, err
+	}
+
+	secrets := map[string]string{}
+	if err := json.Unmarshal(plain, &secrets); err != nil {
+		return nil, err
+	}
+	return secrets, nil
+}
+
+func (s *SecretStore) save(secrets map[string]string) error {
+	plain, err := json.Marshal(secrets)
+	if err != nil {
+		return err
+	}
+	key, err := s.key(true)
+	if err != nil {
+		return err
+	}
+	return s.write(key, plain)
+}
+
+// open decrypts the contents of the secrets file with key.
+func (s *SecretStore) open(key []byte, data []byte) ([]byte, error) {
+	data, err := s.vault.Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := openSealed(key, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt secrets: %w", ErrWrongSecretKey)
+	}
+	return plain, nil
+}
+
+// write encrypts plain with key, and with the vault when it is enabled, into the secrets file.
+func (s *SecretStore) write(key []byte, plain []byte) error {
+	sealed, err := seal(key, plain)
+	if err != nil {
+		return err
+	}
+	encoded, err := s.vault.Encode(sealed)
+	if err != nil {
+		return err
+	}
+	return WritePrivateFile(s.path, encoded)
+}
+
+// Check reports whether data, the contents of a secrets file, can be decrypted with the current
+// key. It returns ErrWrongSecretKey if it cannot, including when there is no key yet, and a vault
+// error if the vault encryption around it cannot be removed to find out.
+func (s *SecretStore) Check(data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, err := s.key(false)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("%w: there is no secret key at %s", ErrWrongSecretKey, s.keyPath)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = s.open(key, data)
+	return err
+}
+
+// MigrateKey moves the key that older versions kept at legacyPath, in the data directory, to the
+// key file. If the key file already holds another key, the secrets are re-encrypted with that one.
+func (s *SecretStore) MigrateKey(legacyPath string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if legacyPath == s.keyPath || os.Getenv(SecretKeyEnv) != "" {
+		return nil
+	}
+	legacy, err := os.ReadFile(legacyPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if len(legacy) != 32 {
+		return fmt.Errorf("secret key %s is corrupt", legacyPath)
+	}
+
+	key, err := os.ReadFile(s.keyPath)
+	switch {
+	case os.IsNotExist(err):
+		if err := WritePrivateFile(s.keyPath, legacy); err != nil {
+			return err
+		}
+	case err != nil:
+		return err
+	case !bytes.Equal(key, legacy):
+		if err := s.reseal(legacy, key); err != nil {
+			return err
+		}
+	}
+	return RemoveFile(legacyPath)
+}
+
+// reseal re-encrypts the secrets file from oldKey to newKey, unless it already uses newKey.
+func (s *SecretStore) reseal(oldKey []byte, newKey []byte) error {
+	if len(newKey) != 32 {
+		return fmt.Errorf("secret key %s is corrupt", s.keyPath)
+	}
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if _, err := s.open(newKey, data); err == nil {
+		return nil
+	}
+	plain, err := s.open(oldKey, data)
+	if err != nil {
+		return err
+	}
+	return s.write(newKey, plain)
+}
+
+// key returns the encryption key, generating and persisting one when create is set and none exists.
+func (s *SecretStore) key(create bool) ([]byte, error) {
+	if encoded := os.Getenv(SecretKeyEnv); encoded != "" {
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil || len(key) != 32 {
+			return nil, errors.New(SecretKeyEnv + " must be a base64-encoded 32-byte key")
+		}
+		return key, nil
+	}
+
+	key, err := os.ReadFile(s.keyPath)
+	if err == nil {
+		if len(key) != 32 {
+			return nil, fmt.Errorf("secret key %s is corrupt", s.keyPath)
+		}
+		return key, nil
+	}
+	if !os.IsNotExist(err) || !create {
+		return nil, err
+	}
+
+	key = make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, err
+	}
+	if err := WritePrivateFile(s.keyPath, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// seal encrypts plain with AES-GCM and prepends the random nonce.
+func seal(key []byte, plain []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plain, nil), nil
+}
+
+// openSealed reverses seal.
+func openSealed(key []byte, data []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, ciphertext := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, nil)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
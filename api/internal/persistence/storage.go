@@ -0,0 +1,126 @@
+package persistence
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// storageLock coordinates individual writes (shared) with whole-directory operations such as
+// backups and restores (exclusive), so a snapshot never observes a half-finished change.
+var storageLock sync.RWMutex
+
+// WriteFile atomically replaces the file at path with data, creating parent directories as needed.
+func WriteFile(path string, data []byte) error {
+	storageLock.RLock()
+	defer storageLock.RUnlock()
+	return writeFileAtomic(path, data, 0644)
+}
+
+// WritePrivateFile is like WriteFile but makes the file readable by the current user only.
+func WritePrivateFile(path string, data []byte) error {
+	storageLock.RLock()
+	defer storageLock.RUnlock()
+	return writeFileAtomic(path, data, 0600)
+}
+
+// RemoveFile deletes the file at path.
+func RemoveFile(path string) error {
+	storageLock.RLock()
+	defer storageLock.RUnlock()
+	return os.Remove(path)
+}
+
+// Tx performs writes while the storage lock is held exclusively.
+type Tx struct{}
+
+// WriteFile atomically replaces the file at path with data.
+func (Tx) WriteFile(path string, data []byte) error {
+	return writeFileAtomic(path, data, 0644)
+}
+
+// WritePrivateFile is like WriteFile but makes the file readable by the current user only.
+func (Tx) WritePrivateFile(path string, data []byte) error {
+	return writeFileAtomic(path, data, 0600)
+}
+
+// RemoveFile deletes the file at path.
+func (Tx) RemoveFile(path string) error {
+	return os.Remove(path)
+}
+
+// Exclusive runs fn while no other write can take place. Writes inside fn must go through tx;
+// calling the package-level WriteFile or any repository method from fn would deadlock.
+func Exclusive(fn func(tx Tx) error) error {
+	storageLock.Lock()
+	defer storageLock.Unlock()
+	return fn(Tx{})
+}
+
+// ListDataFiles returns the chats, library documents, uploaded files, workspace indexes, usage
+// ledgers and app configurations stored under root as slash-separated paths relative to root, e.g.
+// "chats/<id>.json" or "files/<name>".
+func ListDataFiles(root string) ([]string, error) {
+	var paths []string
+	for _, dir := range []string{"chats", "configs", "documents", "files", "usage", "workspaces"} {
+		entries, err := os.ReadDir(filepath.Join(root, dir))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		for _, e := range entries {
+			name := e.Name()
+			// Skip temporary files left by writeFileAtomic and other hidden files
+			if !e.Type().IsRegular() || strings.HasPrefix(name, ".") {
+				continue
+			}
+			if dir != "files" && filepath.Ext(name) != ".json" {
+				continue
+			}
+			paths = append(paths, dir+"/"+name)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(root, "app_config.json")); err == nil {
+		paths = append(paths, "app_config.json")
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// writeFileAtomic writes data to a temporary file in the same directory and renames it into place.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpName)
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmpName)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	if err := os.Chmod(tmpName, perm); err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	return os.Rename(tmpName, path)
+}
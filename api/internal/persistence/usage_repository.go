@@ -0,0 +1,164 @@
+package persistence
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gemiwin/api/internal/domain"
+)
+
+// usageMonthLayout names the monthly files of the usage ledger.
+const usageMonthLayout = "2006-01"
+
+// UsageRepository keeps each user's usage ledger, one JSON file per user and month inside dir
+// named <user id>_<yyyy-mm>.json, encrypted through the vault when enabled. Records are only ever
+// appended; deleting chats leaves them in place.
+type UsageRepository struct {
+	dir   string
+	vault *Vault
+	// mu serializes appends, which read and rewrite a month's file
+	mu sync.Mutex
+}
+
+// NewUsageRepository stores usage ledgers inside dir.
+func NewUsageRepository(dir string, vault *Vault) *UsageRepository {
+	_ = os.MkdirAll(dir, 0755)
+	return &UsageRepository{dir: dir, vault: vault}
+}
+
+// Append adds records to the ledger of userID, in the months of their timestamps.
+func (r *UsageRepository) Append(userID string, records ...domain.UsageRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+	if err := checkLedgerOwner(userID); err != nil {
+		return err
+	}
+	byMonth := map[string][]domain.UsageRecord{}
+	for _, record := range records {
+		month := record.Timestamp.Local().Format(usageMonthLayout)
+		byMonth[month] = append(byMonth[month], record)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for month, added := range byMonth {
+		path := r.path(userID, month)
+		existing, err := r.read(path)
+		if err != nil {
+			return err
+		}
+		data, err := json.MarshalIndent(append(existing, added...), "", "  ")
+		if err != nil {
+			return err
+		}
+		if data, err = r.vault.Encode(data); err != nil {
+			return err
+		}
+		if err := WriteFile(path, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FindByOwner returns the records of userID with timestamps in [from, to), oldest first. Zero
+// bounds do not filter.
+func (r *UsageRepository) FindByOwner(userID string, from time.Time, to time.Time) ([]domain.UsageRecord, error) {
+	if err := checkLedgerOwner(userID); err != nil {
+		return nil, err
+	}
+	months, err := r.months(userID)
+	if err != nil {
+		return nil, err
+	}
+	records := []domain.UsageRecord{}
+	for _, month := range months {
+		start, err := time.ParseInLocation(usageMonthLayout, month, time.Local)
+		if err != nil {
+			continue
+		}
+		// Skip months entirely outside the range without reading them
+		if (!to.IsZero() && !start.Before(to)) || (!from.IsZero() && !start.AddDate(0, 1, 0).After(from)) {
+			continue
+		}
+		stored, err := r.read(r.path(userID, month))
+		if err != nil {
+			return nil, err
+		}
+		for _, record := range stored {
+			if (!from.IsZero() && record.Timestamp.Before(from)) || (!to.IsZero() && !record.Timestamp.Before(to)) {
+				continue
+			}
+			records = append(records, record)
+		}
+	}
+	sort.SliceStable(records, func(i, j int) bool { return records[i].Timestamp.Before(records[j].Timestamp) })
+	return records, nil
+}
+
+// HasOwner reports whether userID has a ledger at all.
+func (r *UsageRepository) HasOwner(userID string) (bool, error) {
+	if err := checkLedgerOwner(userID); err != nil {
+		return false, err
+	}
+	months, err := r.months(userID)
+	return len(months) > 0, err
+}
+
+// months lists the months userID has records in, oldest first.
+func (r *UsageRepository) months(userID string) ([]string, error) {
+	entries, err := os.ReadDir(r.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	prefix := userID + "_"
+	var months []string
+	for _, e := range entries {
+		name := e.Name()
+		if e.Type().IsRegular() && strings.HasPrefix(name, prefix) && strings.HasSuffix(name, ".json") {
+			months = append(months, strings.TrimSuffix(strings.TrimPrefix(name, prefix), ".json"))
+		}
+	}
+	sort.Strings(months)
+	return months, nil
+}
+
+func (r *UsageRepository) path(userID string, month string) string {
+	return filepath.Join(r.dir, userID+"_"+month+".json")
+}
+
+func (r *UsageRepository) read(path string) ([]domain.UsageRecord, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if data, err = r.vault.Decode(data); err != nil {
+		return nil, err
+	}
+	var records []domain.UsageRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("failed to read usage ledger %s: %w", filepath.Base(path), err)
+	}
+	return records, nil
+}
+
+// checkLedgerOwner refuses user ids that could name another user's files or leave dir.
+func checkLedgerOwner(userID string) error {
+	if userID == "" || strings.ContainsAny(userID, `/\_`) || strings.Contains(userID, "..") {
+		return fmt.Errorf("invalid user id %q", userID)
+	}
+	return nil
+}
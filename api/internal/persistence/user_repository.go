@@ -0,0 +1,168 @@
+package persistence
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+
+	"gemiwin/api/internal/domain"
+)
+
+// UserRepository keeps all accounts in a single JSON file. It is not encrypted by the vault so
+// users can still authenticate while encrypted data is locked; it only holds hashes.
+type UserRepository struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewUserRepository stores accounts at path.
+func NewUserRepository(path string) *UserRepository {
+	return &UserRepository{path: path}
+}
+
+// FindAll returns every account.
+func (r *UserRepository) FindAll() ([]*domain.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.load()
+}
+
+// FindByID returns the account with the given id, or nil if there is none.
+func (r *UserRepository) FindByID(id string) (*domain.User, error) {
+	return r.find(func(u *domain.User) bool { return u.ID == id })
+}
+
+// FindByUsername returns the account with the given username, or nil if there is none.
+func (r *UserRepository) FindByUsername(username string) (*domain.User, error) {
+	return r.find(func(u *domain.User) bool { return u.Username == username })
+}
+
+// FindByTokenHash returns the account owning the API token with the given hash, or nil.
+func (r *UserRepository) FindByTokenHash(hash string) (*domain.User, error) {
+	return r.find(func(u *domain.User) bool {
+		for _, t := range u.Tokens {
+			if t.Hash == hash {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// Save creates or replaces the account with user.ID.
+func (r *UserRepository) Save(user *domain.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	users, err := r.load()
+	if err != nil {
+		return err
+	}
+	replaced := false
+	for i, u := range users {
+		if u.ID == user.ID {
+			users[i] = user
+			replaced = true
+		}
+	}
+	if !replaced {
+		users = append(users, user)
+	}
+	return r.store(users)
+}
+
+// Create adds user unless an account already has its username, which it reports as false.
+func (r *UserRepository) Create(user *domain.User) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	users, err := r.load()
+	if err != nil {
+		return false, err
+	}
+	for _, u := range users {
+		if u.Username == user.Username {
+			return false, nil
+		}
+	}
+	return true, r.store(append(users, user))
+}
+
+// Update applies fn to the account with the given id and saves the result, holding the lock in
+// between so concurrent changes are not lost. Nothing is saved if fn fails. It reports false if
+// there is no such account.
+func (r *UserRepository) Update(id string, fn func(*domain.User) error) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	users, err := r.load()
+	if err != nil {
+		return false, err
+	}
+	for _, u := range users {
+		if u.ID == id {
+			if err := fn(u); err != nil {
+				return true, err
+			}
+			return true, r.store(users)
+		}
+	}
+	return false, nil
+}
+
+// Delete removes the account with the given id.
+func (r *UserRepository) Delete(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	users, err := r.load()
+	if err != nil {
+		return err
+	}
+	kept := users[:0]
+	for _, u := range users {
+		if u.ID != id {
+			kept = append(kept, u)
+		}
+	}
+	return r.store(kept)
+}
+
+func (r *UserRepository) find(match func(*domain.User) bool) (*domain.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	users, err := r.load()
+	if err != nil {
+		return nil, err
+	}
+	for _, u := range users {
+		if match(u) {
+			return u, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *UserRepository) load() ([]*domain.User, error) {
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []*domain.User{}, nil
+		}
+		return nil, err
+	}
+	var users []*domain.User
+	if err := json.Unmarshal(data, &users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (r *UserRepository) store(users []*domain.User) error {
+	data, err := json.MarshalIndent(users, "", "  ")
+	if err != nil {
+		return err
+	}
+	return WritePrivateFile(r.path, data)
+}
@@ -0,0 +1,276 @@
+package persistence
+
+import (
+	"bytes"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"sync"
+)
+
+// Errors returned by the Vault.
+var (
+	ErrVaultLocked       = errors.New("data is encrypted and locked")
+	ErrWrongPassphrase   = errors.New("wrong passphrase")
+	ErrEncryptionEnabled = errors.New("encryption is already enabled")
+	ErrEncryptionOff     = errors.New("encryption is not enabled")
+)
+
+// encryptedMagic prefixes every file written by the vault, so plaintext files left from before
+// encryption was enabled can still be read.
+var encryptedMagic = []byte("GMWENC1\x00")
+
+const (
+	vaultKDF        = "pbkdf2-sha256"
+	vaultIterations = 600000
+	vaultCheck      = "gemiwin-vault-check"
+)
+
+// IsEncrypted reports whether data was written by the vault with encryption enabled.
+func IsEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, encryptedMagic)
+}
+
+// vaultMeta is persisted (unencrypted) next to the data and holds what is needed to derive and
+// verify the key from a passphrase. It never contains the key itself.
+type vaultMeta struct {
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Check      []byte `json:"check"`
+}
+
+// Vault encrypts data at rest with an AES-256-GCM key derived from a passphrase. When encryption
+// is not enabled it passes data through unchanged.
+type Vault struct {
+	metaPath string
+	mu       sync.RWMutex
+	meta     *vaultMeta
+	key      []byte
+}
+
+// NewVault loads the encryption settings stored at metaPath, if any. The vault starts locked.
+func NewVault(metaPath string) (*Vault, error) {
+	v := &Vault{metaPath: metaPath}
+	if err := v.Reload(); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// Reload re-reads the encryption settings from disk, e.g. after a restore. The vault stays
+// unlocked only if the current key still matches.
+func (v *Vault) Reload() error {
+	meta, err := readVaultMeta(v.metaPath)
+	if err != nil {
+		return err
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.meta = meta
+	if meta == nil || v.key == nil || !checkKey(meta, v.key) {
+		v.key = nil
+	}
+	return nil
+}
+
+// Enabled reports whether data is encrypted at rest.
+func (v *Vault) Enabled() bool {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.meta != nil
+}
+
+// Locked reports whether encryption is enabled but no key has been provided yet.
+func (v *Vault) Locked() bool {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.meta != nil && v.key == nil
+}
+
+// Unlock derives the key from passphrase and keeps it in memory.
+func (v *Vault) Unlock(passphrase string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.meta == nil {
+		return ErrEncryptionOff
+	}
+	key, err := deriveKey(v.meta, passphrase)
+	if err != nil {
+		return err
+	}
+	if !checkKey(v.meta, key) {
+		return ErrWrongPassphrase
+	}
+	v.key = key
+	return nil
+}
+
+// Encode prepares data to be written to disk, encrypting it when encryption is enabled.
+func (v *Vault) Encode(data []byte) ([]byte, error) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	if v.meta == nil {
+		return data, nil
+	}
+	if v.key == nil {
+		return nil, ErrVaultLocked
+	}
+	return encodeWithKey(v.key, data)
+}
+
+// Decode reverses Encode. Plaintext data is returned unchanged.
+func (v *Vault) Decode(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, encryptedMagic) {
+		return data, nil
+	}
+
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	if v.key == nil {
+		return nil, ErrVaultLocked
+	}
+	return decodeWithKey(v.key, data)
+}
+
+// Rekey is a pending key change produced by Vault.Enable or Vault.Rotate. Re-encrypt every file
+// with Reencode, then call Commit to persist the new settings and switch to the new key.
+type Rekey struct {
+	vault  *Vault
+	oldKey []byte
+	newKey []byte
+	meta   *vaultMeta
+}
+
+// Enable prepares encryption with a key derived from passphrase.
+func (v *Vault) Enable(passphrase string) (*Rekey, error) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	if v.meta != nil {
+		return nil, ErrEncryptionEnabled
+	}
+	return v.newRekey(nil, passphrase)
+}
+
+// Rotate prepares a switch from the key derived from oldPassphrase to one derived from newPassphrase.
+func (v *Vault) Rotate(oldPassphrase string, newPassphrase string) (*Rekey, error) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	if v.meta == nil {
+		return nil, ErrEncryptionOff
+	}
+	oldKey, err := deriveKey(v.meta, oldPassphrase)
+	if err != nil {
+		return nil, err
+	}
+	if !checkKey(v.meta, oldKey) {
+		return nil, ErrWrongPassphrase
+	}
+	return v.newRekey(oldKey, newPassphrase)
+}
+
+func (v *Vault) newRekey(oldKey []byte, passphrase string) (*Rekey, error) {
+	if passphrase == "" {
+		return nil, errors.New("passphrase must not be empty")
+	}
+
+	salt := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+	meta := &vaultMeta{Version: 1, KDF: vaultKDF, Iterations: vaultIterations, Salt: salt}
+	key, err := deriveKey(meta, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	if meta.Check, err = seal(key, []byte(vaultCheck)); err != nil {
+		return nil, err
+	}
+	return &Rekey{vault: v, oldKey: oldKey, newKey: key, meta: meta}, nil
+}
+
+// Reencode converts data written under the old key (or plaintext) to the new key.
+func (r *Rekey) Reencode(data []byte) ([]byte, error) {
+	if bytes.HasPrefix(data, encryptedMagic) {
+		if r.oldKey == nil {
+			return nil, ErrWrongPassphrase
+		}
+		plain, err := decodeWithKey(r.oldKey, data)
+		if err != nil {
+			return nil, err
+		}
+		data = plain
+	}
+	return encodeWithKey(r.newKey, data)
+}
+
+// Commit persists the new encryption settings and unlocks the vault with the new key.
+// It must run inside Exclusive, using tx for the write.
+func (r *Rekey) Commit(tx Tx) error {
+	data, err := json.MarshalIndent(r.meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := tx.WriteFile(r.vault.metaPath, data); err != nil {
+		return err
+	}
+
+	r.vault.mu.Lock()
+	defer r.vault.mu.Unlock()
+	r.vault.meta = r.meta
+	r.vault.key = r.newKey
+	return nil
+}
+
+func readVaultMeta(path string) (*vaultMeta, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var meta vaultMeta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, err
+	}
+	if meta.KDF != vaultKDF || meta.Iterations <= 0 || len(meta.Salt) == 0 {
+		return nil, errors.New("unsupported encryption settings in " + path)
+	}
+	return &meta, nil
+}
+
+func deriveKey(meta *vaultMeta, passphrase string) ([]byte, error) {
+	return pbkdf2.Key(sha256.New, passphrase, meta.Salt, meta.Iterations, 32)
+}
+
+func checkKey(meta *vaultMeta, key []byte) bool {
+	plain, err := openSealed(key, meta.Check)
+	return err == nil && string(plain) == vaultCheck
+}
+
+func encodeWithKey(key []byte, data []byte) ([]byte, error) {
+	sealed, err := seal(key, data)
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]byte{}, encryptedMagic...), sealed...), nil
+}
+
+func decodeWithKey(key []byte, data []byte) ([]byte, error) {
+	plain, err := openSealed(key, data[len(encryptedMagic):])
+	if err != nil {
+		return nil, errors.New("failed to decrypt data: " + err.Error())
+	}
+	return plain, nil
+}
@@ -0,0 +1,115 @@
+package persistence
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gemiwin/api/internal/domain"
+)
+
+// WorkspaceRepository stores one JSON file per workspace index inside dir, encrypted through the
+// vault when enabled.
+type WorkspaceRepository struct {
+	dir   string
+	vault *Vault
+}
+
+// NewWorkspaceRepository stores workspaces inside dir.
+func NewWorkspaceRepository(dir string, vault *Vault) *WorkspaceRepository {
+	_ = os.MkdirAll(dir, 0755)
+	return &WorkspaceRepository{dir: dir, vault: vault}
+}
+
+// Save creates or replaces the workspace with ws.ID.
+func (r *WorkspaceRepository) Save(ws *domain.Workspace) error {
+	data, err := json.Marshal(ws)
+	if err != nil {
+		return err
+	}
+	if data, err = r.vault.Encode(data); err != nil {
+		return err
+	}
+	return WriteFile(filepath.Join(r.dir, ws.ID+".json"), data)
+}
+
+// FindByID returns the workspace with the given id, or nil if it does not exist.
+func (r *WorkspaceRepository) FindByID(id string) (*domain.Workspace, error) {
+	if id == "" || strings.ContainsAny(id, `/\`) || strings.Contains(id, "..") {
+		return nil, nil
+	}
+	data, err := os.ReadFile(filepath.Join(r.dir, id+".json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if data, err = r.vault.Decode(data); err != nil {
+		return nil, err
+	}
+
+	var ws domain.Workspace
+	if err := json.Unmarshal(data, &ws); err != nil {
+		return nil, err
+	}
+	return &ws, nil
+}
+
+// FindByIDForOwner returns the workspace with the given id if it belongs to ownerID, or nil.
+func (r *WorkspaceRepository) FindByIDForOwner(id string, ownerID string) (*domain.Workspace, error) {
+	ws, err := r.FindByID(id)
+	if err != nil || ws == nil || ws.OwnerID != ownerID {
+		return nil, err
+	}
+	return ws, nil
+}
+
+// FindAll returns every workspace.
+func (r *WorkspaceRepository) FindAll() ([]*domain.Workspace, error) {
+	entries, err := os.ReadDir(r.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []*domain.Workspace{}, nil
+		}
+		return nil, err
+	}
+
+	workspaces := make([]*domain.Workspace, 0, len(entries))
+	for _, e := range entries {
+		name := e.Name()
+		if !e.Type().IsRegular() || strings.HasPrefix(name, ".") || filepath.Ext(name) != ".json" {
+			continue
+		}
+		ws, err := r.FindByID(strings.TrimSuffix(name, ".json"))
+		if err != nil {
+			return nil, fmt.Errorf("workspace %s: %w", name, err)
+		}
+		if ws != nil {
+			workspaces = append(workspaces, ws)
+		}
+	}
+	return workspaces, nil
+}
+
+// FindAllByOwner returns the workspaces that belong to ownerID.
+func (r *WorkspaceRepository) FindAllByOwner(ownerID string) ([]*domain.Workspace, error) {
+	workspaces, err := r.FindAll()
+	if err != nil {
+		return nil, err
+	}
+	owned := make([]*domain.Workspace, 0, len(workspaces))
+	for _, ws := range workspaces {
+		if ws.OwnerID == ownerID {
+			owned = append(owned, ws)
+		}
+	}
+	return owned, nil
+}
+
+// Delete removes the workspace with the given id.
+func (r *WorkspaceRepository) Delete(id string) error {
+	return RemoveFile(filepath.Join(r.dir, id+".json"))
+}
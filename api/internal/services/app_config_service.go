@@ -1,37 +1,147 @@
 package services
 
 import (
+	"fmt"
+
 	"gemiwin/api/internal/domain"
 	"gemiwin/api/internal/persistence"
 )
 
-// AppConfigService provides business logic for managing the global AppConfig.
+// AppConfigService provides business logic for managing each user's AppConfig.
 type AppConfigService struct {
-	repo *persistence.AppConfigRepository
+	repo    *persistence.AppConfigRepository
+	secrets *persistence.SecretStore
 }
 
 // NewAppConfigService constructs a new AppConfigService instance.
-func NewAppConfigService(repo *persistence.AppConfigRepository) *AppConfigService {
-	return &AppConfigService{repo: repo}
+func NewAppConfigService(repo *persistence.AppConfigRepository, secrets *persistence.SecretStore) *AppConfigService {
+	return &AppConfigService{repo: repo, secrets: secrets}
 }
 
-// GetConfig returns the current application configuration.
-func (s *AppConfigService) GetConfig() (*domain.AppConfig, error) {
-	return s.repo.Load()
+// GetConfig returns the configuration of userID with secrets masked.
+func (s *AppConfigService) GetConfig(userID string) (*domain.PublicAppConfig, error) {
+	cfg, err := s.load(userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.public(userID, cfg)
 }
 
 // UpdateConfig merges and persists the provided configuration with any existing configuration.
-// A non-empty field value replaces the existing one. An explicitly empty value clears it.
-func (s *AppConfigService) UpdateConfig(newCfg *domain.AppConfig) (*domain.AppConfig, error) {
-	existingCfg, err := s.repo.Load()
+// A non-empty API key is moved to the secret store; an empty one leaves the stored key untouched
+// (use DeleteGeminiApiKey to remove it).
+func (s *AppConfigService) UpdateConfig(userID string, admin bool, newCfg *domain.AppConfig) (*domain.PublicAppConfig, error) {
+	existingCfg, err := s.load(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Omitted servers are kept; an empty list removes them all
+	if newCfg.MCPServers != nil {
+		if err := ValidateServers(newCfg.MCPServers, admin); err != nil {
+			return nil, err
+		}
+		existingCfg.MCPServers = newCfg.MCPServers
+	}
+	if newCfg.Models != nil {
+		if err := ValidateModels(newCfg.Models); err != nil {
+			return nil, err
+		}
+		for i := range newCfg.Models {
+			newCfg.Models[i].Source = ""
+			newCfg.Models[i].Default = false
+		}
+		existingCfg.Models = newCfg.Models
+	}
+	// The webhook is saved with the budgets, so an update that omits it removes it
+	if newCfg.Budgets != nil {
+		if err := ValidateBudgets(newCfg.Budgets, newCfg.BudgetWebhookURL); err != nil {
+			return nil, err
+		}
+		existingCfg.Budgets = newCfg.Budgets
+		existingCfg.BudgetWebhookURL = newCfg.BudgetWebhookURL
+	}
+
+	if newCfg.GeminiApiKey != "" {
+		if err := s.secrets.Set(persistence.UserSecret(persistence.SecretGeminiApiKey, userID), newCfg.GeminiApiKey); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.repo.Save(userID, existingCfg); err != nil {
+		return nil, err
+	}
+	return s.public(userID, existingCfg)
+}
+
+// SetGeminiApiKey stores the Gemini API key of userID in the secret store.
+func (s *AppConfigService) SetGeminiApiKey(userID string, key string) (*domain.PublicAppConfig, error) {
+	if err := s.secrets.Set(persistence.UserSecret(persistence.SecretGeminiApiKey, userID), key); err != nil {
+		return nil, err
+	}
+	return s.GetConfig(userID)
+}
+
+// DeleteGeminiApiKey removes the stored Gemini API key of userID.
+func (s *AppConfigService) DeleteGeminiApiKey(userID string) (*domain.PublicAppConfig, error) {
+	if err := s.secrets.Delete(persistence.UserSecret(persistence.SecretGeminiApiKey, userID)); err != nil {
+		return nil, err
+	}
+	return s.GetConfig(userID)
+}
+
+// MigrateApiKeys moves the plaintext API keys that older versions saved in the configurations of
+// userIDs into the secret store, and removes them from disk. It runs at startup, as the bot reads
+// keys only from the secret store.
+func (s *AppConfigService) MigrateApiKeys(userIDs []string) error {
+	for _, userID := range userIDs {
+		if _, err := s.load(userID); err != nil {
+			return fmt.Errorf("failed to migrate the API key of user %s: %w", userID, err)
+		}
+	}
+	return nil
+}
+
+// load reads the configuration, moving a plaintext API key left by older versions into the secret store.
+func (s *AppConfigService) load(userID string) (*domain.AppConfig, error) {
+	cfg, err := s.repo.Load(userID)
 	if err != nil {
 		return nil, err
 	}
+	if cfg.GeminiApiKey == "" {
+		return cfg, nil
+	}
 
-	existingCfg.GeminiApiKey = newCfg.GeminiApiKey
+	if err := s.secrets.Set(persistence.UserSecret(persistence.SecretGeminiApiKey, userID), cfg.GeminiApiKey); err != nil {
+		return nil, err
+	}
+	cfg.GeminiApiKey = ""
+	if err := s.repo.Save(userID, cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
 
-	if err := s.repo.Save(existingCfg); err != nil {
+func (s *AppConfigService) public(userID string, cfg *domain.AppConfig) (*domain.PublicAppConfig, error) {
+	key, err := s.secrets.Get(persistence.UserSecret(persistence.SecretGeminiApiKey, userID))
+	if err != nil {
 		return nil, err
 	}
-	return existingCfg, nil
+	return &domain.PublicAppConfig{
+		AppConfig:          *cfg,
+		HasKey:             key != "",
+		GeminiApiKeyMasked: maskSecret(key),
+		KeyStorage:         s.secrets.Kind(),
+	}, nil
+}
+
+// maskSecret keeps just enough of a secret to recognise it, e.g. "AIza…9xQk".
+func maskSecret(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	if len(secret) < 12 {
+		return "****"
+	}
+	return secret[:4] + "…" + secret[len(secret)-4:]
 }
@@ -0,0 +1,53 @@
+package services
+
+import "gemiwin/api/internal/domain"
+
+// InlineData is a file passed to the model as raw bytes instead of extracted text.
+type InlineData struct {
+	MIMEType string
+	Data     []byte
+}
+
+// Turn is one message of the conversation sent to a backend. Turns with a ToolCall record a tool
+// the bot ran and its outcome instead of text.
+type Turn struct {
+	Role     domain.Role
+	Text     string
+	Inline   []InlineData
+	ToolCall *domain.ToolCall
+}
+
+// BotRequest is everything a backend needs to generate the next bot message.
+type BotRequest struct {
+	APIKey       string
+	Model        string
+	Instructions string
+	Turns        []Turn
+	// Tools the model may ask to run instead of answering
+	Tools []Tool
+	// ForceAnswer requires a text answer. Tools stay declared so the turns that used them are valid.
+	ForceAnswer bool
+}
+
+// BotReply is the next bot message: an answer, or tools the model wants run before answering.
+type BotReply struct {
+	Text      string
+	ToolCalls []domain.ToolCall
+	// Usage is the token count reported by the backend, or nil if it reports none.
+	Usage *TokenCount
+}
+
+// TokenCount is how many tokens one backend call read and wrote.
+type TokenCount struct {
+	Input  int
+	Output int
+}
+
+// Backend generates bot responses with a Gemini model.
+type Backend interface {
+	// SupportsInline reports whether files of the given MIME type can be sent as raw bytes.
+	SupportsInline(mimeType string) bool
+	Generate(req *BotRequest) (*BotReply, error)
+	// ListModels returns the models the backend can use with apiKey, with what it knows about them.
+	ListModels(apiKey string) ([]domain.Model, error)
+}
@@ -0,0 +1,448 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gemiwin/api/internal/domain"
+	"gemiwin/api/internal/persistence"
+)
+
+const (
+	// BackupFormat identifies gemiwin backup archives.
+	BackupFormat = "gemiwin-backup"
+	// BackupVersion is the archive layout version written by this build. Version 2 added secrets.enc.
+	BackupVersion = 2
+
+	backupManifestName = "manifest.json"
+
+	// MaxBackupBytes caps the size of an archive to restore, which is read into memory.
+	MaxBackupBytes = 1 << 30
+	// maxBackupExpandedBytes caps what the entries of an archive decompress to, together.
+	maxBackupExpandedBytes = 2 << 30
+	// maxBackupManifestBytes caps the decompressed manifest.
+	maxBackupManifestBytes = 16 << 20
+)
+
+// ErrInvalidBackup is returned when an archive fails validation. Nothing is written in that case.
+var ErrInvalidBackup = errors.New("invalid backup")
+
+// BackupEntry describes one file stored in a backup archive.
+type BackupEntry struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// BackupManifest is stored as manifest.json at the root of every backup archive.
+type BackupManifest struct {
+	Format    string        `json:"format"`
+	Version   int           `json:"version"`
+	CreatedAt time.Time     `json:"created_at"`
+	Entries   []BackupEntry `json:"entries"`
+}
+
+// RestoreChanges counts what a restore did (or would do) to one kind of data.
+type RestoreChanges struct {
+	Added     int `json:"added"`
+	Replaced  int `json:"replaced"`
+	Unchanged int `json:"unchanged"`
+	Removed   int `json:"removed"`
+}
+
+// RestoreReport summarises a restore run.
+type RestoreReport struct {
+	DryRun     bool           `json:"dry_run"`
+	Prune      bool           `json:"prune"`
+	CreatedAt  time.Time      `json:"backup_created_at"`
+	Chats      RestoreChanges `json:"chats"`
+	Documents  RestoreChanges `json:"documents"`
+	Files      RestoreChanges `json:"files"`
+	Workspaces RestoreChanges `json:"workspaces"`
+	Usage      RestoreChanges `json:"usage"`
+	AppConfig  RestoreChanges `json:"app_config"`
+	Secrets    RestoreChanges `json:"secrets"`
+	// Warnings explains entries that were (or would be) skipped or could not be fully checked.
+	Warnings []string `json:"warnings"`
+}
+
+// Files kept at the data root next to chats, configs and uploads.
+const (
+	// EncryptionMetaFile holds the encryption settings.
+	EncryptionMetaFile = "encryption.json"
+	// UsersFile holds the local accounts.
+	UsersFile = "users.json"
+	// SecretsFile holds the encrypted API keys.
+	SecretsFile = "secrets.enc"
+)
+
+// BackupService snapshots and restores the whole data directory.
+type BackupService struct {
+	root    string
+	vault   *persistence.Vault
+	secrets *persistence.SecretStore
+}
+
+// NewBackupService creates a BackupService for the data directory rooted at root. Encrypted files
+// are backed up as they are on disk, together with the settings needed to unlock them. The API
+// keys in secrets are backed up encrypted, without the key, which stays outside the data directory.
+func NewBackupService(root string, vault *persistence.Vault, secrets *persistence.SecretStore) *BackupService {
+	return &BackupService{root: root, vault: vault, secrets: secrets}
+}
+
+// BackupFileName returns the default file name for a backup taken at the given time.
+func BackupFileName(now time.Time) string {
+	return "gemiwin-backup-" + now.Format("20060102-150405") + ".zip"
+}
+
+// CreateBackup writes a zip archive of chats, files, the app configuration and the API keys to w.
+// Writes are blocked while the snapshot is taken so the archive is consistent.
+func (s *BackupService) CreateBackup(w io.Writer) (*BackupManifest, error) {
+	manifest := &BackupManifest{
+		Format:    BackupFormat,
+		Version:   BackupVersion,
+		CreatedAt: time.Now().UTC(),
+		Entries:   []BackupEntry{},
+	}
+
+	zw := zip.NewWriter(w)
+	err := persistence.Exclusive(func(tx persistence.Tx) error {
+		paths, err := s.backupPaths()
+		if err != nil {
+			return err
+		}
+		for _, rel := range paths {
+			data, err := os.ReadFile(s.pathFor(rel))
+			if err != nil {
+				return err
+			}
+			sum := sha256.Sum256(data)
+			manifest.Entries = append(manifest.Entries, BackupEntry{
+				Path:   rel,
+				Size:   int64(len(data)),
+				SHA256: hex.EncodeToString(sum[:]),
+			})
+			if err := writeZipEntry(zw, rel, data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := writeZipEntry(zw, backupManifestName, data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// Restore validates the archive and writes its contents into the data directory. With dryRun
+// nothing is written and the report describes the changes that would be made. With prune, chats
+// and files that are not part of the backup are deleted so the data directory matches it exactly.
+func (s *BackupService) Restore(archive []byte, dryRun bool, prune bool) (*RestoreReport, error) {
+	manifest, contents, err := readBackup(archive)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &RestoreReport{DryRun: dryRun, Prune: prune, CreatedAt: manifest.CreatedAt, Warnings: []string{}}
+
+	err = persistence.Exclusive(func(tx persistence.Tx) error {
+		existing, err := s.backupPaths()
+		if err != nil {
+			return err
+		}
+		present := make(map[string]bool, len(existing))
+		for _, rel := range existing {
+			present[rel] = true
+		}
+
+		for _, entry := range manifest.Entries {
+			if entry.Path == SecretsFile && !s.restorableSecrets(contents[entry.Path], report) {
+				continue
+			}
+			changes := report.changesFor(entry.Path)
+			dest := s.pathFor(entry.Path)
+
+			switch {
+			case !present[entry.Path]:
+				changes.Added++
+			case fileHasChecksum(dest, entry.SHA256):
+				changes.Unchanged++
+				continue
+			default:
+				changes.Replaced++
+			}
+
+			if !dryRun {
+				write := tx.WriteFile
+				if entry.Path == SecretsFile {
+					write = tx.WritePrivateFile
+				}
+				if err := write(dest, contents[entry.Path]); err != nil {
+					return err
+				}
+			}
+		}
+
+		if !prune {
+			return nil
+		}
+		for _, rel := range existing {
+			if _, ok := contents[rel]; ok {
+				continue
+			}
+			// Older archives never contained the API keys, so they do not mean to remove them
+			if rel == SecretsFile && manifest.Version < 2 {
+				continue
+			}
+			report.changesFor(rel).Removed++
+			if !dryRun {
+				if err := tx.RemoveFile(s.pathFor(rel)); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !dryRun {
+		// The restored data may use different encryption settings
+		if err := s.vault.Reload(); err != nil {
+			return nil, err
+		}
+	}
+	return report, nil
+}
+
+// restorableSecrets reports whether the API keys in data can be restored: they have to be
+// encrypted with this installation's secret key, or they could never be read again.
+func (s *BackupService) restorableSecrets(data []byte, report *RestoreReport) bool {
+	err := s.secrets.Check(data)
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, persistence.ErrWrongSecretKey):
+		report.Warnings = append(report.Warnings, fmt.Sprintf("%s skipped: %v; restore with the secret key the backup was taken with (secret_key_file or %s) to recover the API keys", SecretsFile, err, persistence.SecretKeyEnv))
+		return false
+	default:
+		// Encrypted with a passphrase that is not unlocked here, which restores with it
+		report.Warnings = append(report.Warnings, fmt.Sprintf("%s could not be checked against the secret key: %v", SecretsFile, err))
+		return true
+	}
+}
+
+func (r *RestoreReport) changesFor(rel string) *RestoreChanges {
+	switch {
+	case rel == SecretsFile:
+		return &r.Secrets
+	case strings.HasPrefix(rel, "chats/"):
+		return &r.Chats
+	case strings.HasPrefix(rel, "documents/"):
+		return &r.Documents
+	case strings.HasPrefix(rel, "files/"):
+		return &r.Files
+	case strings.HasPrefix(rel, "workspaces/"):
+		return &r.Workspaces
+	case strings.HasPrefix(rel, "usage/"):
+		return &r.Usage
+	default:
+		return &r.AppConfig
+	}
+}
+
+// readBackup parses and validates an archive, returning its manifest and the verified file contents.
+func readBackup(archive []byte) (*BackupManifest, map[string][]byte, error) {
+	zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: not a zip archive", ErrInvalidBackup)
+	}
+
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	mf, ok := files[backupManifestName]
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: missing %s", ErrInvalidBackup, backupManifestName)
+	}
+	remaining := int64(maxBackupManifestBytes)
+	data, err := readZipFileWithin(mf, &remaining)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+	}
+	var manifest BackupManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, nil, fmt.Errorf("%w: malformed manifest: %v", ErrInvalidBackup, err)
+	}
+	if manifest.Format != BackupFormat {
+		return nil, nil, fmt.Errorf("%w: unexpected format %q", ErrInvalidBackup, manifest.Format)
+	}
+	if manifest.Version < 1 || manifest.Version > BackupVersion {
+		return nil, nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidBackup, manifest.Version)
+	}
+
+	// Entries are read up to the sizes the manifest gives, which must fit the limit together
+	var expanded int64
+	for _, entry := range manifest.Entries {
+		if entry.Size < 0 {
+			return nil, nil, fmt.Errorf("%w: negative size for %s", ErrInvalidBackup, entry.Path)
+		}
+		if expanded += entry.Size; expanded > maxBackupExpandedBytes {
+			return nil, nil, fmt.Errorf("%w: contents exceed %d bytes", ErrInvalidBackup, maxBackupExpandedBytes)
+		}
+	}
+
+	contents := make(map[string][]byte, len(manifest.Entries))
+	for _, entry := range manifest.Entries {
+		if !isBackupPath(entry.Path) {
+			return nil, nil, fmt.Errorf("%w: unexpected path %q", ErrInvalidBackup, entry.Path)
+		}
+		if _, dup := contents[entry.Path]; dup {
+			return nil, nil, fmt.Errorf("%w: duplicate entry %q", ErrInvalidBackup, entry.Path)
+		}
+		f, ok := files[entry.Path]
+		if !ok {
+			return nil, nil, fmt.Errorf("%w: %s listed in manifest but missing from archive", ErrInvalidBackup, entry.Path)
+		}
+		remaining := entry.Size
+		body, err := readZipFileWithin(f, &remaining)
+		if errors.Is(err, ErrImportTooLarge) {
+			return nil, nil, fmt.Errorf("%w: %s is larger than the manifest says", ErrInvalidBackup, entry.Path)
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %s: %v", ErrInvalidBackup, entry.Path, err)
+		}
+		sum := sha256.Sum256(body)
+		if int64(len(body)) != entry.Size || hex.EncodeToString(sum[:]) != entry.SHA256 {
+			return nil, nil, fmt.Errorf("%w: checksum mismatch for %s", ErrInvalidBackup, entry.Path)
+		}
+		if err := validateBackupContent(entry.Path, body); err != nil {
+			return nil, nil, fmt.Errorf("%w: %s: %v", ErrInvalidBackup, entry.Path, err)
+		}
+		contents[entry.Path] = body
+	}
+
+	return &manifest, contents, nil
+}
+
+// validateBackupContent makes sure JSON documents decode into the expected domain types.
+// Encrypted documents cannot be inspected and are only checked against the manifest.
+func validateBackupContent(rel string, data []byte) error {
+	if persistence.IsEncrypted(data) {
+		return nil
+	}
+	switch {
+	case strings.HasPrefix(rel, "chats/"):
+		var chat domain.Chat
+		if err := json.Unmarshal(data, &chat); err != nil {
+			return err
+		}
+		if chat.ID+".json" != path.Base(rel) {
+			return fmt.Errorf("chat id %q does not match file name", chat.ID)
+		}
+	case strings.HasPrefix(rel, "documents/"):
+		var doc domain.LibraryDocument
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return err
+		}
+		if doc.ID+".json" != path.Base(rel) {
+			return fmt.Errorf("document id %q does not match file name", doc.ID)
+		}
+	case strings.HasPrefix(rel, "workspaces/"):
+		var ws domain.Workspace
+		if err := json.Unmarshal(data, &ws); err != nil {
+			return err
+		}
+		if ws.ID+".json" != path.Base(rel) {
+			return fmt.Errorf("workspace id %q does not match file name", ws.ID)
+		}
+	case strings.HasPrefix(rel, "usage/"):
+		var records []domain.UsageRecord
+		return json.Unmarshal(data, &records)
+	case rel == "app_config.json" || strings.HasPrefix(rel, "configs/"):
+		var cfg domain.AppConfig
+		return json.Unmarshal(data, &cfg)
+	case rel == UsersFile:
+		var users []domain.User
+		return json.Unmarshal(data, &users)
+	}
+	return nil
+}
+
+// isBackupPath reports whether rel is a location a backup is allowed to write to.
+func isBackupPath(rel string) bool {
+	if rel == "app_config.json" || rel == EncryptionMetaFile || rel == UsersFile || rel == SecretsFile {
+		return true
+	}
+	dir, name := path.Split(rel)
+	if name == "" || strings.HasPrefix(name, ".") || strings.ContainsAny(name, `\:`) {
+		return false
+	}
+	switch dir {
+	case "chats/", "configs/", "documents/", "usage/", "workspaces/":
+		return strings.HasSuffix(name, ".json")
+	case "files/":
+		return true
+	}
+	return false
+}
+
+// backupPaths lists the data files included in a backup, relative to the data root, in slash form.
+func (s *BackupService) backupPaths() ([]string, error) {
+	var paths []string
+	listed, err := persistence.ListDataFiles(s.root)
+	if err != nil {
+		return nil, err
+	}
+	for _, rel := range listed {
+		if isBackupPath(rel) {
+			paths = append(paths, rel)
+		}
+	}
+	for _, rel := range []string{EncryptionMetaFile, UsersFile, SecretsFile} {
+		if _, err := os.Stat(s.pathFor(rel)); err == nil {
+			paths = append(paths, rel)
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// pathFor converts a slash-separated path relative to the data root into a filesystem path.
+func (s *BackupService) pathFor(rel string) string {
+	return filepath.Join(s.root, filepath.FromSlash(rel))
+}
+
+func fileHasChecksum(filePath string, want string) bool {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return false
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]) == want
+}
@@ -0,0 +1,65 @@
+package services
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"gemiwin/api/internal/persistence"
+)
+
+// newTestBackupService returns a backup service for a new data directory whose secrets use the key
+// at keyPath, and the secret store of that directory.
+func newTestBackupService(t *testing.T, keyPath string) (*BackupService, *persistence.SecretStore) {
+	t.Helper()
+	root := t.TempDir()
+	vault, err := persistence.NewVault(filepath.Join(root, EncryptionMetaFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	secrets := persistence.NewSecretStore(filepath.Join(root, SecretsFile), keyPath, vault)
+	return NewBackupService(root, vault, secrets), secrets
+}
+
+func TestRestoreSecrets(t *testing.T) {
+	keyPath := filepath.Join(t.TempDir(), "secret.key")
+	source, sourceSecrets := newTestBackupService(t, keyPath)
+	if err := sourceSecrets.Set(persistence.SecretGeminiApiKey, "sk-source"); err != nil {
+		t.Fatal(err)
+	}
+	var archive bytes.Buffer
+	if _, err := source.CreateBackup(&archive); err != nil {
+		t.Fatal(err)
+	}
+
+	// Another data directory using the same key gets the API keys back
+	same, sameSecrets := newTestBackupService(t, keyPath)
+	report, err := same.Restore(archive.Bytes(), false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Secrets.Added != 1 || len(report.Warnings) != 0 {
+		t.Errorf("restore with the same key: secrets %+v, warnings %v", report.Secrets, report.Warnings)
+	}
+	if got, err := sameSecrets.Get(persistence.SecretGeminiApiKey); err != nil || got != "sk-source" {
+		t.Errorf("restored secret = %q, %v; want sk-source", got, err)
+	}
+
+	// With another key the API keys cannot be read, so the existing ones are kept
+	other, otherSecrets := newTestBackupService(t, filepath.Join(t.TempDir(), "secret.key"))
+	if err := otherSecrets.Set(persistence.SecretGeminiApiKey, "sk-other"); err != nil {
+		t.Fatal(err)
+	}
+	for _, dryRun := range []bool{true, false} {
+		report, err := other.Restore(archive.Bytes(), dryRun, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report.Secrets != (RestoreChanges{}) || len(report.Warnings) != 1 {
+			t.Errorf("restore with another key (dry run %v): secrets %+v, warnings %v", dryRun, report.Secrets, report.Warnings)
+		}
+	}
+	if got, err := otherSecrets.Get(persistence.SecretGeminiApiKey); err != nil || got != "sk-other" {
+		t.Errorf("kept secret = %q, %v; want sk-other", got, err)
+	}
+}
@@ -1,74 +1,255 @@
 package services
 
 import (
-	"bytes"
+	"errors"
 	"fmt"
-	"os"
-	"os/exec"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	"gemiwin/api/internal/domain"
+	"gemiwin/api/internal/extract"
 	"gemiwin/api/internal/persistence"
 )
 
-// BotService generates responses using the Gemini CLI, injecting global and chat configs.
+// maxInlineBytes caps the raw file data sent with one request; the Gemini API rejects larger
+// inline payloads.
+const maxInlineBytes = 20 << 20
+
+// maxToolRounds caps how many times the model may ask for tools before it must answer.
+const maxToolRounds = 5
+
+// promptInstructions tell the model how to treat the conversation.
+const promptInstructions = "You are the bot, and I am the user.\n" +
+	"Use the previous conversation ONLY as context to answer the final question.\n" +
+	"Do NOT repeat the context, greet, or add extra information.\n" +
+	"You must respond in the SAME LANGUAGE used in the user's last message.\n"
+
+// BotService generates responses with a model backend, injecting global and chat configs.
 type BotService struct {
-	cfgRepo *persistence.AppConfigRepository
+	secrets      *persistence.SecretStore
+	files        *persistence.FileRepository
+	workspaces   *WorkspaceService
+	tools        *ToolRegistry
+	mcp          *MCPService
+	backend      Backend
+	defaultModel string
 }
 
-func NewBotService(cfgRepo *persistence.AppConfigRepository) *BotService {
-	return &BotService{cfgRepo: cfgRepo}
+// NewBotService creates a BotService that calls backend, reads attachments from files, adds
+// context from the chat's workspaces and lets the model run tools, including those of the MCP
+// servers the chat enabled through mcp. defaultModel is used for chats that have no model
+// configured.
+func NewBotService(secrets *persistence.SecretStore, files *persistence.FileRepository, workspaces *WorkspaceService, tools *ToolRegistry, mcp *MCPService, backend Backend, defaultModel string) *BotService {
+	return &BotService{secrets: secrets, files: files, workspaces: workspaces, tools: tools, mcp: mcp, backend: backend, defaultModel: defaultModel}
 }
 
-func (s *BotService) GetBotResponse(chat *domain.Chat) (string, error) {
-	// Load global configuration
-	appCfg, err := s.cfgRepo.Load()
+// GetBotResponse returns the answer to the last message of chat, with the tokens and time it took.
+// model overrides the chat's model when set. Tools the model runs on the way are appended to
+// chat.Messages as "tool" messages, so the caller saves them with the answer. When it fails after
+// tools ran, the returned message only holds the Usage of the rounds that completed.
+func (s *BotService) GetBotResponse(chat *domain.Chat, model string) (domain.Message, error) {
+	return s.respond(chat, model, false)
+}
+
+// respond is GetBotResponse, offering only ReadOnly tools if readOnly is set.
+func (s *BotService) respond(chat *domain.Chat, model string, readOnly bool) (domain.Message, error) {
+	// Load the chat owner's API key from the secret store
+	apiKey, err := s.secrets.Get(persistence.UserSecret(persistence.SecretGeminiApiKey, chat.Owner()))
 	if err != nil {
-		return "", fmt.Errorf("failed to load app config: %w", err)
+		return domain.Message{}, fmt.Errorf("failed to load API key: %w", err)
 	}
-	var conversation strings.Builder
 
-	conversation.WriteString("You are the bot, and I am the user.\n")
-	conversation.WriteString("Use the previous conversation ONLY as context to answer the final question.\n")
-	conversation.WriteString("Do NOT repeat the context, greet, or add extra information.\n")
-	conversation.WriteString("You must respond in the SAME LANGUAGE used in the user's last message.\n")
-	conversation.WriteString("Conversation: \n")
+	if model == "" {
+		model = s.chatModel(chat)
+	}
 
-	for _, msg := range chat.Messages {
-		text := msg.Content
-		if msg.Type == "doc" && msg.Document != nil {
-			text += fmt.Sprintf(" <doc:%s>%s</doc>", msg.Document.Name, msg.Document.Content)
+	turns, err := s.buildTurns(chat.Messages)
+	if err != nil {
+		return domain.Message{}, err
+	}
+	if err := s.addWorkspaceContext(chat, turns); err != nil {
+		return domain.Message{}, err
+	}
+	// Tools of the MCP servers the chat enabled join the built-in ones
+	mcpTools, err := s.mcp.Tools(chat.Owner(), chat.Config.MCPServers)
+	if err != nil {
+		return domain.Message{}, err
+	}
+	tools := s.tools.With(mcpTools...)
+	if readOnly {
+		tools = tools.ReadOnly()
+	}
+	instructions := promptInstructions
+	if chat.Config.Instructions != "" {
+		instructions += "\n" + chat.Config.Instructions + "\n"
+	}
+	req := &BotRequest{
+		APIKey:       apiKey,
+		Model:        model,
+		Instructions: instructions,
+		Turns:        turns,
+		Tools:        tools.Tools(),
+	}
+	ctx := ToolContext{UserID: chat.Owner(), ChatID: chat.ID}
+	usage := &domain.Usage{Model: model}
+	start := time.Now()
+	for round := 0; ; round++ {
+		req.ForceAnswer = round == maxToolRounds
+		reply, err := s.backend.Generate(req)
+		if err != nil {
+			if round > 0 {
+				// The earlier rounds were paid for even though there is no answer
+				return domain.Message{Usage: usage}, err
+			}
+			return domain.Message{}, err
+		}
+		addUsage(usage, req, reply)
+		if len(reply.ToolCalls) == 0 {
+			usage.LatencyMs = time.Since(start).Milliseconds()
+			return domain.Message{
+				Role:      domain.BotRole,
+				Type:      "text",
+				Content:   reply.Text,
+				Usage:     usage,
+				Timestamp: time.Now(),
+			}, nil
+		}
+		if req.ForceAnswer {
+			return domain.Message{Usage: usage}, errors.New("the model kept calling tools without answering")
+		}
+		for _, call := range reply.ToolCalls {
+			call = tools.Call(ctx, call)
+			chat.Messages = append(chat.Messages, toolMessage(call))
+			req.Turns = append(req.Turns, Turn{Role: domain.BotRole, ToolCall: &call})
 		}
-		conversation.WriteString(fmt.Sprintf("%s: %s\n", msg.Role, text))
 	}
+}
 
-	conversation.WriteString("Your answer:")
+// chatModel returns the model chat is configured with, or the default model.
+func (s *BotService) chatModel(chat *domain.Chat) string {
+	if chat.Config.Model == "" {
+		return s.defaultModel
+	}
+	return chat.Config.Model
+}
 
-	cmd := exec.Command("gemini")
+// addUsage adds the tokens of one backend call to usage. When the backend reports none, they are
+// estimated from the text sent and received, and usage is marked as estimated.
+func addUsage(usage *domain.Usage, req *BotRequest, reply *BotReply) {
+	if reply.Usage != nil {
+		usage.InputTokens += reply.Usage.Input
+		usage.OutputTokens += reply.Usage.Output
+		return
+	}
+	usage.Estimated = true
+	input := estimateTokens(req.Instructions)
+	for _, turn := range req.Turns {
+		input += estimateTokens(turn.Text)
+		if turn.ToolCall != nil {
+			input += estimateTokens(string(turn.ToolCall.Arguments)) + estimateTokens(turn.ToolCall.Result) + estimateTokens(turn.ToolCall.Error)
+		}
+	}
+	output := estimateTokens(reply.Text)
+	for _, call := range reply.ToolCalls {
+		output += estimateTokens(call.Name) + estimateTokens(string(call.Arguments))
+	}
+	usage.InputTokens += input
+	usage.OutputTokens += output
+}
 
-	// We prepare the standard input of the command with the content of the conversation
-	cmd.Stdin = strings.NewReader(conversation.String())
+// estimateTokens approximates the token count of text at four characters per token, which is
+// close for English prose and code.
+func estimateTokens(text string) int {
+	return (utf8.RuneCountInString(text) + 3) / 4
+}
 
-	// Prepare environment variables
-	env := os.Environ()
-	if appCfg != nil && appCfg.GeminiApiKey != "" {
-		env = append(env, "GEMINI_API_KEY="+appCfg.GeminiApiKey)
+// toolMessage records call in the chat history. The content repeats the outcome for clients
+// and exports that do not know about tool calls.
+func toolMessage(call domain.ToolCall) domain.Message {
+	content := call.Result
+	if call.Error != "" {
+		content = "Error: " + call.Error
 	}
-	model := chat.Config.Model
-	if model == "" {
-		model = domain.DefaultModel
+	return domain.Message{
+		Role:      domain.BotRole,
+		Type:      "tool",
+		Content:   content,
+		ToolCall:  &call,
+		Timestamp: time.Now(),
 	}
-	env = append(env, "GEMINI_MODEL="+model)
-	cmd.Env = env
+}
 
-	var out bytes.Buffer
-	cmd.Stdout = &out
-	var stderr bytes.Buffer
-	cmd.Stderr = &stderr
+// buildTurns renders the messages for the backend. Documents the backend accepts as raw bytes
+// are attached inline, up to maxInlineBytes per request; all others are embedded as text.
+func (s *BotService) buildTurns(messages []domain.Message) ([]Turn, error) {
+	turns := make([]Turn, 0, len(messages))
+	inlineBytes := 0
+	for _, msg := range messages {
+		if msg.ToolCall != nil {
+			turns = append(turns, Turn{Role: msg.Role, ToolCall: msg.ToolCall})
+			continue
+		}
+		turn := Turn{Role: msg.Role, Text: msg.Content}
+		for _, doc := range msg.Documents {
+			if doc.ID != "" && s.backend.SupportsInline(doc.MIMEType) {
+				data, err := s.files.Read(doc.ID)
+				if err != nil {
+					return nil, err
+				}
+				if data != nil && inlineBytes+len(data) <= maxInlineBytes {
+					inlineBytes += len(data)
+					turn.Inline = append(turn.Inline, InlineData{MIMEType: doc.MIMEType, Data: data})
+					turn.Text += fmt.Sprintf(" <doc:%s>[attached as %s]</doc>", doc.Name, doc.MIMEType)
+					continue
+				}
+			}
+			turn.Text += fmt.Sprintf(" <doc:%s>%s</doc>", doc.Name, documentPromptText(doc))
+		}
+		turns = append(turns, turn)
+	}
+	return turns, nil
+}
 
-	if err = cmd.Run(); err != nil {
-		return "", fmt.Errorf("error executing gemini command: %w, stderr: %s", err, stderr.String())
+// addWorkspaceContext appends the files of the chat's workspaces that are relevant to the last
+// question to the last user turn. Earlier turns are left alone, so their context is not repeated.
+func (s *BotService) addWorkspaceContext(chat *domain.Chat, turns []Turn) error {
+	if len(chat.Config.WorkspaceIDs) == 0 {
+		return nil
 	}
+	for i := len(turns) - 1; i >= 0; i-- {
+		if turns[i].Role != domain.UserRole {
+			continue
+		}
+		context, err := s.workspaces.PromptContext(chat.Owner(), chat.Config.WorkspaceIDs, chat.Messages[i].Content)
+		if err != nil {
+			return fmt.Errorf("failed to load workspace context: %w", err)
+		}
+		turns[i].Text += context
+		return nil
+	}
+	return nil
+}
 
-	return strings.TrimSpace(out.String()), nil
+// documentPromptText returns the text of doc for the prompt. Extraction problems are spelled out
+// so the model does not mistake a document it could not read for an empty one.
+func documentPromptText(doc domain.Document) string {
+	if doc.SourceURL != "" {
+		doc.Content = "[fetched from " + doc.SourceURL + "]\n" + doc.Content
+	}
+	if doc.Extraction == nil || doc.Extraction.Status == extract.StatusOK {
+		return doc.Content
+	}
+	if doc.Extraction.Status == extract.StatusUnsupported && doc.Content == "" {
+		return fmt.Sprintf("[unsupported attachment: %s files cannot be read by this model backend]", doc.MIMEType)
+	}
+	note := fmt.Sprintf("[text extraction %s", doc.Extraction.Status)
+	if len(doc.Extraction.Warnings) > 0 {
+		note += ": " + strings.Join(doc.Extraction.Warnings, "; ")
+	}
+	note += "]"
+	if doc.Content == "" {
+		return note
+	}
+	return doc.Content + "\n" + note
 }
@@ -0,0 +1,309 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+
+	"gemiwin/api/internal/domain"
+	"gemiwin/api/internal/persistence"
+)
+
+// budgetWebhookTimeout bounds delivering one budget event.
+const budgetWebhookTimeout = 10 * time.Second
+
+// Budget events sent to the webhook.
+const (
+	BudgetEventThreshold = "budget.threshold"
+	BudgetEventExceeded  = "budget.exceeded"
+)
+
+var (
+	// ErrInvalidBudget is returned for budget settings that cannot be used.
+	ErrInvalidBudget = errors.New("invalid budget")
+	// ErrBudgetExceeded is wrapped by BudgetExceededError.
+	ErrBudgetExceeded = errors.New("budget exceeded")
+)
+
+// BudgetExceededError is returned when a used-up budget without a downgrade model refuses a message.
+type BudgetExceededError struct {
+	Budget   domain.Budget
+	ResetsAt time.Time
+}
+
+func (e *BudgetExceededError) Error() string {
+	scope := "your"
+	if e.Budget.ChatID != "" {
+		scope = "this chat's"
+	}
+	return fmt.Sprintf("%s %s budget of %s is used up; it resets at %s", scope, e.Budget.Period, budgetLimit(e.Budget), e.ResetsAt.Format("2006-01-02 15:04 MST"))
+}
+
+func (e *BudgetExceededError) Unwrap() error {
+	return ErrBudgetExceeded
+}
+
+// BudgetStatus is a budget with what has been used of it in the current period.
+type BudgetStatus struct {
+	domain.Budget
+	UsedTokens  int       `json:"used_tokens"`
+	UsedCostUSD float64   `json:"used_cost_usd"`
+	PeriodStart time.Time `json:"period_start"`
+	ResetsAt    time.Time `json:"resets_at"`
+	Exceeded    bool      `json:"exceeded"`
+}
+
+// BudgetEvent is posted to the budget webhook when an answer crosses a threshold of a budget.
+type BudgetEvent struct {
+	Event     string       `json:"event"`
+	UserID    string       `json:"user_id"`
+	Threshold float64      `json:"threshold"`
+	Budget    BudgetStatus `json:"budget"`
+	// ChatID and Model identify the answer that crossed the threshold.
+	ChatID    string    `json:"chat_id,omitempty"`
+	Model     string    `json:"model"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// BudgetService enforces the budgets of each user's AppConfig against their usage ledger, and
+// reports crossed thresholds to the user's webhook.
+type BudgetService struct {
+	configs *persistence.AppConfigRepository
+	usage   *UsageService
+	users   *UserService
+	// guarded reaches the webhooks of users without administrator rights under the URL policy
+	guarded *http.Client
+	open    *http.Client
+}
+
+// NewBudgetService creates a BudgetService that reads budgets from configs and measures them with
+// usage. Webhooks of users without administrator rights, checked with users, are reached through
+// fetcher's policy, like fetched URLs.
+func NewBudgetService(configs *persistence.AppConfigRepository, usage *UsageService, users *UserService, fetcher *URLFetcher) *BudgetService {
+	return &BudgetService{
+		configs: configs,
+		usage:   usage,
+		users:   users,
+		guarded: fetcher.Client(budgetWebhookTimeout),
+		open:    &http.Client{Timeout: budgetWebhookTimeout},
+	}
+}
+
+// ValidateBudgets checks budget settings before they are saved.
+func ValidateBudgets(budgets []domain.Budget, webhookURL string) error {
+	for i, budget := range budgets {
+		if budget.Period != domain.BudgetDaily && budget.Period != domain.BudgetMonthly {
+			return fmt.Errorf("%w: budgets[%d]: period must be %q or %q", ErrInvalidBudget, i, domain.BudgetDaily, domain.BudgetMonthly)
+		}
+		if budget.MaxTokens < 0 || budget.MaxCostUSD < 0 || (budget.MaxTokens == 0 && budget.MaxCostUSD == 0) {
+			return fmt.Errorf("%w: budgets[%d]: set a positive max_tokens or max_cost_usd", ErrInvalidBudget, i)
+		}
+		if budget.DowngradeTo != "" && !domain.ValidModelID(budget.DowngradeTo) {
+			return fmt.Errorf("%w: budgets[%d]: invalid downgrade_to model %q", ErrInvalidBudget, i, budget.DowngradeTo)
+		}
+		for _, threshold := range budget.AlertAt {
+			if threshold <= 0 || threshold >= 1 {
+				return fmt.Errorf("%w: budgets[%d]: alert_at values must be between 0 and 1", ErrInvalidBudget, i)
+			}
+		}
+	}
+	if webhookURL != "" {
+		u, err := url.Parse(webhookURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("%w: budget_webhook_url must be an http or https URL", ErrInvalidBudget)
+		}
+	}
+	return nil
+}
+
+// Status returns userID's budgets with their use in the current period.
+func (s *BudgetService) Status(userID string) ([]BudgetStatus, error) {
+	cfg, err := s.configs.Load(userID)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]BudgetStatus, 0, len(cfg.Budgets))
+	for _, budget := range cfg.Budgets {
+		status, err := s.measure(userID, budget, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// Check returns the model that may answer in chatID, which is model unless a used-up budget
+// downgrades it. A *BudgetExceededError is returned if a used-up budget has no downgrade model.
+func (s *BudgetService) Check(userID string, chatID string, model string) (string, error) {
+	cfg, err := s.configs.Load(userID)
+	if err != nil {
+		return "", err
+	}
+	downgrade := ""
+	for _, budget := range cfg.Budgets {
+		if budget.ChatID != "" && budget.ChatID != chatID {
+			continue
+		}
+		status, err := s.measure(userID, budget, time.Now())
+		if err != nil {
+			return "", err
+		}
+		if !status.Exceeded {
+			continue
+		}
+		if budget.DowngradeTo == "" {
+			return "", &BudgetExceededError{Budget: budget, ResetsAt: status.ResetsAt}
+		}
+		if downgrade == "" {
+			downgrade = budget.DowngradeTo
+		}
+	}
+	if downgrade != "" {
+		return downgrade, nil
+	}
+	return model, nil
+}
+
+// Record adds answers, given in chatID, to userID's usage ledger and reports the budget
+// thresholds they cross to the user's webhook. Delivery happens in the background; failures are
+// logged.
+func (s *BudgetService) Record(userID string, chatID string, answers ...*domain.Usage) error {
+	cfg, err := s.configs.Load(userID)
+	if err != nil {
+		return err
+	}
+
+	// Budgets are measured before the answers are added, to find the thresholds they cross
+	now := time.Now()
+	var budgets []BudgetStatus
+	for _, budget := range cfg.Budgets {
+		if budget.ChatID != "" && budget.ChatID != chatID {
+			continue
+		}
+		status, err := s.measure(userID, budget, now)
+		if err != nil {
+			return err
+		}
+		budgets = append(budgets, status)
+	}
+	if err := s.usage.Record(userID, chatID, answers...); err != nil {
+		return err
+	}
+
+	var events []BudgetEvent
+	for _, before := range budgets {
+		budget := before.Budget
+		thresholds := append(slices.Sorted(slices.Values(budget.AlertAt)), 1)
+		// Answers are added one by one, so each event names the model whose answer crossed it
+		for _, answer := range answers {
+			after := before
+			after.UsedTokens += answer.InputTokens + answer.OutputTokens
+			if answer.CostUSD != nil {
+				after.UsedCostUSD = math.Round((after.UsedCostUSD+*answer.CostUSD)*1e6) / 1e6
+			}
+			after.Exceeded = budgetFraction(budget, after.UsedTokens, after.UsedCostUSD) >= 1
+
+			for _, threshold := range thresholds {
+				if budgetFraction(budget, before.UsedTokens, before.UsedCostUSD) >= threshold ||
+					budgetFraction(budget, after.UsedTokens, after.UsedCostUSD) < threshold {
+					continue
+				}
+				event := BudgetEvent{Event: BudgetEventThreshold, UserID: userID, Threshold: threshold, Budget: after, ChatID: chatID, Model: answer.Model, Timestamp: now}
+				if threshold == 1 {
+					event.Event = BudgetEventExceeded
+				}
+				log.Printf("Budget %s: user %s, %s budget of %s at %.0f%%", event.Event, userID, budget.Period, budgetLimit(budget), threshold*100)
+				events = append(events, event)
+			}
+			before = after
+		}
+	}
+	if len(events) > 0 && cfg.BudgetWebhookURL != "" {
+		go s.deliver(userID, cfg.BudgetWebhookURL, events)
+	}
+	return nil
+}
+
+// measure returns budget with its use in the period containing now, read from the usage ledger
+// so that deleting chats gives nothing back.
+func (s *BudgetService) measure(userID string, budget domain.Budget, now time.Time) (BudgetStatus, error) {
+	start, end := budgetPeriod(budget.Period, now)
+	status := BudgetStatus{Budget: budget, PeriodStart: start, ResetsAt: end}
+	total, err := s.usage.Total(userID, UsageFilter{From: start, To: end, ChatID: budget.ChatID})
+	if err != nil {
+		return status, err
+	}
+	status.UsedTokens = total.InputTokens + total.OutputTokens
+	status.UsedCostUSD = total.CostUSD
+	status.Exceeded = budgetFraction(budget, status.UsedTokens, status.UsedCostUSD) >= 1
+	return status, nil
+}
+
+// deliver posts events to the webhook of userID, one request per event.
+func (s *BudgetService) deliver(userID string, webhookURL string, events []BudgetEvent) {
+	client := s.guarded
+	if user, err := s.users.GetUser(userID); err == nil && user != nil && user.Admin {
+		client = s.open
+	}
+	for _, event := range events {
+		body, err := json.Marshal(event)
+		if err != nil {
+			continue
+		}
+		resp, err := client.Post(webhookURL, "application/json", bytes.NewReader(body))
+		if err != nil {
+			log.Printf("Budget webhook of user %s failed: %v", userID, err)
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			log.Printf("Budget webhook of user %s answered %s", userID, resp.Status)
+		}
+	}
+}
+
+// budgetPeriod returns the start of the daily or monthly period containing now and the start of
+// the next one, in the server's time zone.
+func budgetPeriod(period string, now time.Time) (time.Time, time.Time) {
+	now = now.Local()
+	if period == domain.BudgetMonthly {
+		start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local)
+		return start, start.AddDate(0, 1, 0)
+	}
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	return start, start.AddDate(0, 0, 1)
+}
+
+// budgetFraction returns how much of budget the given use takes, by whichever limit is closest.
+func budgetFraction(budget domain.Budget, tokens int, cost float64) float64 {
+	fraction := 0.0
+	if budget.MaxTokens > 0 {
+		fraction = float64(tokens) / float64(budget.MaxTokens)
+	}
+	if budget.MaxCostUSD > 0 {
+		fraction = max(fraction, cost/budget.MaxCostUSD)
+	}
+	return fraction
+}
+
+// budgetLimit describes the limits of budget, e.g. "100000 tokens" or "$5".
+func budgetLimit(budget domain.Budget) string {
+	cost := "$" + strconv.FormatFloat(budget.MaxCostUSD, 'f', -1, 64)
+	switch {
+	case budget.MaxTokens > 0 && budget.MaxCostUSD > 0:
+		return fmt.Sprintf("%d tokens or %s", budget.MaxTokens, cost)
+	case budget.MaxTokens > 0:
+		return fmt.Sprintf("%d tokens", budget.MaxTokens)
+	default:
+		return cost
+	}
+}
@@ -0,0 +1,179 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	// Time zones must resolve on machines without a zoneinfo database, such as Windows
+	_ "time/tzdata"
+
+	"gemiwin/api/internal/domain"
+	"gemiwin/api/internal/persistence"
+)
+
+const (
+	// maxSearchResults caps the matches search_chats returns.
+	maxSearchResults = 10
+	// searchSnippetRunes is the context kept around each match.
+	searchSnippetRunes = 120
+)
+
+// BuiltinTools returns the tools that ship with the server. They only read the clock and the
+// calling user's own chats.
+func BuiltinTools(chats *persistence.ChatRepository) []Tool {
+	return []Tool{
+		{
+			Name:        "current_time",
+			Description: "Returns the current date, time and weekday, in UTC or the given IANA time zone.",
+			Parameters: json.RawMessage(`{"type":"object","properties":{` +
+				`"timezone":{"type":"string","description":"IANA time zone such as Europe/Paris; defaults to UTC"}}}`),
+			Handler:  currentTimeTool,
+			ReadOnly: true,
+		},
+		{
+			Name:        "calculator",
+			Description: "Evaluates an arithmetic expression in double-precision floating point. Supports + - * / % ^, parentheses, sqrt, abs, round, floor, ceil, ln, log, log2, exp, trigonometric functions, pi and e.",
+			Parameters: json.RawMessage(`{"type":"object","properties":{` +
+				`"expression":{"type":"string","description":"Expression to evaluate, e.g. (3.5 + 2) * sqrt(16)"}},` +
+				`"required":["expression"]}`),
+			Handler:  calculatorTool,
+			ReadOnly: true,
+		},
+		{
+			Name:        "search_chats",
+			Description: "Searches the user's previous chats for a word or phrase and returns the matching messages with their chat names.",
+			Parameters: json.RawMessage(`{"type":"object","properties":{` +
+				`"query":{"type":"string","description":"Text to look for, case-insensitive"}},` +
+				`"required":["query"]}`),
+			Handler:  searchChatsTool(chats),
+			ReadOnly: true,
+		},
+	}
+}
+
+func currentTimeTool(_ ToolContext, args json.RawMessage) (string, error) {
+	var params struct {
+		Timezone string `json:"timezone"`
+	}
+	if err := json.Unmarshal(args, &params); err != nil {
+		return "", fmt.Errorf("invalid arguments: %w", err)
+	}
+	loc := time.UTC
+	if params.Timezone != "" {
+		var err error
+		if loc, err = time.LoadLocation(params.Timezone); err != nil {
+			return "", fmt.Errorf("unknown time zone %q", params.Timezone)
+		}
+	}
+	now := time.Now().In(loc)
+	return fmt.Sprintf("%s (%s, %s)", now.Format(time.RFC3339), now.Weekday(), loc), nil
+}
+
+func calculatorTool(_ ToolContext, args json.RawMessage) (string, error) {
+	var params struct {
+		Expression string `json:"expression"`
+	}
+	if err := json.Unmarshal(args, &params); err != nil {
+		return "", fmt.Errorf("invalid arguments: %w", err)
+	}
+	v, err := calculate(params.Expression)
+	if err != nil {
+		return "", err
+	}
+	return formatNumber(v), nil
+}
+
+// chatSearchResult is one message found by searchChats.
+type chatSearchResult struct {
+	ChatID    string    `json:"chat_id"`
+	ChatName  string    `json:"chat_name"`
+	Role      string    `json:"role"`
+	Snippet   string    `json:"snippet"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+func searchChatsTool(chats *persistence.ChatRepository) func(ToolContext, json.RawMessage) (string, error) {
+	return func(ctx ToolContext, args json.RawMessage) (string, error) {
+		var params struct {
+			Query string `json:"query"`
+		}
+		if err := json.Unmarshal(args, &params); err != nil {
+			return "", fmt.Errorf("invalid arguments: %w", err)
+		}
+		query := strings.ToLower(strings.TrimSpace(params.Query))
+		if query == "" {
+			return "", errors.New("query must not be empty")
+		}
+
+		owned, err := chats.FindAllByOwner(ctx.UserID)
+		if err != nil {
+			return "", err
+		}
+		// The current conversation is already in the model's context
+		results := searchChats(owned, query, ctx.ChatID, maxSearchResults)
+
+		data, err := json.Marshal(results)
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	}
+}
+
+// searchChats finds the messages of chats containing query, which must be lowercase, newest
+// first. Tool runs and the chat with id skipChatID are left out.
+func searchChats(chats []*domain.Chat, query string, skipChatID string, limit int) []chatSearchResult {
+	results := []chatSearchResult{}
+	for _, chat := range chats {
+		if chat.ID == skipChatID {
+			continue
+		}
+		for _, msg := range chat.Messages {
+			if msg.Type == "tool" {
+				continue
+			}
+			if snippet, ok := searchSnippet(msg.Content, query); ok {
+				results = append(results, chatSearchResult{
+					ChatID:    chat.ID,
+					ChatName:  chat.Name,
+					Role:      string(msg.Role),
+					Snippet:   snippet,
+					Timestamp: msg.Timestamp,
+				})
+			}
+		}
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Timestamp.After(results[j].Timestamp) })
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+// searchSnippet returns the part of content around the first case-insensitive match of query.
+func searchSnippet(content string, query string) (string, bool) {
+	runes := []rune(content)
+	lower := []rune(strings.ToLower(content))
+	// Lowercasing can change the length of a few characters; fall back to the plain text then
+	if len(lower) != len(runes) {
+		lower = runes
+	}
+	i := strings.Index(string(lower), query)
+	if i < 0 {
+		return "", false
+	}
+	start := len([]rune(string(lower)[:i]))
+	from := max(0, start-searchSnippetRunes/2)
+	to := min(len(runes), start+len([]rune(query))+searchSnippetRunes/2)
+	snippet := strings.TrimSpace(string(runes[from:to]))
+	if from > 0 {
+		snippet = "…" + snippet
+	}
+	if to < len(runes) {
+		snippet += "…"
+	}
+	return snippet, true
+}
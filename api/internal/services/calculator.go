@@ -0,0 +1,248 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// maxExpressionLength and maxExpressionDepth bound the work a calculator call can cause.
+const (
+	maxExpressionLength = 1000
+	maxExpressionDepth  = 64
+)
+
+var calculatorFunctions = map[string]func(float64) float64{
+	"sqrt": math.Sqrt, "abs": math.Abs, "round": math.Round, "floor": math.Floor, "ceil": math.Ceil,
+	"ln": math.Log, "log": math.Log10, "log2": math.Log2, "exp": math.Exp,
+	"sin": math.Sin, "cos": math.Cos, "tan": math.Tan, "asin": math.Asin, "acos": math.Acos, "atan": math.Atan,
+}
+
+var calculatorConstants = map[string]float64{"pi": math.Pi, "e": math.E}
+
+// calculate evaluates an arithmetic expression with + - * / % ^, parentheses, the functions in
+// calculatorFunctions and the constants pi and e.
+func calculate(expression string) (float64, error) {
+	if len(expression) > maxExpressionLength {
+		return 0, fmt.Errorf("expression is longer than %d characters", maxExpressionLength)
+	}
+	p := &calcParser{input: expression}
+	v, err := p.expression()
+	if err != nil {
+		return 0, err
+	}
+	p.skipSpace()
+	if p.pos < len(p.input) {
+		return 0, fmt.Errorf("unexpected %q at position %d", p.input[p.pos], p.pos+1)
+	}
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, errors.New("the result is not a finite number")
+	}
+	return v, nil
+}
+
+// formatNumber renders v without an exponent unless it is very large or very small.
+func formatNumber(v float64) string {
+	if a := math.Abs(v); a == 0 || (a >= 1e-6 && a < 1e15) {
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// calcParser is a recursive descent parser over the grammar
+//
+//	expression = term { ("+" | "-") term }
+//	term       = unary { ("*" | "/" | "%") unary }
+//	unary      = ("+" | "-") unary | power
+//	power      = primary [ "^" unary ]
+//	primary    = number | constant | function "(" expression ")" | "(" expression ")"
+type calcParser struct {
+	input string
+	pos   int
+	depth int
+}
+
+func (p *calcParser) skipSpace() {
+	for p.pos < len(p.input) && unicode.IsSpace(rune(p.input[p.pos])) {
+		p.pos++
+	}
+}
+
+func (p *calcParser) peek() byte {
+	p.skipSpace()
+	if p.pos < len(p.input) {
+		return p.input[p.pos]
+	}
+	return 0
+}
+
+func (p *calcParser) expression() (float64, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > maxExpressionDepth {
+		return 0, errors.New("expression is nested too deeply")
+	}
+
+	v, err := p.term()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		switch p.peek() {
+		case '+':
+			p.pos++
+			r, err := p.term()
+			if err != nil {
+				return 0, err
+			}
+			v += r
+		case '-':
+			p.pos++
+			r, err := p.term()
+			if err != nil {
+				return 0, err
+			}
+			v -= r
+		default:
+			return v, nil
+		}
+	}
+}
+
+func (p *calcParser) term() (float64, error) {
+	v, err := p.unary()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		op := p.peek()
+		if op != '*' && op != '/' && op != '%' {
+			return v, nil
+		}
+		p.pos++
+		r, err := p.unary()
+		if err != nil {
+			return 0, err
+		}
+		switch {
+		case op == '*':
+			v *= r
+		case r == 0:
+			return 0, errors.New("division by zero")
+		case op == '/':
+			v /= r
+		default:
+			v = math.Mod(v, r)
+		}
+	}
+}
+
+func (p *calcParser) unary() (float64, error) {
+	switch p.peek() {
+	case '-', '+':
+		neg := p.input[p.pos] == '-'
+		p.pos++
+		p.depth++
+		defer func() { p.depth-- }()
+		if p.depth > maxExpressionDepth {
+			return 0, errors.New("expression is nested too deeply")
+		}
+		v, err := p.unary()
+		if neg {
+			v = -v
+		}
+		return v, err
+	}
+	return p.power()
+}
+
+func (p *calcParser) power() (float64, error) {
+	base, err := p.primary()
+	if err != nil {
+		return 0, err
+	}
+	if p.peek() != '^' {
+		return base, nil
+	}
+	p.pos++
+	// Exponentiation is right-associative: 2^3^2 is 2^(3^2)
+	exp, err := p.unary()
+	if err != nil {
+		return 0, err
+	}
+	return math.Pow(base, exp), nil
+}
+
+func (p *calcParser) primary() (float64, error) {
+	c := p.peek()
+	switch {
+	case c == '(':
+		p.pos++
+		v, err := p.expression()
+		if err != nil {
+			return 0, err
+		}
+		if p.peek() != ')' {
+			return 0, errors.New("missing closing parenthesis")
+		}
+		p.pos++
+		return v, nil
+	case c >= '0' && c <= '9' || c == '.':
+		return p.number()
+	case unicode.IsLetter(rune(c)):
+		start := p.pos
+		for p.pos < len(p.input) && (unicode.IsLetter(rune(p.input[p.pos])) || unicode.IsDigit(rune(p.input[p.pos]))) {
+			p.pos++
+		}
+		name := strings.ToLower(p.input[start:p.pos])
+		if v, ok := calculatorConstants[name]; ok {
+			return v, nil
+		}
+		fn, ok := calculatorFunctions[name]
+		if !ok {
+			return 0, fmt.Errorf("unknown function or constant %q", name)
+		}
+		if p.peek() != '(' {
+			return 0, fmt.Errorf("%s needs parentheses", name)
+		}
+		arg, err := p.primary()
+		if err != nil {
+			return 0, err
+		}
+		return fn(arg), nil
+	case c == 0:
+		return 0, errors.New("unexpected end of expression")
+	default:
+		return 0, fmt.Errorf("unexpected %q at position %d", c, p.pos+1)
+	}
+}
+
+func (p *calcParser) number() (float64, error) {
+	start := p.pos
+	for p.pos < len(p.input) {
+		c := p.input[p.pos]
+		isExp := (c == 'e' || c == 'E') && p.pos+1 < len(p.input) &&
+			(p.input[p.pos+1] >= '0' && p.input[p.pos+1] <= '9' || p.input[p.pos+1] == '-' || p.input[p.pos+1] == '+')
+		switch {
+		case c >= '0' && c <= '9' || c == '.' || c == '_':
+			p.pos++
+		case isExp:
+			p.pos += 2
+		default:
+			return p.parseNumber(start)
+		}
+	}
+	return p.parseNumber(start)
+}
+
+func (p *calcParser) parseNumber(start int) (float64, error) {
+	text := strings.ReplaceAll(p.input[start:p.pos], "_", "")
+	v, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", p.input[start:p.pos])
+	}
+	return v, nil
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestCalculate(t *testing.T) {
+	tests := []struct {
+		expression string
+		want       float64
+	}{
+		{"1 + 2 * 3", 7},
+		{"(1 + 2) * 3", 9},
+		{"10 - 4 - 3", 3},
+		{"12 / 4 / 3", 1},
+		{"2 * 3 ^ 2", 18},
+		{"2 ^ 3 ^ 2", 512},
+		{"(2 ^ 3) ^ 2", 64},
+		{"-2 ^ 2", -4},
+		{"2 ^ -1", 0.5},
+		{"--3", 3},
+		{"-(1 + 2) * -3", 9},
+		{"3 - -2", 5},
+		{"+4", 4},
+		{"7 % 3", 1},
+		{"-7 % 3", -1},
+		{"7.5 % 2", 1.5},
+		{"1 + 7 % 3 * 2", 3},
+		{"sqrt(16) + abs(-2)", 6},
+		{"round(2.5) + floor(1.9) + ceil(1.1)", 6},
+		{"log(1000) + log2(8)", 6},
+		{"PI", math.Pi},
+		{"cos(0) + e - e", 1},
+		{"1_000 * 1.5e3", 1.5e6},
+		{strings.Repeat("(", 63) + "1" + strings.Repeat(")", 63), 1},
+		{strings.Repeat("1+", 499) + "1", 500},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			got, err := calculate(tt.expression)
+			if err != nil {
+				t.Fatalf("calculate(%q): %v", tt.expression, err)
+			}
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("calculate(%q) = %v, want %v", tt.expression, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCalculateErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		err        string
+	}{
+		{"division by zero", "1 / 0", "division by zero"},
+		{"division by a zero expression", "1 / (2 - 2)", "division by zero"},
+		{"modulo zero", "5 % 0", "division by zero"},
+		{"unknown identifier", "foo + 1", `unknown function or constant "foo"`},
+		{"unknown function", "cbrt(8)", `unknown function or constant "cbrt"`},
+		{"function without parentheses", "sqrt 4", "sqrt needs parentheses"},
+		{"not finite", "ln(0)", "not a finite number"},
+		{"not a number", "sqrt(-1)", "not a finite number"},
+		{"empty", "", "unexpected end of expression"},
+		{"dangling operator", "1 +", "unexpected end of expression"},
+		{"trailing input", "1 2", `unexpected '2' at position 3`},
+		{"unclosed parenthesis", "(1 + 2", "missing closing parenthesis"},
+		{"invalid number", "1.2.3", `invalid number "1.2.3"`},
+		{"nested too deeply", strings.Repeat("(", 64) + "1" + strings.Repeat(")", 64), "nested too deeply"},
+		{"too many signs", strings.Repeat("-", 64) + "1", "nested too deeply"},
+		{"too long", strings.Repeat("1+", 500) + "1", "longer than 1000 characters"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := calculate(tt.expression)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("calculate(%q) error = %v, want %q", tt.expression, err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"gemiwin/api/internal/domain"
+)
+
+// maxCompareModels caps how many models one comparison asks.
+const maxCompareModels = 4
+
+var (
+	// ErrInvalidComparison is returned for comparisons that cannot be run.
+	ErrInvalidComparison = errors.New("invalid comparison")
+	// ErrAlternateNotFound is returned when a message has no alternate at the given position.
+	ErrAlternateNotFound = errors.New("alternate not found")
+)
+
+// Compare adds content to chat id and has each of models answer it at once. The answers are
+// stored as alternates of one bot message, the first one that succeeded being selected. Budgets
+// apply to each model as they do to a single answer. Only ReadOnly tools are offered, since each
+// model runs them on its own. It returns nil if the chat does not exist.
+func (s *ChatService) Compare(userID string, id string, content string, models []string) (*domain.Chat, error) {
+	if content == "" {
+		return nil, fmt.Errorf("%w: content is required", ErrInvalidComparison)
+	}
+	if len(models) < 2 || len(models) > maxCompareModels {
+		return nil, fmt.Errorf("%w: compare 2 to %d models", ErrInvalidComparison, maxCompareModels)
+	}
+	for i, model := range models {
+		if slices.Contains(models[:i], model) {
+			return nil, fmt.Errorf("%w: model %q is listed twice", ErrInvalidComparison, model)
+		}
+		if _, err := s.models.Lookup(userID, model); err != nil {
+			return nil, err
+		}
+	}
+
+	chat, err := s.repo.FindByIDForOwner(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if chat == nil {
+		return nil, nil
+	}
+
+	// Check every budget before asking any model, so a refused comparison costs nothing
+	answering := make([]string, len(models))
+	for i, model := range models {
+		if answering[i], err = s.budgets.Check(userID, chat.ID, model); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(chat.Messages) == 0 {
+		chat.Name = content
+	}
+	chat.Messages = append(chat.Messages, domain.Message{
+		Role:      domain.UserRole,
+		Type:      "text",
+		Content:   content,
+		Timestamp: time.Now(),
+	})
+
+	alternates := make([]domain.Alternate, len(models))
+	var wg sync.WaitGroup
+	for i := range models {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			alternates[i] = s.alternate(chat, models[i], answering[i])
+		}()
+	}
+	wg.Wait()
+
+	var usages []*domain.Usage
+	selected := -1
+	for i, alternate := range alternates {
+		if alternate.Usage != nil {
+			usages = append(usages, alternate.Usage)
+		}
+		if alternate.Error == "" && selected < 0 {
+			selected = i
+		}
+	}
+	// Models that failed after calling tools still used tokens
+	if err := s.budgets.Record(userID, chat.ID, usages...); err != nil {
+		log.Printf("Failed to check budget thresholds: %v", err)
+	}
+	if selected < 0 {
+		return nil, fmt.Errorf("no model answered: %s", alternates[0].Error)
+	}
+	alternates[selected].Selected = true
+
+	chat.Messages = append(chat.Messages, domain.Message{
+		Role:       domain.BotRole,
+		Type:       "text",
+		Content:    alternates[selected].Content,
+		Usage:      alternates[selected].Usage,
+		Alternates: alternates,
+		Timestamp:  time.Now(),
+	})
+	if err := s.repo.Update(chat); err != nil {
+		return nil, err
+	}
+	return chat, nil
+}
+
+// alternate has model answer the last message of chat, through answering when a used-up budget
+// downgrades it. chat is not changed.
+func (s *ChatService) alternate(chat *domain.Chat, model string, answering string) domain.Alternate {
+	alternate := domain.Alternate{Model: model}
+	// Each model runs its tools on its own copy of the history. Tools with side effects would
+	// run once per model, so they are left out.
+	c := *chat
+	c.Messages = slices.Clone(chat.Messages)
+	msg, err := s.bot.respond(&c, answering, true)
+	if err != nil {
+		alternate.Error = err.Error()
+		alternate.Usage = msg.Usage
+		return alternate
+	}
+	for _, m := range c.Messages[len(chat.Messages):] {
+		if m.ToolCall != nil {
+			alternate.ToolCalls = append(alternate.ToolCalls, *m.ToolCall)
+		}
+	}
+	if answering != model {
+		msg.Usage.DowngradedFrom = model
+	}
+	alternate.Content = msg.Content
+	alternate.Usage = msg.Usage
+	return alternate
+}
+
+// SelectAlternate makes the alternate at position alternate the answer of the message at index
+// in chat id, as later messages will see it. It returns nil if the chat does not exist.
+func (s *ChatService) SelectAlternate(userID string, id string, index int, alternate int) (*domain.Chat, error) {
+	chat, err := s.repo.FindByIDForOwner(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if chat == nil {
+		return nil, nil
+	}
+	if index < 0 || index >= len(chat.Messages) {
+		return nil, fmt.Errorf("%w: message index out of range", ErrAlternateNotFound)
+	}
+	msg := &chat.Messages[index]
+	if alternate < 0 || alternate >= len(msg.Alternates) {
+		return nil, fmt.Errorf("%w: message %d has no alternate %d", ErrAlternateNotFound, index, alternate)
+	}
+	if msg.Alternates[alternate].Error != "" {
+		return nil, fmt.Errorf("%w: alternate %d has no answer", ErrInvalidComparison, alternate)
+	}
+
+	for i := range msg.Alternates {
+		msg.Alternates[i].Selected = i == alternate
+	}
+	msg.Content = msg.Alternates[alternate].Content
+	msg.Usage = msg.Alternates[alternate].Usage
+	if err := s.repo.Update(chat); err != nil {
+		return nil, err
+	}
+	return chat, nil
+}
@@ -0,0 +1,233 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"gemiwin/api/internal/domain"
+	"gemiwin/api/internal/mcp"
+)
+
+const (
+	// defaultMCPChats and maxMCPChats bound list_chats.
+	defaultMCPChats = 20
+	maxMCPChats     = 100
+	// defaultMCPMessages and maxMCPMessages bound how many of a chat's latest messages get_chat returns.
+	defaultMCPMessages = 20
+	maxMCPMessages     = 200
+	// maxMCPSearchResults caps search.
+	maxMCPSearchResults = 50
+)
+
+// chatMCPInstructions tell the client's model what the server is for.
+const chatMCPInstructions = "gemiwin stores conversations with Gemini. Use list_chats and search to find a chat, " +
+	"get_chat to read it, and send_message to ask Gemini a question in a new or existing chat."
+
+// ErrChatNotFound is returned by the MCP tools for chat ids the user does not have.
+var ErrChatNotFound = errors.New("chat not found")
+
+// mcpChatSummary is a chat as listed by list_chats.
+type mcpChatSummary struct {
+	ID            string     `json:"id"`
+	Name          string     `json:"name"`
+	CreatedAt     time.Time  `json:"created_at"`
+	LastMessageAt *time.Time `json:"last_message_at,omitempty"`
+	Messages      int        `json:"messages"`
+}
+
+// mcpChatMessage is a message as returned by get_chat, with attachments reduced to their names.
+type mcpChatMessage struct {
+	Index     int              `json:"index"`
+	Role      domain.Role      `json:"role"`
+	Type      string           `json:"type"`
+	Content   string           `json:"content"`
+	Documents []string         `json:"documents,omitempty"`
+	ToolCall  *domain.ToolCall `json:"tool_call,omitempty"`
+	Timestamp time.Time        `json:"timestamp"`
+}
+
+// readOnlyMCPTool marks the tools that only read chats, so clients may run them freely.
+var readOnlyMCPTool = &mcp.ToolAnnotations{ReadOnlyHint: true}
+
+// NewChatMCPServer returns an MCP server that lets other agents read, search and continue the
+// chats of userID.
+func NewChatMCPServer(chats *ChatService, userID string) *mcp.Server {
+	return mcp.NewServer(mcpClientInfo, chatMCPInstructions,
+		mcp.ServerTool{
+			Tool: mcp.Tool{
+				Name:        "list_chats",
+				Description: "Lists the user's chats, most recently active first.",
+				InputSchema: json.RawMessage(fmt.Sprintf(`{"type":"object","properties":{`+
+					`"limit":{"type":"integer","minimum":1,"maximum":%d,"description":"How many chats to return; defaults to %d"}}}`,
+					maxMCPChats, defaultMCPChats)),
+				Annotations: readOnlyMCPTool,
+			},
+			Handler: func(_ context.Context, args json.RawMessage) (*mcp.ToolResult, error) {
+				var params struct {
+					Limit int `json:"limit"`
+				}
+				if err := json.Unmarshal(args, &params); err != nil {
+					return nil, fmt.Errorf("invalid arguments: %w", err)
+				}
+				owned, err := chats.ListChats(userID)
+				if err != nil {
+					return nil, err
+				}
+				summaries := make([]mcpChatSummary, 0, len(owned))
+				for _, chat := range owned {
+					summary := mcpChatSummary{ID: chat.ID, Name: chat.Name, CreatedAt: chat.CreatedAt, Messages: len(chat.Messages)}
+					if n := len(chat.Messages); n > 0 {
+						summary.LastMessageAt = &chat.Messages[n-1].Timestamp
+					}
+					summaries = append(summaries, summary)
+				}
+				sort.Slice(summaries, func(i, j int) bool {
+					return lastActivity(summaries[i]).After(lastActivity(summaries[j]))
+				})
+				return jsonResult(summaries[:min(len(summaries), clampLimit(params.Limit, defaultMCPChats, maxMCPChats))])
+			},
+		},
+		mcp.ServerTool{
+			Tool: mcp.Tool{
+				Name:        "get_chat",
+				Description: "Returns a chat's name, model and latest messages, including the tools Gemini ran.",
+				InputSchema: json.RawMessage(fmt.Sprintf(`{"type":"object","properties":{`+
+					`"chat_id":{"type":"string"},`+
+					`"last":{"type":"integer","minimum":1,"maximum":%d,"description":"How many of the latest messages to return; defaults to %d"}},`+
+					`"required":["chat_id"]}`, maxMCPMessages, defaultMCPMessages)),
+				Annotations: readOnlyMCPTool,
+			},
+			Handler: func(_ context.Context, args json.RawMessage) (*mcp.ToolResult, error) {
+				var params struct {
+					ChatID string `json:"chat_id"`
+					Last   int    `json:"last"`
+				}
+				if err := json.Unmarshal(args, &params); err != nil {
+					return nil, fmt.Errorf("invalid arguments: %w", err)
+				}
+				chat, err := chats.GetChatByID(userID, params.ChatID)
+				if err != nil {
+					return nil, err
+				}
+				if chat == nil {
+					return nil, ErrChatNotFound
+				}
+				from := max(0, len(chat.Messages)-clampLimit(params.Last, defaultMCPMessages, maxMCPMessages))
+				messages := make([]mcpChatMessage, 0, len(chat.Messages)-from)
+				for i, msg := range chat.Messages[from:] {
+					names := make([]string, 0, len(msg.Documents))
+					for _, doc := range msg.Documents {
+						names = append(names, doc.Name)
+					}
+					messages = append(messages, mcpChatMessage{
+						Index:     from + i,
+						Role:      msg.Role,
+						Type:      msg.Type,
+						Content:   msg.Content,
+						Documents: names,
+						ToolCall:  msg.ToolCall,
+						Timestamp: msg.Timestamp,
+					})
+				}
+				return jsonResult(map[string]any{
+					"id":             chat.ID,
+					"name":           chat.Name,
+					"model":          chat.Config.Model,
+					"total_messages": len(chat.Messages),
+					"messages":       messages,
+				})
+			},
+		},
+		mcp.ServerTool{
+			Tool: mcp.Tool{
+				Name:        "search",
+				Description: "Finds messages in the user's chats containing a word or phrase, newest first.",
+				InputSchema: json.RawMessage(fmt.Sprintf(`{"type":"object","properties":{`+
+					`"query":{"type":"string","description":"Text to look for, case-insensitive"},`+
+					`"limit":{"type":"integer","minimum":1,"maximum":%d,"description":"How many matches to return; defaults to %d"}},`+
+					`"required":["query"]}`, maxMCPSearchResults, maxSearchResults)),
+				Annotations: readOnlyMCPTool,
+			},
+			Handler: func(_ context.Context, args json.RawMessage) (*mcp.ToolResult, error) {
+				var params struct {
+					Query string `json:"query"`
+					Limit int    `json:"limit"`
+				}
+				if err := json.Unmarshal(args, &params); err != nil {
+					return nil, fmt.Errorf("invalid arguments: %w", err)
+				}
+				query := strings.ToLower(strings.TrimSpace(params.Query))
+				if query == "" {
+					return nil, errors.New("query must not be empty")
+				}
+				owned, err := chats.ListChats(userID)
+				if err != nil {
+					return nil, err
+				}
+				return jsonResult(searchChats(owned, query, "", clampLimit(params.Limit, maxSearchResults, maxMCPSearchResults)))
+			},
+		},
+		mcp.ServerTool{
+			Tool: mcp.Tool{
+				Name: "send_message",
+				Description: "Sends a message to Gemini and returns its answer. The message is appended to the chat " +
+					"with chat_id, or starts a new chat when chat_id is omitted.",
+				InputSchema: json.RawMessage(`{"type":"object","properties":{` +
+					`"content":{"type":"string","description":"The message to send"},` +
+					`"chat_id":{"type":"string","description":"Chat to continue; omit to start a new chat"}},` +
+					`"required":["content"]}`),
+			},
+			Handler: func(_ context.Context, args json.RawMessage) (*mcp.ToolResult, error) {
+				var params struct {
+					Content string `json:"content"`
+					ChatID  string `json:"chat_id"`
+				}
+				if err := json.Unmarshal(args, &params); err != nil {
+					return nil, fmt.Errorf("invalid arguments: %w", err)
+				}
+				if strings.TrimSpace(params.Content) == "" {
+					return nil, errors.New("content must not be empty")
+				}
+				chat, err := chats.AddMessageToChat(userID, params.ChatID, params.Content, nil)
+				if err != nil {
+					return nil, err
+				}
+				if chat == nil {
+					return nil, ErrChatNotFound
+				}
+				return jsonResult(map[string]any{
+					"chat_id": chat.ID,
+					"answer":  chat.Messages[len(chat.Messages)-1].Content,
+				})
+			},
+		},
+	)
+}
+
+func lastActivity(chat mcpChatSummary) time.Time {
+	if chat.LastMessageAt == nil {
+		return chat.CreatedAt
+	}
+	return *chat.LastMessageAt
+}
+
+// clampLimit returns limit, or def when it is not set, capped at maxLimit.
+func clampLimit(limit int, def int, maxLimit int) int {
+	if limit <= 0 {
+		return def
+	}
+	return min(limit, maxLimit)
+}
+
+func jsonResult(v any) (*mcp.ToolResult, error) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return mcp.TextContent(string(data), false), nil
+}
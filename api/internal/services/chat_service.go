@@ -2,47 +2,66 @@ package services
 
 import (
 	"fmt"
-	"io/ioutil"
-	"os"
-	"path/filepath"
-	"strings"
+	"log"
 	"time"
 
 	"gemiwin/api/internal/domain"
 	"gemiwin/api/internal/persistence"
 
 	"github.com/google/uuid"
-	pdf "github.com/ledongthuc/pdf"
 )
 
 type ChatService struct {
-	repo *persistence.ChatRepository
-	bot  *BotService
+	repo         *persistence.ChatRepository
+	files        *persistence.FileRepository
+	bot          *BotService
+	storage      *StorageService
+	documents    *DocumentService
+	workspaces   *WorkspaceService
+	mcp          *MCPService
+	models       *ModelRegistry
+	budgets      *BudgetService
+	defaultModel string
 }
 
-func NewChatService(repo *persistence.ChatRepository, bot *BotService) *ChatService {
+// NewChatService creates a ChatService that adds uploaded files to the library in documents,
+// reads them back from files, releases them through storage, checks selected workspaces against
+// workspaces, enabled MCP servers against mcp and selected models against models, keeps answers
+// within the owner's budgets, and starts new chats with defaultModel unless the request selects
+// another one.
+func NewChatService(repo *persistence.ChatRepository, files *persistence.FileRepository, storage *StorageService, documents *DocumentService, workspaces *WorkspaceService, mcp *MCPService, models *ModelRegistry, budgets *BudgetService, bot *BotService, defaultModel string) *ChatService {
 	return &ChatService{
-		repo: repo,
-		bot:  bot,
+		repo:         repo,
+		files:        files,
+		storage:      storage,
+		documents:    documents,
+		workspaces:   workspaces,
+		mcp:          mcp,
+		models:       models,
+		budgets:      budgets,
+		bot:          bot,
+		defaultModel: defaultModel,
 	}
 }
 
-func (s *ChatService) GetChatByID(id string) (*domain.Chat, error) {
-	return s.repo.FindByID(id)
+// GetChatByID returns the chat with the given id, or nil if it does not exist or belongs to
+// another user.
+func (s *ChatService) GetChatByID(userID string, id string) (*domain.Chat, error) {
+	return s.repo.FindByIDForOwner(id, userID)
 }
 
-func (s *ChatService) AddMessageToChat(id string, content string, cfg *domain.ChatConfig) (*domain.Chat, error) {
+func (s *ChatService) AddMessageToChat(userID string, id string, content string, cfg *domain.ChatConfig) (*domain.Chat, error) {
 	var chat *domain.Chat
 	var err error
 
 	if id == "" {
-		// Determine initial configuration
-		initialCfg := domain.ChatConfig{Model: domain.DefaultModel}
-		if cfg != nil && cfg.Model != "" {
-			initialCfg = *cfg
+		initialCfg, err := s.initialConfig(userID, cfg)
+		if err != nil {
+			return nil, err
 		}
 		chat = &domain.Chat{
 			ID:        uuid.New().String(),
+			OwnerID:   userID,
 			Name:      content,
 			CreatedAt: time.Now(),
 			Config:    initialCfg,
@@ -52,7 +71,7 @@ func (s *ChatService) AddMessageToChat(id string, content string, cfg *domain.Ch
 			return nil, err
 		}
 	} else {
-		chat, err = s.repo.FindByID(id)
+		chat, err = s.repo.FindByIDForOwner(id, userID)
 		if err != nil {
 			return nil, err
 		}
@@ -69,23 +88,14 @@ func (s *ChatService) AddMessageToChat(id string, content string, cfg *domain.Ch
 		Role:      domain.UserRole,
 		Type:      "text",
 		Content:   content,
-		Document:  nil,
 		Timestamp: time.Now(),
 	}
 	chat.Messages = append(chat.Messages, userMessage)
 
-	botResponse, err := s.bot.GetBotResponse(chat)
+	botMessage, err := s.answer(chat)
 	if err != nil {
 		return nil, err
 	}
-
-	botMessage := domain.Message{
-		Role:      domain.BotRole,
-		Type:      "text",
-		Content:   botResponse,
-		Document:  nil,
-		Timestamp: time.Now(),
-	}
 	chat.Messages = append(chat.Messages, botMessage)
 
 	if err := s.repo.Update(chat); err != nil {
@@ -94,79 +104,207 @@ func (s *ChatService) AddMessageToChat(id string, content string, cfg *domain.Ch
 	return chat, nil
 }
 
-// AddFileToChat adds a file as a message. If id is empty, a new chat is created.
-func (s *ChatService) AddFileToChat(id string, userContent string, fileName string, fileBytes []byte, cfg *domain.ChatConfig) (*domain.Chat, string, error) {
-	// Step 1: get or create chat
-	defaultName := userContent
-	if defaultName == "" {
-		defaultName = fileName
+// answer generates the answer to the last message of chat within its owner's budgets. Once a
+// budget is used up, its downgrade model answers instead, or a *BudgetExceededError is returned.
+func (s *ChatService) answer(chat *domain.Chat) (domain.Message, error) {
+	configured := s.bot.chatModel(chat)
+	model, err := s.budgets.Check(chat.Owner(), chat.ID, configured)
+	if err != nil {
+		return domain.Message{}, err
+	}
+	msg, err := s.bot.GetBotResponse(chat, model)
+	if err != nil {
+		return domain.Message{}, err
+	}
+	if model != configured {
+		msg.Usage.DowngradedFrom = configured
+	}
+	if err := s.budgets.Record(chat.Owner(), chat.ID, msg.Usage); err != nil {
+		log.Printf("Failed to check budget thresholds: %v", err)
 	}
+	return msg, nil
+}
 
-	chat, err := s.getOrCreateChat(id, defaultName, cfg)
-	if err != nil || chat == nil {
-		return chat, "", err
+// Completion is a conversation sent through the OpenAI-compatible API.
+type Completion struct {
+	// Model overrides the chat's model for this answer when set.
+	Model string
+	// Instructions come from the conversation's system messages. With ChatID they replace the
+	// chat's instructions for this answer only.
+	Instructions string
+	// Messages is the whole conversation, or only the new user message when ChatID is set.
+	Messages []domain.Message
+	// Save stores a new conversation as a chat.
+	Save bool
+	// ChatID continues a stored chat instead; its history replaces the conversation's.
+	ChatID string
+}
+
+// Complete answers a conversation that did not start in gemiwin. The exchange is stored only if
+// req.Save or req.ChatID is set; otherwise the returned chat has no id and is discarded. It returns
+// nil if req.ChatID does not name one of userID's chats.
+func (s *ChatService) Complete(userID string, req Completion) (*domain.Chat, error) {
+	if req.Model != "" {
+		if _, err := s.models.Lookup(userID, req.Model); err != nil {
+			return nil, err
+		}
+	}
+
+	var chat *domain.Chat
+	if req.ChatID != "" {
+		var err error
+		if chat, err = s.repo.FindByIDForOwner(req.ChatID, userID); err != nil || chat == nil {
+			return nil, err
+		}
+		chat.Messages = append(chat.Messages, req.Messages...)
+	} else {
+		cfg, err := s.initialConfig(userID, &domain.ChatConfig{Model: req.Model})
+		if err != nil {
+			return nil, err
+		}
+		cfg.Instructions = req.Instructions
+		chat = &domain.Chat{OwnerID: userID, CreatedAt: time.Now(), Config: cfg, Messages: req.Messages}
+		for _, msg := range req.Messages {
+			if msg.Role == domain.UserRole {
+				chat.Name = msg.Content
+				break
+			}
+		}
+		if req.Save {
+			chat.ID = uuid.New().String()
+		}
 	}
 
-	// Step 2: persist file to disk
-	storedFileName, destPath, docURL, err := s.storeFile(fileName, fileBytes)
+	// A stored chat answers this request with its model and instructions, but keeps its own
+	asked := *chat
+	if req.Model != "" {
+		asked.Config.Model = req.Model
+	}
+	if req.Instructions != "" {
+		asked.Config.Instructions = req.Instructions
+	}
+	answer, err := s.answer(&asked)
 	if err != nil {
-		return nil, "", err
+		return nil, err
+	}
+	// The history includes the tool calls made while answering
+	chat.Messages = append(asked.Messages, answer)
+
+	switch {
+	case req.ChatID != "":
+		err = s.repo.Update(chat)
+	case req.Save:
+		err = s.repo.Create(chat)
 	}
+	if err != nil {
+		return nil, err
+	}
+	return chat, nil
+}
 
-	// Step 3: extract textual content (if any)
-	content := s.extractContent(destPath, filepath.Ext(fileName), fileBytes)
+// Upload is a file sent along with a message.
+type Upload struct {
+	Name string
+	Data []byte
+}
 
-	// Step 4: compose document metadata
-	document := &domain.Document{
-		ID:      storedFileName,
-		Name:    fileName,
-		URL:     docURL,
-		Content: content,
+// AddFileToChat adds a message with optional text, new files and documents from the user's
+// library, attached by id. Uploaded files are added to the library too, or attached from it if
+// the user has uploaded the same file before. If id is empty, a new chat is created. If any file
+// fails ValidateUpload, ErrUnsupportedFileType is returned, and if a library id is unknown,
+// ErrDocumentNotFound, before anything is stored.
+func (s *ChatService) AddFileToChat(userID string, id string, userContent string, uploads []Upload, documentIDs []string, cfg *domain.ChatConfig) (*domain.Chat, error) {
+	if len(uploads) == 0 && len(documentIDs) == 0 {
+		return nil, ErrNoFiles
+	}
+	for i := range uploads {
+		uploads[i].Name = SanitizeFileName(uploads[i].Name)
+		if err := ValidateUpload(uploads[i].Name, uploads[i].Data); err != nil {
+			return nil, fmt.Errorf("%s: %w", uploads[i].Name, err)
+		}
+	}
+	attached, err := s.documents.Attachments(userID, documentIDs)
+	if err != nil {
+		return nil, err
 	}
 
-	// Step 5: append user message with document. The message content comes from the request.
+	// Step 1: get or create chat
+	defaultName := userContent
+	if defaultName == "" {
+		if len(uploads) > 0 {
+			defaultName = uploads[0].Name
+		} else {
+			defaultName = attached[0].Name
+		}
+	}
+
+	chat, err := s.getOrCreateChat(userID, id, defaultName, cfg)
+	if err != nil || chat == nil {
+		return chat, err
+	}
+
+	// Step 2: add each file to the library, which stores it and extracts its text. They were
+	// validated above, before the chat was created.
+	documents := make([]domain.Document, 0, len(uploads)+len(attached))
+	for _, upload := range uploads {
+		doc, err := s.documents.addUpload(userID, upload)
+		if err != nil {
+			return nil, err
+		}
+		documents = append(documents, doc.Attachment())
+	}
+	documents = append(documents, attached...)
+
+	// Step 3: append user message with the documents. The message content comes from the request.
 	userMessage := domain.Message{
 		Role:      domain.UserRole,
 		Type:      "doc",
 		Content:   userContent,
-		Document:  document,
+		Documents: documents,
 		Timestamp: time.Now(),
 	}
 	chat.Messages = append(chat.Messages, userMessage)
 
-	// Step 6: let bot respond
-	botResponse, err := s.bot.GetBotResponse(chat)
+	// Step 4: let bot respond
+	botMessage, err := s.answer(chat)
 	if err != nil {
-		return nil, "", err
-	}
-
-	botMessage := domain.Message{
-		Role:      domain.BotRole,
-		Type:      "text",
-		Content:   botResponse,
-		Document:  nil,
-		Timestamp: time.Now(),
+		return nil, err
 	}
 	chat.Messages = append(chat.Messages, botMessage)
 
 	if err := s.repo.Update(chat); err != nil {
-		return nil, "", err
+		return nil, err
 	}
 
-	return chat, storedFileName, nil
+	return chat, nil
+}
+
+// AddURLToChat downloads rawURL into userID's library and adds it to the chat as a document
+// message with optional text. It returns nil if the chat does not exist.
+func (s *ChatService) AddURLToChat(userID string, id string, rawURL string, userContent string) (*domain.Chat, error) {
+	chat, err := s.repo.FindByIDForOwner(id, userID)
+	if err != nil || chat == nil {
+		return nil, err
+	}
+	doc, err := s.documents.CreateFromURL(userID, rawURL)
+	if err != nil {
+		return nil, err
+	}
+	return s.AddFileToChat(userID, id, userContent, nil, []string{doc.ID}, nil)
 }
 
 // getOrCreateChat returns the existing chat or creates a new one when id is empty.
-func (s *ChatService) getOrCreateChat(id string, defaultName string, cfg *domain.ChatConfig) (*domain.Chat, error) {
+func (s *ChatService) getOrCreateChat(userID string, id string, defaultName string, cfg *domain.ChatConfig) (*domain.Chat, error) {
 	var chat *domain.Chat
 
 	if id == "" {
-		initialCfg := domain.ChatConfig{Model: domain.DefaultModel}
-		if cfg != nil && cfg.Model != "" {
-			initialCfg = *cfg
+		initialCfg, err := s.initialConfig(userID, cfg)
+		if err != nil {
+			return nil, err
 		}
 		chat = &domain.Chat{
 			ID:        uuid.New().String(),
+			OwnerID:   userID,
 			Name:      defaultName,
 			CreatedAt: time.Now(),
 			Config:    initialCfg,
@@ -178,71 +316,89 @@ func (s *ChatService) getOrCreateChat(id string, defaultName string, cfg *domain
 		return chat, nil
 	}
 
-	chat, err := s.repo.FindByID(id)
-	if err != nil {
-		return nil, err
-	}
-	return chat, nil
+	return s.repo.FindByIDForOwner(id, userID)
 }
 
-// storeFile saves the uploaded bytes to disk and returns useful metadata.
-func (s *ChatService) storeFile(originalName string, data []byte) (storedFileName, filePath, docURL string, err error) {
-	filesDir := "data/files"
-	if err = os.MkdirAll(filesDir, 0755); err != nil {
-		return
+// initialConfig returns the configuration of a new chat: cfg with the default model filled in.
+// ErrUnknownModel, ErrWorkspaceNotFound or ErrMCPServerNotFound is returned if cfg selects a
+// model, workspace or MCP server userID does not have.
+func (s *ChatService) initialConfig(userID string, cfg *domain.ChatConfig) (domain.ChatConfig, error) {
+	initialCfg := domain.ChatConfig{Model: s.defaultModel}
+	if cfg == nil {
+		return initialCfg, nil
 	}
-
-	ext := filepath.Ext(originalName)
-	storedFileName = uuid.New().String() + ext
-	filePath = filepath.Join(filesDir, storedFileName)
-
-	if err = ioutil.WriteFile(filePath, data, 0644); err != nil {
-		return
+	if cfg.Model != "" {
+		if _, err := s.models.Lookup(userID, cfg.Model); err != nil {
+			return initialCfg, err
+		}
+		initialCfg.Model = cfg.Model
 	}
-
-	docURL = "/files/" + storedFileName
-	return
+	if err := s.workspaces.CheckOwned(userID, cfg.WorkspaceIDs); err != nil {
+		return initialCfg, err
+	}
+	initialCfg.WorkspaceIDs = cfg.WorkspaceIDs
+	if err := s.mcp.CheckConfigured(userID, cfg.MCPServers); err != nil {
+		return initialCfg, err
+	}
+	initialCfg.MCPServers = cfg.MCPServers
+	return initialCfg, nil
 }
 
-// extractContent derives textual content from common text-based files or PDFs.
-func (s *ChatService) extractContent(filePath string, ext string, data []byte) string {
-	ext = strings.ToLower(ext)
-	textExts := map[string]bool{".txt": true, ".md": true, ".markdown": true, ".json": true, ".yaml": true, ".yml": true, ".xml": true, ".csv": true, ".go": true, ".js": true, ".ts": true, ".py": true, ".java": true, ".c": true, ".cpp": true, ".rb": true, ".rs": true}
-
-	if textExts[ext] {
-		return string(data)
+// ReadDocument returns an uploaded file and its metadata if one of userID's chats or library
+// documents references it, or nil otherwise.
+func (s *ChatService) ReadDocument(userID string, name string) (*domain.Document, []byte, error) {
+	chats, err := s.repo.FindAllByOwner(userID)
+	if err != nil {
+		return nil, nil, err
 	}
-
-	if ext == ".pdf" {
-		if _, r, err := pdf.Open(filePath); err == nil {
-			var sb strings.Builder
-			for pageIndex := 1; pageIndex <= r.NumPage(); pageIndex++ {
-				p := r.Page(pageIndex)
-				if p.V.IsNull() {
+	for _, chat := range chats {
+		for _, msg := range chat.Messages {
+			for i := range msg.Documents {
+				if msg.Documents[i].ID != name {
 					continue
 				}
-				txt, _ := p.GetPlainText(nil)
-				sb.WriteString(txt)
+				data, err := s.files.Read(name)
+				if err != nil || data == nil {
+					return nil, nil, err
+				}
+				return &msg.Documents[i], data, nil
 			}
-			return sb.String()
 		}
 	}
 
-	return ""
+	libraryDoc, err := s.documents.FindByFileID(userID, name)
+	if err != nil || libraryDoc == nil {
+		return nil, nil, err
+	}
+	data, err := s.files.Read(name)
+	if err != nil || data == nil {
+		return nil, nil, err
+	}
+	doc := libraryDoc.Attachment()
+	return &doc, data, nil
 }
 
-func (s *ChatService) DeleteChatByID(id string) error {
-	return s.repo.Delete(id)
+// DeleteChatByID deletes the chat if it belongs to userID, along with files no other chat uses.
+// It reports whether a chat was deleted.
+func (s *ChatService) DeleteChatByID(userID string, id string) (bool, error) {
+	chat, err := s.repo.FindByIDForOwner(id, userID)
+	if err != nil || chat == nil {
+		return false, err
+	}
+	if err := s.repo.Delete(id); err != nil {
+		return true, err
+	}
+	return true, s.storage.RemoveUnreferenced(documentIDs(chat.Messages))
 }
 
-func (s *ChatService) ListChats() ([]*domain.Chat, error) {
-	return s.repo.FindAll()
+func (s *ChatService) ListChats(userID string) ([]*domain.Chat, error) {
+	return s.repo.FindAllByOwner(userID)
 }
 
 // DeleteMessagesFromIndex removes the message at the given index and all subsequent messages.
 // It returns the updated chat or nil if the chat does not exist.
-func (s *ChatService) DeleteMessagesFromIndex(id string, index int) (*domain.Chat, error) {
-	chat, err := s.repo.FindByID(id)
+func (s *ChatService) DeleteMessagesFromIndex(userID string, id string, index int) (*domain.Chat, error) {
+	chat, err := s.repo.FindByIDForOwner(id, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -255,18 +411,22 @@ func (s *ChatService) DeleteMessagesFromIndex(id string, index int) (*domain.Cha
 	}
 
 	// Keep messages before the specified index
+	removed := documentIDs(chat.Messages[index:])
 	chat.Messages = chat.Messages[:index]
 
 	if err := s.repo.Update(chat); err != nil {
 		return nil, err
 	}
+	if err := s.storage.RemoveUnreferenced(removed); err != nil {
+		return nil, err
+	}
 
 	return chat, nil
 }
 
 // UpdateChatConfig updates configuration fields of a chat identified by id.
-func (s *ChatService) UpdateChatConfig(id string, cfg domain.ChatConfig) (*domain.Chat, error) {
-	chat, err := s.repo.FindByID(id)
+func (s *ChatService) UpdateChatConfig(userID string, id string, cfg domain.ChatConfig) (*domain.Chat, error) {
+	chat, err := s.repo.FindByIDForOwner(id, userID)
 	if err != nil {
 		return nil, err
 	}
@@ -274,14 +434,24 @@ func (s *ChatService) UpdateChatConfig(id string, cfg domain.ChatConfig) (*domai
 		return nil, nil
 	}
 
-	// Validate model if provided
 	if cfg.Model != "" {
-		switch cfg.Model {
-		case domain.ModelGemini25Pro, domain.ModelGemini25Flash:
-			chat.Config.Model = cfg.Model
-		default:
-			return nil, fmt.Errorf("invalid model: %s", cfg.Model)
+		if _, err := s.models.Lookup(userID, cfg.Model); err != nil {
+			return nil, err
+		}
+		chat.Config.Model = cfg.Model
+	}
+	// An empty list clears the selection; omitting it keeps the current one
+	if cfg.WorkspaceIDs != nil {
+		if err := s.workspaces.CheckOwned(userID, cfg.WorkspaceIDs); err != nil {
+			return nil, err
+		}
+		chat.Config.WorkspaceIDs = cfg.WorkspaceIDs
+	}
+	if cfg.MCPServers != nil {
+		if err := s.mcp.CheckConfigured(userID, cfg.MCPServers); err != nil {
+			return nil, err
 		}
+		chat.Config.MCPServers = cfg.MCPServers
 	}
 
 	if err := s.repo.Update(chat); err != nil {
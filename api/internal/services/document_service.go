@@ -0,0 +1,192 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"gemiwin/api/internal/domain"
+	"gemiwin/api/internal/extract"
+	"gemiwin/api/internal/persistence"
+
+	"github.com/google/uuid"
+)
+
+// ErrDocumentNotFound is returned when a message attaches a library document the user does not have.
+var ErrDocumentNotFound = errors.New("document not found")
+
+// DocumentService manages each user's document library: files that are stored and extracted once
+// and can then be attached to messages in any chat.
+type DocumentService struct {
+	repo       *persistence.DocumentRepository
+	storage    *StorageService
+	extractors *extract.Registry
+	// pages extracts fetched web pages, keeping only their readable text
+	pages   *extract.Registry
+	fetcher *URLFetcher
+	// mu keeps two uploads of the same file from both being added
+	mu sync.Mutex
+}
+
+// NewDocumentService creates a DocumentService that stores files through storage, extracts
+// their text with extractors and downloads documents added by URL with fetcher.
+func NewDocumentService(repo *persistence.DocumentRepository, storage *StorageService, extractors *extract.Registry, fetcher *URLFetcher) *DocumentService {
+	return &DocumentService{
+		repo:       repo,
+		storage:    storage,
+		extractors: extractors,
+		pages:      extractors.With("text/html", extract.ReadableHTML),
+		fetcher:    fetcher,
+	}
+}
+
+// Create adds upload to userID's library, or returns the entry userID already has for the same
+// file. The upload must pass ValidateUpload.
+func (s *DocumentService) Create(userID string, upload Upload) (*domain.LibraryDocument, error) {
+	upload.Name = SanitizeFileName(upload.Name)
+	if err := ValidateUpload(upload.Name, upload.Data); err != nil {
+		return nil, fmt.Errorf("%s: %w", upload.Name, err)
+	}
+	return s.addUpload(userID, upload)
+}
+
+// addUpload is Create for an upload that has been sanitized and validated. A file counts as the
+// same if its content and extension match; documents added by URL are not reused.
+func (s *DocumentService) addUpload(userID string, upload Upload) (*domain.LibraryDocument, error) {
+	fileID := storedName(upload.Name, upload.Data)
+	if doc, err := s.findUpload(userID, fileID); err != nil || doc != nil {
+		return doc, err
+	}
+	// Extract from the upload itself: the stored copy may be encrypted
+	extracted := s.extractors.Extract(upload.Data)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Another request may have added the same file meanwhile
+	if doc, err := s.findUpload(userID, fileID); err != nil || doc != nil {
+		return doc, err
+	}
+	return s.create(userID, upload, extracted, "")
+}
+
+// findUpload returns userID's uploaded library document stored as fileID, or nil if there is none.
+func (s *DocumentService) findUpload(userID string, fileID string) (*domain.LibraryDocument, error) {
+	docs, err := s.repo.FindAllByOwner(userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, doc := range docs {
+		if doc.FileID == fileID && doc.SourceURL == "" {
+			return doc, nil
+		}
+	}
+	return nil, nil
+}
+
+// CreateFromURL downloads rawURL and adds it to userID's library. Web pages are reduced to their
+// readable text; other content is extracted like an upload and must be of an allowed type.
+func (s *DocumentService) CreateFromURL(userID string, rawURL string) (*domain.LibraryDocument, error) {
+	fetched, err := s.fetcher.Fetch(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	name, err := NameForContent(fetched.Name, fetched.Data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fetched.URL, err)
+	}
+
+	return s.create(userID, Upload{Name: name, Data: fetched.Data}, s.pages.Extract(fetched.Data), fetched.URL)
+}
+
+// create stores a validated upload and saves its library entry.
+func (s *DocumentService) create(userID string, upload Upload, extracted extract.Result, sourceURL string) (*domain.LibraryDocument, error) {
+	fileID, release, err := s.storage.Store(upload.Name, upload.Data)
+	if err != nil {
+		return nil, err
+	}
+	// Keep the file pinned until the library entry referencing it has been saved
+	defer release()
+
+	doc := &domain.LibraryDocument{
+		ID:         uuid.New().String(),
+		OwnerID:    userID,
+		Name:       upload.Name,
+		FileID:     fileID,
+		URL:        "/files/" + fileID,
+		MIMEType:   extracted.MIMEType,
+		Size:       int64(len(upload.Data)),
+		Content:    extracted.Text,
+		Extraction: &domain.Extraction{Status: extracted.Status, Warnings: extracted.Warnings},
+		SourceURL:  sourceURL,
+		CreatedAt:  time.Now(),
+	}
+	if err := s.repo.Save(doc); err != nil {
+		s.storage.RemoveUnreferenced([]string{fileID})
+		return nil, err
+	}
+	return doc, nil
+}
+
+// List returns userID's library, newest first. The extracted text is left out; use Get to read it.
+func (s *DocumentService) List(userID string) ([]*domain.LibraryDocument, error) {
+	docs, err := s.repo.FindAllByOwner(userID)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(docs, func(i, j int) bool { return docs[i].CreatedAt.After(docs[j].CreatedAt) })
+	for _, doc := range docs {
+		doc.Content = ""
+	}
+	return docs, nil
+}
+
+// Get returns the library document with the given id, or nil if userID has none.
+func (s *DocumentService) Get(userID string, id string) (*domain.LibraryDocument, error) {
+	return s.repo.FindByIDForOwner(id, userID)
+}
+
+// FindByFileID returns one of userID's library documents stored as fileID, or nil if there is none.
+func (s *DocumentService) FindByFileID(userID string, fileID string) (*domain.LibraryDocument, error) {
+	docs, err := s.repo.FindAllByOwner(userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, doc := range docs {
+		if doc.FileID == fileID {
+			return doc, nil
+		}
+	}
+	return nil, nil
+}
+
+// Delete removes the document from userID's library and reports whether it existed. Messages
+// it was attached to keep their copy; the file is deleted once nothing references it.
+func (s *DocumentService) Delete(userID string, id string) (bool, error) {
+	doc, err := s.repo.FindByIDForOwner(id, userID)
+	if err != nil || doc == nil {
+		return false, err
+	}
+	if err := s.repo.Delete(doc.ID); err != nil {
+		return true, err
+	}
+	return true, s.storage.RemoveUnreferenced([]string{doc.FileID})
+}
+
+// Attachments returns the message documents for the given library ids. ErrDocumentNotFound is
+// returned if any of them is not in userID's library.
+func (s *DocumentService) Attachments(userID string, ids []string) ([]domain.Document, error) {
+	attachments := make([]domain.Document, 0, len(ids))
+	for _, id := range ids {
+		doc, err := s.repo.FindByIDForOwner(id, userID)
+		if err != nil {
+			return nil, err
+		}
+		if doc == nil {
+			return nil, fmt.Errorf("%w: %s", ErrDocumentNotFound, id)
+		}
+		attachments = append(attachments, doc.Attachment())
+	}
+	return attachments, nil
+}
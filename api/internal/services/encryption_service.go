@@ -0,0 +1,114 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+
+	"gemiwin/api/internal/persistence"
+)
+
+// EncryptionStatus reports whether data is encrypted at rest and whether it can currently be read.
+type EncryptionStatus struct {
+	Enabled bool `json:"enabled"`
+	Locked  bool `json:"locked"`
+}
+
+// EncryptionService manages the passphrase-based encryption of the data directory.
+type EncryptionService struct {
+	root  string
+	vault *persistence.Vault
+	// unlocked run after each successful Unlock
+	unlocked []func()
+}
+
+// NewEncryptionService creates an EncryptionService for the data directory rooted at root.
+func NewEncryptionService(root string, vault *persistence.Vault) *EncryptionService {
+	return &EncryptionService{root: root, vault: vault}
+}
+
+// Status returns the current encryption status.
+func (s *EncryptionService) Status() *EncryptionStatus {
+	return &EncryptionStatus{Enabled: s.vault.Enabled(), Locked: s.vault.Locked()}
+}
+
+// Unlock derives the key from passphrase so encrypted data can be read and written.
+func (s *EncryptionService) Unlock(passphrase string) error {
+	if err := s.vault.Unlock(passphrase); err != nil {
+		return err
+	}
+	for _, fn := range s.unlocked {
+		fn()
+	}
+	return nil
+}
+
+// OnUnlock has fn run after the data is unlocked, for startup work that needs to read it.
+func (s *EncryptionService) OnUnlock(fn func()) {
+	s.unlocked = append(s.unlocked, fn)
+}
+
+// Enable turns on encryption and encrypts every existing chat, file, the app configuration and
+// the API keys. It returns the number of files that were encrypted.
+func (s *EncryptionService) Enable(passphrase string) (int, error) {
+	rekey, err := s.vault.Enable(passphrase)
+	if err != nil {
+		return 0, err
+	}
+	return s.reencryptAll(rekey)
+}
+
+// Rotate re-encrypts all data with a key derived from newPassphrase. It returns the number of
+// files that were re-encrypted.
+func (s *EncryptionService) Rotate(oldPassphrase string, newPassphrase string) (int, error) {
+	rekey, err := s.vault.Rotate(oldPassphrase, newPassphrase)
+	if err != nil {
+		return 0, err
+	}
+	return s.reencryptAll(rekey)
+}
+
+// reencryptAll rewrites every data file under the new key and then switches the vault over.
+// Writes are blocked for the duration so no file is written under the old key in between.
+func (s *EncryptionService) reencryptAll(rekey *persistence.Rekey) (int, error) {
+	count := 0
+	err := persistence.Exclusive(func(tx persistence.Tx) error {
+		paths, err := persistence.ListDataFiles(s.root)
+		if err != nil {
+			return err
+		}
+		for _, rel := range paths {
+			path := filepath.Join(s.root, filepath.FromSlash(rel))
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			encoded, err := rekey.Reencode(data)
+			if err != nil {
+				return err
+			}
+			if err := tx.WriteFile(path, encoded); err != nil {
+				return err
+			}
+			count++
+		}
+
+		// The API keys are already encrypted with their own key, which is kept outside the data
+		// directory; the vault adds the passphrase on top
+		path := filepath.Join(s.root, SecretsFile)
+		data, err := os.ReadFile(path)
+		if err == nil {
+			encoded, err := rekey.Reencode(data)
+			if err != nil {
+				return err
+			}
+			if err := tx.WritePrivateFile(path, encoded); err != nil {
+				return err
+			}
+			count++
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+		return rekey.Commit(tx)
+	})
+	return count, err
+}
@@ -0,0 +1,394 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"gemiwin/api/internal/domain"
+	"gemiwin/api/internal/persistence"
+)
+
+// Supported export formats.
+const (
+	ExportFormatMarkdown = "md"
+	ExportFormatHTML     = "html"
+	ExportFormatJSON     = "json"
+	ExportFormatText     = "txt"
+	ExportFormatPDF      = "pdf"
+)
+
+// ErrUnsupportedExportFormat is returned when the requested export format is unknown.
+var ErrUnsupportedExportFormat = errors.New("unsupported export format")
+
+const exportTimeLayout = "2006-01-02 15:04:05 MST"
+
+// ExportedFile is a rendered chat ready to be downloaded.
+type ExportedFile struct {
+	FileName    string
+	ContentType string
+	Data        []byte
+}
+
+// ExportService renders chats into portable formats and bundles full archives.
+type ExportService struct {
+	repo  *persistence.ChatRepository
+	files *persistence.FileRepository
+}
+
+// NewExportService creates an ExportService that reads attached documents from files.
+func NewExportService(repo *persistence.ChatRepository, files *persistence.FileRepository) *ExportService {
+	return &ExportService{repo: repo, files: files}
+}
+
+// ExportChat renders userID's chat identified by id in the given format. Document links are
+// prefixed with baseURL so they remain usable outside the app. It returns nil if the chat does not exist.
+func (s *ExportService) ExportChat(userID string, id string, format string, baseURL string) (*ExportedFile, error) {
+	chat, err := s.repo.FindByIDForOwner(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if chat == nil {
+		return nil, nil
+	}
+
+	data, contentType, err := renderChat(chat, format, baseURL+"/files/")
+	if err != nil {
+		return nil, err
+	}
+
+	return &ExportedFile{
+		FileName:    exportFileName(chat) + "." + format,
+		ContentType: contentType,
+		Data:        data,
+	}, nil
+}
+
+// ExportAll writes a zip archive with every chat of userID (as JSON and Markdown) and the files they reference.
+func (s *ExportService) ExportAll(userID string, w io.Writer) error {
+	chats, err := s.repo.FindAllByOwner(userID)
+	if err != nil {
+		return err
+	}
+	sort.Slice(chats, func(i, j int) bool { return chats[i].CreatedAt.Before(chats[j].CreatedAt) })
+
+	zw := zip.NewWriter(w)
+	written := make(map[string]bool)
+
+	for _, chat := range chats {
+		jsonData, _, err := renderChat(chat, ExportFormatJSON, "")
+		if err != nil {
+			return err
+		}
+		if err := writeZipEntry(zw, "chats/"+chat.ID+".json", jsonData); err != nil {
+			return err
+		}
+
+		mdData, _, err := renderChat(chat, ExportFormatMarkdown, "../files/")
+		if err != nil {
+			return err
+		}
+		if err := writeZipEntry(zw, "chats/"+chat.ID+".md", mdData); err != nil {
+			return err
+		}
+
+		for _, msg := range chat.Messages {
+			for _, doc := range msg.Documents {
+				if doc.ID == "" || written[doc.ID] {
+					continue
+				}
+				data, err := s.files.Read(doc.ID)
+				if err != nil {
+					return err
+				}
+				if data == nil {
+					// The file was removed from disk; keep exporting the rest.
+					continue
+				}
+				if err := writeZipEntry(zw, "files/"+doc.ID, data); err != nil {
+					return err
+				}
+				written[doc.ID] = true
+			}
+		}
+	}
+
+	return zw.Close()
+}
+
+func writeZipEntry(zw *zip.Writer, name string, data []byte) error {
+	f, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	return err
+}
+
+// renderChat converts a chat into the requested format. filesURL is the prefix used for document links.
+func renderChat(chat *domain.Chat, format string, filesURL string) ([]byte, string, error) {
+	switch format {
+	case ExportFormatMarkdown:
+		return []byte(renderMarkdown(chat, filesURL)), "text/markdown; charset=utf-8", nil
+	case ExportFormatHTML:
+		return []byte(renderHTML(chat, filesURL)), "text/html; charset=utf-8", nil
+	case ExportFormatJSON:
+		data, err := json.MarshalIndent(chat, "", "  ")
+		if err != nil {
+			return nil, "", err
+		}
+		return data, "application/json", nil
+	case ExportFormatText:
+		return []byte(renderText(chat, filesURL)), "text/plain; charset=utf-8", nil
+	case ExportFormatPDF:
+		return renderPDF(renderText(chat, filesURL)), "application/pdf", nil
+	default:
+		return nil, "", fmt.Errorf("%w: %s", ErrUnsupportedExportFormat, format)
+	}
+}
+
+func renderMarkdown(chat *domain.Chat, filesURL string) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("# %s\n\n", chat.Name))
+	sb.WriteString(fmt.Sprintf("- **Created:** %s\n", chat.CreatedAt.Format(exportTimeLayout)))
+	sb.WriteString(fmt.Sprintf("- **Model:** %s\n\n", chatModel(chat)))
+
+	for _, msg := range chat.Messages {
+		sb.WriteString(fmt.Sprintf("## %s · %s\n\n", roleLabel(msg.Role), msg.Timestamp.Format(exportTimeLayout)))
+		for i := range msg.Documents {
+			sb.WriteString(fmt.Sprintf("📎 [%s](%s)\n\n", msg.Documents[i].Name, documentLink(&msg.Documents[i], filesURL)))
+		}
+		if msg.Content != "" {
+			sb.WriteString(msg.Content)
+			sb.WriteString("\n\n")
+		}
+	}
+	return sb.String()
+}
+
+func renderHTML(chat *domain.Chat, filesURL string) string {
+	var sb strings.Builder
+	sb.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n")
+	sb.WriteString(fmt.Sprintf("<title>%s</title>\n", html.EscapeString(chat.Name)))
+	sb.WriteString("<style>body{font-family:sans-serif;max-width:48rem;margin:2rem auto;padding:0 1rem}" +
+		".message{margin:1rem 0;padding:.75rem 1rem;border-radius:.5rem}" +
+		".user{background:#eef2ff}.bot{background:#f4f4f5}" +
+		".meta{color:#71717a;font-size:.8rem;margin-bottom:.5rem}" +
+		".content{white-space:pre-wrap}</style>\n")
+	sb.WriteString("</head>\n<body>\n")
+	sb.WriteString(fmt.Sprintf("<h1>%s</h1>\n", html.EscapeString(chat.Name)))
+	sb.WriteString(fmt.Sprintf("<p class=\"meta\">Created %s · Model %s</p>\n",
+		html.EscapeString(chat.CreatedAt.Format(exportTimeLayout)), html.EscapeString(chatModel(chat))))
+
+	for _, msg := range chat.Messages {
+		sb.WriteString(fmt.Sprintf("<div class=\"message %s\">\n", html.EscapeString(string(msg.Role))))
+		sb.WriteString(fmt.Sprintf("<div class=\"meta\">%s · %s</div>\n",
+			roleLabel(msg.Role), html.EscapeString(msg.Timestamp.Format(exportTimeLayout))))
+		for i := range msg.Documents {
+			sb.WriteString(fmt.Sprintf("<div class=\"document\">📎 <a href=\"%s\">%s</a></div>\n",
+				html.EscapeString(documentLink(&msg.Documents[i], filesURL)), html.EscapeString(msg.Documents[i].Name)))
+		}
+		if msg.Content != "" {
+			sb.WriteString(fmt.Sprintf("<div class=\"content\">%s</div>\n", html.EscapeString(msg.Content)))
+		}
+		sb.WriteString("</div>\n")
+	}
+
+	sb.WriteString("</body>\n</html>\n")
+	return sb.String()
+}
+
+func renderText(chat *domain.Chat, filesURL string) string {
+	var sb strings.Builder
+	sb.WriteString(chat.Name + "\n")
+	sb.WriteString(fmt.Sprintf("Created: %s\n", chat.CreatedAt.Format(exportTimeLayout)))
+	sb.WriteString(fmt.Sprintf("Model: %s\n\n", chatModel(chat)))
+
+	for _, msg := range chat.Messages {
+		sb.WriteString(fmt.Sprintf("[%s] %s:\n", msg.Timestamp.Format(exportTimeLayout), roleLabel(msg.Role)))
+		for i := range msg.Documents {
+			sb.WriteString(fmt.Sprintf("Attachment: %s (%s)\n", msg.Documents[i].Name, documentLink(&msg.Documents[i], filesURL)))
+		}
+		if msg.Content != "" {
+			sb.WriteString(msg.Content + "\n")
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+func chatModel(chat *domain.Chat) string {
+	if chat.Config.Model == "" {
+		return domain.DefaultModel
+	}
+	return chat.Config.Model
+}
+
+func roleLabel(role domain.Role) string {
+	switch role {
+	case domain.UserRole:
+		return "User"
+	case domain.BotRole:
+		return "Bot"
+	default:
+		return string(role)
+	}
+}
+
+func documentLink(doc *domain.Document, filesURL string) string {
+	if doc.ID == "" {
+		return doc.URL
+	}
+	return filesURL + doc.ID
+}
+
+// exportFileName derives a filesystem-friendly name from the chat name.
+func exportFileName(chat *domain.Chat) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		case r == ' ':
+			return '-'
+		default:
+			return -1
+		}
+	}, chat.Name)
+	if len(name) > 60 {
+		name = name[:60]
+	}
+	if name == "" {
+		name = "chat-" + chat.ID
+	}
+	return name
+}
+
+// ExportArchiveName returns the file name used for bulk export archives.
+func ExportArchiveName(now time.Time) string {
+	return "gemiwin-export-" + now.Format("20060102-150405") + ".zip"
+}
+
+// renderPDF lays out plain text on Letter-sized pages using the built-in Helvetica font.
+// It is intentionally minimal: characters outside Latin-1 are replaced with '?'.
+func renderPDF(text string) []byte {
+	const (
+		pageWidth    = 612
+		pageHeight   = 792
+		margin       = 54
+		fontSize     = 10
+		lineHeight   = 14
+		charsPerLine = 95
+	)
+	linesPerPage := (pageHeight - 2*margin) / lineHeight
+
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		lines = append(lines, wrapLine(line, charsPerLine)...)
+	}
+
+	var pages [][]string
+	for len(lines) > 0 {
+		n := linesPerPage
+		if n > len(lines) {
+			n = len(lines)
+		}
+		pages = append(pages, lines[:n])
+		lines = lines[n:]
+	}
+	if len(pages) == 0 {
+		pages = append(pages, []string{""})
+	}
+
+	// Object layout: 1 catalog, 2 pages tree, 3 font, then (page, content) pairs.
+	var objects []string
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 4+2*i)
+	}
+	objects = append(objects,
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>",
+	)
+
+	for i, page := range pages {
+		var content bytes.Buffer
+		content.WriteString(fmt.Sprintf("BT /F1 %d Tf %d TL %d %d Td\n", fontSize, lineHeight, margin, pageHeight-margin))
+		for _, line := range page {
+			content.WriteString("(" + escapePDFString(line) + ") Tj T*\n")
+		}
+		content.WriteString("ET")
+
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>",
+				pageWidth, pageHeight, 5+2*i),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", content.Len(), content.String()),
+		)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		buf.WriteString(fmt.Sprintf("%d 0 obj\n%s\nendobj\n", i+1, obj))
+	}
+
+	xref := buf.Len()
+	buf.WriteString(fmt.Sprintf("xref\n0 %d\n0000000000 65535 f \n", len(objects)+1))
+	for _, off := range offsets {
+		buf.WriteString(fmt.Sprintf("%010d 00000 n \n", off))
+	}
+	buf.WriteString(fmt.Sprintf("trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref))
+	return buf.Bytes()
+}
+
+// wrapLine splits a line into chunks of at most width characters, preferring word boundaries.
+func wrapLine(line string, width int) []string {
+	runes := []rune(strings.ReplaceAll(line, "\t", "    "))
+	if len(runes) <= width {
+		return []string{string(runes)}
+	}
+
+	var out []string
+	for len(runes) > width {
+		cut := width
+		for i := width; i > width/2; i-- {
+			if runes[i] == ' ' {
+				cut = i
+				break
+			}
+		}
+		out = append(out, string(runes[:cut]))
+		runes = []rune(strings.TrimLeft(string(runes[cut:]), " "))
+	}
+	return append(out, string(runes))
+}
+
+// escapePDFString encodes text as a Latin-1 PDF literal string.
+func escapePDFString(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			sb.WriteByte('\\')
+			sb.WriteRune(r)
+		case r < 32:
+			sb.WriteByte(' ')
+		case r < 128:
+			sb.WriteRune(r)
+		case r < 256:
+			sb.WriteString(fmt.Sprintf("\\%03o", r))
+		default:
+			sb.WriteByte('?')
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,303 @@
+package services
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"gemiwin/api/internal/domain"
+)
+
+// geminiInlineTypes are the MIME types the Gemini API accepts as inline data.
+var geminiInlineTypes = map[string]bool{
+	"image/png": true, "image/jpeg": true, "image/webp": true, "image/heic": true, "image/heif": true,
+	"audio/wav": true, "audio/mpeg": true, "audio/aiff": true, "audio/aac": true, "audio/ogg": true,
+	"audio/flac": true, "application/pdf": true,
+}
+
+// GeminiAPIBackend calls the generateContent endpoint of the Gemini REST API. Images, audio and
+// PDFs are sent as inline data so the model sees the original file.
+type GeminiAPIBackend struct {
+	baseURL string
+	client  *http.Client
+}
+
+// NewGeminiAPIBackend creates a GeminiAPIBackend for the API at baseURL, e.g.
+// https://generativelanguage.googleapis.com/v1beta.
+func NewGeminiAPIBackend(baseURL string) *GeminiAPIBackend {
+	return &GeminiAPIBackend{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		client:  &http.Client{Timeout: 5 * time.Minute},
+	}
+}
+
+func (b *GeminiAPIBackend) SupportsInline(mimeType string) bool {
+	return geminiInlineTypes[mimeType]
+}
+
+// Function parts use the camelCase names the API answers with, so responses decode into them too.
+type geminiPart struct {
+	Text             string                  `json:"text,omitempty"`
+	InlineData       *geminiInlineData       `json:"inline_data,omitempty"`
+	FunctionCall     *geminiFunctionCall     `json:"functionCall,omitempty"`
+	FunctionResponse *geminiFunctionResponse `json:"functionResponse,omitempty"`
+}
+
+type geminiFunctionCall struct {
+	Name string          `json:"name"`
+	Args json.RawMessage `json:"args,omitempty"`
+}
+
+type geminiFunctionResponse struct {
+	Name     string         `json:"name"`
+	Response map[string]any `json:"response"`
+}
+
+type geminiFunctionDeclaration struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// Parameters is sent as a full JSON schema; the OpenAPI subset of the "parameters" field
+	// rejects keywords common in MCP tool schemas, such as additionalProperties
+	Parameters json.RawMessage `json:"parameters_json_schema"`
+}
+
+type geminiTool struct {
+	FunctionDeclarations []geminiFunctionDeclaration `json:"function_declarations"`
+}
+
+type geminiToolConfig struct {
+	FunctionCallingConfig struct {
+		Mode string `json:"mode"`
+	} `json:"function_calling_config"`
+}
+
+type geminiInlineData struct {
+	MIMEType string `json:"mime_type"`
+	Data     string `json:"data"`
+}
+
+type geminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []geminiPart `json:"parts"`
+}
+
+type geminiRequest struct {
+	SystemInstruction *geminiContent    `json:"system_instruction,omitempty"`
+	Contents          []geminiContent   `json:"contents"`
+	Tools             []geminiTool      `json:"tools,omitempty"`
+	ToolConfig        *geminiToolConfig `json:"tool_config,omitempty"`
+}
+
+type geminiResponse struct {
+	Candidates []struct {
+		Content      geminiContent `json:"content"`
+		FinishReason string        `json:"finishReason"`
+	} `json:"candidates"`
+	PromptFeedback struct {
+		BlockReason string `json:"blockReason"`
+	} `json:"promptFeedback"`
+	UsageMetadata *struct {
+		PromptTokenCount     int `json:"promptTokenCount"`
+		CandidatesTokenCount int `json:"candidatesTokenCount"`
+		ThoughtsTokenCount   int `json:"thoughtsTokenCount"`
+	} `json:"usageMetadata"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (b *GeminiAPIBackend) Generate(req *BotRequest) (*BotReply, error) {
+	if req.APIKey == "" {
+		return nil, errors.New("the Gemini API backend requires an API key")
+	}
+
+	body := geminiRequest{
+		SystemInstruction: &geminiContent{Parts: []geminiPart{{Text: req.Instructions}}},
+		Contents:          make([]geminiContent, 0, len(req.Turns)),
+	}
+	if len(req.Tools) > 0 {
+		declarations := make([]geminiFunctionDeclaration, 0, len(req.Tools))
+		for _, tool := range req.Tools {
+			declarations = append(declarations, geminiFunctionDeclaration{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.Parameters,
+			})
+		}
+		body.Tools = []geminiTool{{FunctionDeclarations: declarations}}
+		if req.ForceAnswer {
+			body.ToolConfig = &geminiToolConfig{}
+			body.ToolConfig.FunctionCallingConfig.Mode = "NONE"
+		}
+	}
+	for _, turn := range req.Turns {
+		if turn.ToolCall != nil {
+			body.Contents = append(body.Contents, geminiToolContents(turn.ToolCall)...)
+			continue
+		}
+		content := geminiContent{Role: "user"}
+		if turn.Role == domain.BotRole {
+			content.Role = "model"
+		}
+		if turn.Text != "" {
+			content.Parts = append(content.Parts, geminiPart{Text: turn.Text})
+		}
+		for _, inline := range turn.Inline {
+			content.Parts = append(content.Parts, geminiPart{InlineData: &geminiInlineData{
+				MIMEType: inline.MIMEType,
+				Data:     base64.StdEncoding.EncodeToString(inline.Data),
+			}})
+		}
+		if len(content.Parts) > 0 {
+			body.Contents = append(body.Contents, content)
+		}
+	}
+
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	endpoint := fmt.Sprintf("%s/models/%s:generateContent", b.baseURL, url.PathEscape(req.Model))
+	httpReq, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("x-goog-api-key", req.APIKey)
+
+	resp, err := b.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("error calling the Gemini API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 16<<20))
+	if err != nil {
+		return nil, fmt.Errorf("error reading the Gemini API response: %w", err)
+	}
+	var parsed geminiResponse
+	if err := json.Unmarshal(raw, &parsed); err != nil {
+		return nil, fmt.Errorf("unexpected Gemini API response (status %d)", resp.StatusCode)
+	}
+	if resp.StatusCode != http.StatusOK {
+		if parsed.Error != nil && parsed.Error.Message != "" {
+			return nil, fmt.Errorf("gemini API error (status %d): %s", resp.StatusCode, parsed.Error.Message)
+		}
+		return nil, fmt.Errorf("gemini API error (status %d)", resp.StatusCode)
+	}
+	if parsed.PromptFeedback.BlockReason != "" {
+		return nil, fmt.Errorf("the prompt was blocked: %s", parsed.PromptFeedback.BlockReason)
+	}
+	if len(parsed.Candidates) == 0 {
+		return nil, errors.New("the Gemini API returned no answer")
+	}
+
+	reply := &BotReply{}
+	// Thinking is billed as output
+	if u := parsed.UsageMetadata; u != nil {
+		reply.Usage = &TokenCount{Input: u.PromptTokenCount, Output: u.CandidatesTokenCount + u.ThoughtsTokenCount}
+	}
+	var answer strings.Builder
+	for _, part := range parsed.Candidates[0].Content.Parts {
+		if part.FunctionCall != nil {
+			reply.ToolCalls = append(reply.ToolCalls, domain.ToolCall{Name: part.FunctionCall.Name, Arguments: part.FunctionCall.Args})
+			continue
+		}
+		answer.WriteString(part.Text)
+	}
+	reply.Text = strings.TrimSpace(answer.String())
+	return reply, nil
+}
+
+// geminiToolContents renders a tool call from the history as the model's function call followed
+// by the function's response.
+func geminiToolContents(call *domain.ToolCall) []geminiContent {
+	response := map[string]any{"result": call.Result}
+	if call.Error != "" {
+		response = map[string]any{"error": call.Error}
+	}
+	return []geminiContent{
+		{Role: "model", Parts: []geminiPart{{FunctionCall: &geminiFunctionCall{Name: call.Name, Args: call.Arguments}}}},
+		{Role: "user", Parts: []geminiPart{{FunctionResponse: &geminiFunctionResponse{Name: call.Name, Response: response}}}},
+	}
+}
+
+type geminiModel struct {
+	Name                       string   `json:"name"`
+	DisplayName                string   `json:"displayName"`
+	Description                string   `json:"description"`
+	InputTokenLimit            int      `json:"inputTokenLimit"`
+	OutputTokenLimit           int      `json:"outputTokenLimit"`
+	SupportedGenerationMethods []string `json:"supportedGenerationMethods"`
+}
+
+type geminiModelList struct {
+	Models        []geminiModel `json:"models"`
+	NextPageToken string        `json:"nextPageToken"`
+	Error         *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// ListModels asks the API which models the key can use to generate content. Without a key there is
+// nothing to ask, and no models are reported.
+func (b *GeminiAPIBackend) ListModels(apiKey string) ([]domain.Model, error) {
+	if apiKey == "" {
+		return nil, nil
+	}
+	var models []domain.Model
+	pageToken := ""
+	for {
+		query := url.Values{"pageSize": {"1000"}}
+		if pageToken != "" {
+			query.Set("pageToken", pageToken)
+		}
+		httpReq, err := http.NewRequest(http.MethodGet, b.baseURL+"/models?"+query.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+		httpReq.Header.Set("x-goog-api-key", apiKey)
+
+		resp, err := b.client.Do(httpReq)
+		if err != nil {
+			return nil, fmt.Errorf("error calling the Gemini API: %w", err)
+		}
+		var page geminiModelList
+		err = json.NewDecoder(io.LimitReader(resp.Body, 16<<20)).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("unexpected Gemini API response (status %d)", resp.StatusCode)
+		}
+		if resp.StatusCode != http.StatusOK {
+			if page.Error != nil && page.Error.Message != "" {
+				return nil, fmt.Errorf("gemini API error (status %d): %s", resp.StatusCode, page.Error.Message)
+			}
+			return nil, fmt.Errorf("gemini API error (status %d)", resp.StatusCode)
+		}
+
+		for _, m := range page.Models {
+			if !slices.Contains(m.SupportedGenerationMethods, "generateContent") {
+				continue
+			}
+			models = append(models, domain.Model{
+				ID:            strings.TrimPrefix(m.Name, "models/"),
+				DisplayName:   m.DisplayName,
+				Description:   m.Description,
+				ContextWindow: m.InputTokenLimit,
+				OutputTokens:  m.OutputTokenLimit,
+			})
+		}
+		if page.NextPageToken == "" {
+			return models, nil
+		}
+		pageToken = page.NextPageToken
+	}
+}
@@ -0,0 +1,143 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"gemiwin/api/internal/domain"
+)
+
+// toolCallPrefix starts a reply in which the CLI model asks for a tool, since the CLI has no
+// native function calling.
+const toolCallPrefix = "TOOL_CALL"
+
+// GeminiCLIBackend runs the gemini command line tool with the conversation on stdin. It only
+// accepts text; tools are described in the prompt and requested with a TOOL_CALL line.
+type GeminiCLIBackend struct{}
+
+// NewGeminiCLIBackend creates a GeminiCLIBackend.
+func NewGeminiCLIBackend() *GeminiCLIBackend {
+	return &GeminiCLIBackend{}
+}
+
+func (b *GeminiCLIBackend) SupportsInline(mimeType string) bool {
+	return false
+}
+
+func (b *GeminiCLIBackend) Generate(req *BotRequest) (*BotReply, error) {
+	var conversation strings.Builder
+
+	conversation.WriteString(req.Instructions)
+	if len(req.Tools) > 0 && !req.ForceAnswer {
+		writeToolInstructions(&conversation, req.Tools)
+	}
+	conversation.WriteString("Conversation: \n")
+	for _, turn := range req.Turns {
+		if turn.ToolCall != nil {
+			writeToolTurn(&conversation, turn)
+			continue
+		}
+		conversation.WriteString(fmt.Sprintf("%s: %s\n", turn.Role, turn.Text))
+	}
+	conversation.WriteString("Your answer:")
+
+	cmd := exec.Command("gemini")
+
+	// We prepare the standard input of the command with the content of the conversation
+	cmd.Stdin = strings.NewReader(conversation.String())
+
+	// Prepare environment variables
+	env := os.Environ()
+	if req.APIKey != "" {
+		env = append(env, "GEMINI_API_KEY="+req.APIKey)
+	}
+	env = append(env, "GEMINI_MODEL="+req.Model)
+	cmd.Env = env
+
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("error executing gemini command: %w, stderr: %s", err, stderr.String())
+	}
+
+	answer := strings.TrimSpace(out.String())
+	if call, ok := parseToolCall(answer); ok && !req.ForceAnswer {
+		return &BotReply{ToolCalls: []domain.ToolCall{call}}, nil
+	}
+	return &BotReply{Text: answer}, nil
+}
+
+func writeToolInstructions(w *strings.Builder, tools []Tool) {
+	w.WriteString("You can run these tools before answering:\n")
+	for _, tool := range tools {
+		fmt.Fprintf(w, "- %s: %s Arguments (JSON schema): %s\n", tool.Name, tool.Description, tool.Parameters)
+	}
+	fmt.Fprintf(w, "To run a tool, reply with nothing but one line: %s {\"name\": \"<tool>\", \"arguments\": {...}}\n", toolCallPrefix)
+	w.WriteString("The result will be added to the conversation. Only run a tool when it helps answer the final question.\n")
+}
+
+func writeToolTurn(w *strings.Builder, turn Turn) {
+	call := turn.ToolCall
+	request, _ := json.Marshal(struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments,omitempty"`
+	}{call.Name, call.Arguments})
+	fmt.Fprintf(w, "%s: %s %s\n", turn.Role, toolCallPrefix, request)
+	if call.Error != "" {
+		fmt.Fprintf(w, "tool error: %s\n", call.Error)
+	} else {
+		fmt.Fprintf(w, "tool result: %s\n", call.Result)
+	}
+}
+
+// parseToolCall recognizes a reply that consists of a TOOL_CALL line, possibly inside a code fence.
+func parseToolCall(answer string) (domain.ToolCall, bool) {
+	answer = strings.TrimSpace(strings.Trim(answer, "`"))
+	answer = strings.TrimSpace(strings.TrimPrefix(answer, "json"))
+	rest, ok := strings.CutPrefix(answer, toolCallPrefix)
+	if !ok {
+		return domain.ToolCall{}, false
+	}
+	var request struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	}
+	if err := json.Unmarshal([]byte(strings.TrimSpace(rest)), &request); err != nil || request.Name == "" {
+		return domain.ToolCall{}, false
+	}
+	return domain.ToolCall{Name: request.Name, Arguments: request.Arguments}, true
+}
+
+// cliProbeTimeout bounds running the CLI to check that it is installed.
+const cliProbeTimeout = 15 * time.Second
+
+// ListModels checks that the gemini command runs and returns the built-in models. The CLI cannot
+// list models, and it only passes text to them.
+func (b *GeminiCLIBackend) ListModels(apiKey string) ([]domain.Model, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), cliProbeTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, "gemini", "--version").Output()
+	if err != nil {
+		return nil, fmt.Errorf("the gemini command is not usable: %w", err)
+	}
+	version := strings.TrimSpace(string(out))
+
+	models := make([]domain.Model, 0, len(builtinModels))
+	for _, model := range builtinModels {
+		models = append(models, domain.Model{
+			ID:              model.ID,
+			Description:     "Through the gemini CLI " + version,
+			InputModalities: []string{"text"},
+		})
+	}
+	return models, nil
+}
@@ -0,0 +1,597 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"gemiwin/api/internal/domain"
+	"gemiwin/api/internal/persistence"
+
+	"github.com/google/uuid"
+)
+
+// Supported import sources.
+const (
+	ImportSourceChatGPT = "chatgpt"
+	ImportSourceGemini  = "gemini"
+	ImportSourceGemiwin = "gemiwin"
+)
+
+// Per-conversation import outcomes.
+const (
+	ImportStatusImported  = "imported"
+	ImportStatusDuplicate = "duplicate"
+	ImportStatusFailed    = "failed"
+)
+
+// Limits on imports, which are read into memory.
+const (
+	// MaxImportBytes caps the size of an uploaded export.
+	MaxImportBytes = 128 << 20
+	// maxImportExpandedBytes caps what the entries of an export archive decompress to, together.
+	maxImportExpandedBytes = 512 << 20
+)
+
+var (
+	// ErrUnrecognizedImport is returned when the payload does not match any supported export format.
+	ErrUnrecognizedImport = errors.New("unrecognized import format")
+	// ErrImportTooLarge is returned when an export archive expands beyond maxImportExpandedBytes.
+	ErrImportTooLarge = errors.New("import is too large")
+)
+
+// ImportResult describes what happened to a single conversation.
+type ImportResult struct {
+	Source   string `json:"source"`
+	SourceID string `json:"source_id"`
+	Title    string `json:"title"`
+	Status   string `json:"status"`
+	ChatID   string `json:"chat_id,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// ImportReport summarises an import run.
+type ImportReport struct {
+	Imported int            `json:"imported"`
+	Skipped  int            `json:"skipped"`
+	Failed   int            `json:"failed"`
+	Results  []ImportResult `json:"results"`
+}
+
+// ImportService maps conversations exported from other tools into gemiwin chats.
+type ImportService struct {
+	repo         *persistence.ChatRepository
+	storage      *StorageService
+	defaultModel string
+}
+
+// NewImportService creates an ImportService that restores exported documents through storage.
+// Imported chats without a model of their own use defaultModel.
+func NewImportService(repo *persistence.ChatRepository, storage *StorageService, defaultModel string) *ImportService {
+	return &ImportService{repo: repo, storage: storage, defaultModel: defaultModel}
+}
+
+// importedChat is a parsed conversation waiting to be persisted.
+type importedChat struct {
+	source   string
+	sourceID string
+	chat     *domain.Chat
+	err      error
+}
+
+// Import parses data (a zip archive or a JSON document) and stores every conversation it contains
+// as chats of userID. Conversations the user already imported from the same source id are skipped.
+func (s *ImportService) Import(userID string, data []byte) (*ImportReport, error) {
+	var parsed []importedChat
+	var files map[string][]byte
+	var err error
+
+	if bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		parsed, files, err = s.parseZip(data)
+	} else {
+		parsed, err = parseImportJSON(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range parsed {
+		if item.chat != nil && item.chat.Config.Model == "" {
+			item.chat.Config.Model = s.defaultModel
+		}
+	}
+
+	existing, err := s.repo.FindAllByOwner(userID)
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[string]bool)
+	ownedFiles := make(map[string]bool)
+	for _, chat := range existing {
+		known[ImportSourceGemiwin+"/"+chat.ID] = true
+		if chat.Source != nil {
+			known[chat.Source.Provider+"/"+chat.Source.ID] = true
+		}
+		for _, msg := range chat.Messages {
+			for _, doc := range msg.Documents {
+				if doc.ID != "" {
+					ownedFiles[doc.ID] = true
+				}
+			}
+		}
+	}
+
+	// Restored files stay pinned until the chats referencing them have been saved
+	var releases []func()
+	defer func() {
+		for _, release := range releases {
+			release()
+		}
+	}()
+
+	report := &ImportReport{Results: make([]ImportResult, 0, len(parsed))}
+	for _, item := range parsed {
+		result := ImportResult{Source: item.source, SourceID: item.sourceID}
+		if item.chat != nil {
+			result.Title = item.chat.Name
+		}
+
+		key := item.source + "/" + item.sourceID
+		switch {
+		case item.err != nil:
+			result.Status = ImportStatusFailed
+			result.Error = item.err.Error()
+			report.Failed++
+		case known[key]:
+			result.Status = ImportStatusDuplicate
+			report.Skipped++
+		default:
+			pinned, err := s.prepareChat(userID, item, files, ownedFiles)
+			releases = append(releases, pinned...)
+			if err != nil {
+				result.Status = ImportStatusFailed
+				result.Error = err.Error()
+				report.Failed++
+				break
+			}
+			if err := s.repo.Create(item.chat); err != nil {
+				result.Status = ImportStatusFailed
+				result.Error = err.Error()
+				report.Failed++
+				break
+			}
+			known[key] = true
+			result.Status = ImportStatusImported
+			result.ChatID = item.chat.ID
+			report.Imported++
+		}
+		report.Results = append(report.Results, result)
+	}
+
+	return report, nil
+}
+
+// prepareChat assigns an imported chat to userID. A gemiwin chat whose id is taken by another
+// user's chat gets a new id. Documents are restored from the archive under their content address,
+// so an import can never grant access to files that belong to someone else; references to files
+// that are neither in the archive nor already the user's are dropped. It returns the release
+// functions of the files it stored.
+func (s *ImportService) prepareChat(userID string, item importedChat, files map[string][]byte, ownedFiles map[string]bool) ([]func(), error) {
+	chat := item.chat
+	chat.OwnerID = userID
+
+	if item.source == ImportSourceGemiwin {
+		taken, err := s.repo.FindByID(chat.ID)
+		if err != nil {
+			return nil, err
+		}
+		if taken != nil {
+			chat.Source = &domain.ChatSource{Provider: ImportSourceGemiwin, ID: chat.ID}
+			chat.ID = uuid.New().String()
+		}
+	}
+
+	// Workspaces are directories on the exporting machine, and MCP servers are named in the
+	// exporting user's settings
+	chat.Config.WorkspaceIDs = nil
+	chat.Config.MCPServers = nil
+
+	var releases []func()
+	for i := range chat.Messages {
+		docs := chat.Messages[i].Documents
+		for j := range docs {
+			doc := &docs[j]
+			// Library entries are not part of an export
+			doc.LibraryID = ""
+			if doc.ID == "" || ownedFiles[doc.ID] {
+				continue
+			}
+			data, ok := files[doc.ID]
+			// Restored files pass the same checks as uploads
+			if ok && ValidateUpload(doc.Name, data) != nil {
+				ok = false
+			}
+			if !ok {
+				doc.ID = ""
+				doc.URL = ""
+				continue
+			}
+			name, release, err := s.storage.Store(doc.ID, data)
+			if err != nil {
+				return releases, err
+			}
+			releases = append(releases, release)
+			doc.ID = name
+			doc.URL = "/files/" + name
+		}
+	}
+	return releases, nil
+}
+
+// parseZip handles ChatGPT and Google Takeout archives as well as gemiwin's own bulk export.
+// Documents bundled with a gemiwin export are returned by name.
+func (s *ImportService) parseZip(data []byte) ([]importedChat, map[string][]byte, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrUnrecognizedImport, err)
+	}
+
+	var parsed []importedChat
+	files := make(map[string][]byte)
+	recognized := false
+	remaining := int64(maxImportExpandedBytes)
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		name := strings.ToLower(path.Base(f.Name))
+		dir := path.Base(path.Dir(f.Name))
+
+		switch {
+		case name == "conversations.json":
+			content, err := readZipFileWithin(f, &remaining)
+			if err != nil {
+				return nil, nil, err
+			}
+			chats, err := parseChatGPT(content)
+			if err != nil {
+				return nil, nil, err
+			}
+			parsed = append(parsed, chats...)
+			recognized = true
+		case strings.HasSuffix(strings.ReplaceAll(name, " ", ""), "myactivity.json"):
+			content, err := readZipFileWithin(f, &remaining)
+			if err != nil {
+				return nil, nil, err
+			}
+			chats, err := parseGeminiTakeout(content)
+			if err != nil {
+				return nil, nil, err
+			}
+			parsed = append(parsed, chats...)
+			recognized = true
+		case dir == "chats" && strings.HasSuffix(name, ".json"):
+			content, err := readZipFileWithin(f, &remaining)
+			if err != nil {
+				return nil, nil, err
+			}
+			parsed = append(parsed, parseGemiwinChat(content))
+			recognized = true
+		case dir == "files":
+			content, err := readZipFileWithin(f, &remaining)
+			if err != nil {
+				return nil, nil, err
+			}
+			files[path.Base(f.Name)] = content
+		}
+	}
+
+	if !recognized {
+		return nil, nil, ErrUnrecognizedImport
+	}
+	return parsed, files, nil
+}
+
+// readZipFileWithin decompresses f, taking its size from remaining. ErrImportTooLarge is returned
+// once remaining is used up, whatever the archive claims the entry's size is.
+func readZipFileWithin(f *zip.File, remaining *int64) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(io.LimitReader(rc, *remaining+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > *remaining {
+		return nil, ErrImportTooLarge
+	}
+	*remaining -= int64(len(data))
+	return data, nil
+}
+
+// parseImportJSON detects which tool produced a standalone JSON document.
+func parseImportJSON(data []byte) ([]importedChat, error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 {
+		return nil, ErrUnrecognizedImport
+	}
+
+	if trimmed[0] == '{' {
+		var probe map[string]json.RawMessage
+		if err := json.Unmarshal(trimmed, &probe); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrUnrecognizedImport, err)
+		}
+		if _, ok := probe["mapping"]; ok {
+			return parseChatGPT([]byte("[" + string(trimmed) + "]"))
+		}
+		if _, ok := probe["messages"]; ok {
+			return []importedChat{parseGemiwinChat(trimmed)}, nil
+		}
+		return nil, ErrUnrecognizedImport
+	}
+
+	var probe []map[string]json.RawMessage
+	if err := json.Unmarshal(trimmed, &probe); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnrecognizedImport, err)
+	}
+	if len(probe) == 0 {
+		return []importedChat{}, nil
+	}
+	first := probe[0]
+	switch {
+	case first["mapping"] != nil:
+		return parseChatGPT(trimmed)
+	case first["safeHtmlItem"] != nil || first["header"] != nil:
+		return parseGeminiTakeout(trimmed)
+	case first["messages"] != nil:
+		var raw []json.RawMessage
+		if err := json.Unmarshal(trimmed, &raw); err != nil {
+			return nil, err
+		}
+		parsed := make([]importedChat, 0, len(raw))
+		for _, item := range raw {
+			parsed = append(parsed, parseGemiwinChat(item))
+		}
+		return parsed, nil
+	}
+	return nil, ErrUnrecognizedImport
+}
+
+// parseGemiwinChat reads a chat produced by gemiwin's own export, keeping its original id.
+func parseGemiwinChat(data []byte) importedChat {
+	var chat domain.Chat
+	if err := json.Unmarshal(data, &chat); err != nil {
+		return importedChat{source: ImportSourceGemiwin, err: err}
+	}
+	item := importedChat{source: ImportSourceGemiwin, sourceID: chat.ID, chat: &chat}
+	if chat.ID == "" {
+		item.err = errors.New("chat has no id")
+		return item
+	}
+	if strings.ContainsAny(chat.ID, `/\`) || strings.Contains(chat.ID, "..") {
+		item.err = fmt.Errorf("invalid chat id: %s", chat.ID)
+		return item
+	}
+	if chat.Messages == nil {
+		chat.Messages = []domain.Message{}
+	}
+	return item
+}
+
+type chatGPTConversation struct {
+	ID             string                 `json:"id"`
+	ConversationID string                 `json:"conversation_id"`
+	Title          string                 `json:"title"`
+	CreateTime     float64                `json:"create_time"`
+	CurrentNode    string                 `json:"current_node"`
+	Mapping        map[string]chatGPTNode `json:"mapping"`
+}
+
+type chatGPTNode struct {
+	Parent  string          `json:"parent"`
+	Message *chatGPTMessage `json:"message"`
+}
+
+type chatGPTMessage struct {
+	Author struct {
+		Role string `json:"role"`
+	} `json:"author"`
+	CreateTime float64 `json:"create_time"`
+	Content    struct {
+		ContentType string            `json:"content_type"`
+		Parts       []json.RawMessage `json:"parts"`
+	} `json:"content"`
+}
+
+// parseChatGPT maps ChatGPT's conversations.json. Only the active branch of each conversation
+// (from current_node back to the root) is imported.
+func parseChatGPT(data []byte) ([]importedChat, error) {
+	var conversations []chatGPTConversation
+	if err := json.Unmarshal(data, &conversations); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnrecognizedImport, err)
+	}
+
+	parsed := make([]importedChat, 0, len(conversations))
+	for _, conv := range conversations {
+		sourceID := conv.ConversationID
+		if sourceID == "" {
+			sourceID = conv.ID
+		}
+		item := importedChat{source: ImportSourceChatGPT, sourceID: sourceID}
+
+		var nodes []chatGPTNode
+		seen := make(map[string]bool)
+		for id := conv.CurrentNode; id != "" && !seen[id]; {
+			seen[id] = true
+			node, ok := conv.Mapping[id]
+			if !ok {
+				break
+			}
+			nodes = append(nodes, node)
+			id = node.Parent
+		}
+
+		messages := make([]domain.Message, 0, len(nodes))
+		for i := len(nodes) - 1; i >= 0; i-- {
+			msg := nodes[i].Message
+			if msg == nil {
+				continue
+			}
+			var role domain.Role
+			switch msg.Author.Role {
+			case "user":
+				role = domain.UserRole
+			case "assistant":
+				role = domain.BotRole
+			default:
+				continue
+			}
+
+			var parts []string
+			for _, raw := range msg.Content.Parts {
+				var text string
+				if json.Unmarshal(raw, &text) == nil && text != "" {
+					parts = append(parts, text)
+				}
+			}
+			if len(parts) == 0 {
+				continue
+			}
+
+			messages = append(messages, domain.Message{
+				Role:      role,
+				Type:      "text",
+				Content:   strings.Join(parts, "\n"),
+				Timestamp: epochToTime(msg.CreateTime, conv.CreateTime),
+			})
+		}
+
+		if sourceID == "" {
+			item.err = errors.New("conversation has no id")
+		} else if len(messages) == 0 {
+			item.err = errors.New("conversation has no text messages")
+		}
+
+		item.chat = newImportedChat(conv.Title, epochToTime(conv.CreateTime, 0), messages,
+			&domain.ChatSource{Provider: ImportSourceChatGPT, ID: sourceID})
+		parsed = append(parsed, item)
+	}
+	return parsed, nil
+}
+
+type geminiActivity struct {
+	Header       string `json:"header"`
+	Title        string `json:"title"`
+	Time         string `json:"time"`
+	SafeHTMLItem []struct {
+		HTML string `json:"html"`
+	} `json:"safeHtmlItem"`
+}
+
+// parseGeminiTakeout maps the Gemini Apps activity log from Google Takeout. Takeout does not
+// group prompts into conversations, so each prompt and its response become their own chat.
+func parseGeminiTakeout(data []byte) ([]importedChat, error) {
+	var activities []geminiActivity
+	if err := json.Unmarshal(data, &activities); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnrecognizedImport, err)
+	}
+
+	parsed := make([]importedChat, 0, len(activities))
+	for _, act := range activities {
+		prompt, ok := strings.CutPrefix(act.Title, "Prompted ")
+		if !ok {
+			// Skip non-conversation activity such as feedback or settings changes.
+			continue
+		}
+
+		sum := sha256.Sum256([]byte(act.Time + "\x00" + act.Title))
+		sourceID := hex.EncodeToString(sum[:16])
+		item := importedChat{source: ImportSourceGemini, sourceID: sourceID}
+
+		ts, err := time.Parse(time.RFC3339Nano, act.Time)
+		if err != nil {
+			item.err = fmt.Errorf("invalid activity time: %s", act.Time)
+			ts = time.Now()
+		}
+
+		messages := []domain.Message{{
+			Role:      domain.UserRole,
+			Type:      "text",
+			Content:   prompt,
+			Timestamp: ts,
+		}}
+		var answer []string
+		for _, part := range act.SafeHTMLItem {
+			if text := htmlToText(part.HTML); text != "" {
+				answer = append(answer, text)
+			}
+		}
+		if len(answer) > 0 {
+			messages = append(messages, domain.Message{
+				Role:      domain.BotRole,
+				Type:      "text",
+				Content:   strings.Join(answer, "\n\n"),
+				Timestamp: ts,
+			})
+		}
+
+		item.chat = newImportedChat(prompt, ts, messages,
+			&domain.ChatSource{Provider: ImportSourceGemini, ID: sourceID})
+		parsed = append(parsed, item)
+	}
+
+	sort.SliceStable(parsed, func(i, j int) bool {
+		return parsed[i].chat.CreatedAt.Before(parsed[j].chat.CreatedAt)
+	})
+	return parsed, nil
+}
+
+func newImportedChat(name string, createdAt time.Time, messages []domain.Message, source *domain.ChatSource) *domain.Chat {
+	if name == "" && len(messages) > 0 {
+		name = messages[0].Content
+	}
+	return &domain.Chat{
+		ID:        uuid.New().String(),
+		Name:      name,
+		CreatedAt: createdAt,
+		Messages:  messages,
+		Source:    source,
+	}
+}
+
+func epochToTime(seconds float64, fallback float64) time.Time {
+	if seconds == 0 {
+		seconds = fallback
+	}
+	if seconds == 0 {
+		return time.Now()
+	}
+	return time.Unix(0, int64(seconds*float64(time.Second)))
+}
+
+var (
+	htmlBreakPattern = regexp.MustCompile(`(?i)<br\s*/?>|</p>|</li>|</h[1-6]>|</div>`)
+	htmlTagPattern   = regexp.MustCompile(`<[^>]*>`)
+	blankLinePattern = regexp.MustCompile(`\n{3,}`)
+)
+
+// htmlToText flattens the simple markup used in Takeout responses into plain text.
+func htmlToText(s string) string {
+	s = htmlBreakPattern.ReplaceAllString(s, "\n")
+	s = htmlTagPattern.ReplaceAllString(s, "")
+	s = html.UnescapeString(s)
+	s = blankLinePattern.ReplaceAllString(s, "\n\n")
+	return strings.TrimSpace(s)
+}
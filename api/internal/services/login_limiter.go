@@ -0,0 +1,113 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// loginWindow is how far back login attempts are counted.
+	loginWindow = time.Minute
+	// maxLoginAttemptsPerIP caps the attempts, failed or not, from one address per window. Each
+	// attempt costs a password hash, so this also bounds the work one client can cause.
+	maxLoginAttemptsPerIP = 10
+	// maxLoginFailuresPerUser caps the failed attempts at one username per window, from any address.
+	maxLoginFailuresPerUser = 5
+)
+
+// ErrTooManyLoginAttempts is wrapped by LoginThrottledError.
+var ErrTooManyLoginAttempts = errors.New("too many login attempts")
+
+// LoginThrottledError is returned when an address or username has used up its login attempts.
+type LoginThrottledError struct {
+	RetryAt time.Time
+}
+
+func (e *LoginThrottledError) Error() string {
+	return fmt.Sprintf("too many login attempts; try again in %s", time.Until(e.RetryAt).Round(time.Second))
+}
+
+func (e *LoginThrottledError) Unwrap() error {
+	return ErrTooManyLoginAttempts
+}
+
+// loginLimiter counts recent login attempts by key, an address or a username.
+type loginLimiter struct {
+	mu        sync.Mutex
+	attempts  map[string][]time.Time
+	lastSweep time.Time
+}
+
+func newLoginLimiter() *loginLimiter {
+	return &loginLimiter{attempts: map[string][]time.Time{}}
+}
+
+// check returns a LoginThrottledError if key has limit attempts within the window before now.
+func (l *loginLimiter) check(key string, limit int, now time.Time) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.throttled(key, limit, now)
+}
+
+// take is check, counting an attempt by key at now if it is allowed.
+func (l *loginLimiter) take(key string, limit int, now time.Time) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.throttled(key, limit, now); err != nil {
+		return err
+	}
+	l.attempts[key] = append(l.attempts[key], now)
+	return nil
+}
+
+// add counts an attempt by key at now.
+func (l *loginLimiter) add(key string, now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.attempts[key] = append(l.recent(key, now), now)
+}
+
+// reset forgets the attempts by key.
+func (l *loginLimiter) reset(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.attempts, key)
+}
+
+func (l *loginLimiter) throttled(key string, limit int, now time.Time) error {
+	recent := l.recent(key, now)
+	if len(recent) < limit {
+		return nil
+	}
+	return &LoginThrottledError{RetryAt: recent[len(recent)-limit].Add(loginWindow)}
+}
+
+// recent drops key's attempts older than the window and returns the rest. Once per window every
+// key is swept, so addresses that stopped trying do not pile up.
+func (l *loginLimiter) recent(key string, now time.Time) []time.Time {
+	if now.Sub(l.lastSweep) > loginWindow {
+		l.lastSweep = now
+		for k := range l.attempts {
+			if k != key {
+				l.prune(k, now)
+			}
+		}
+	}
+	return l.prune(key, now)
+}
+
+func (l *loginLimiter) prune(key string, now time.Time) []time.Time {
+	times := l.attempts[key]
+	i := 0
+	for i < len(times) && now.Sub(times[i]) >= loginWindow {
+		i++
+	}
+	if i == len(times) {
+		delete(l.attempts, key)
+		return nil
+	}
+	l.attempts[key] = times[i:]
+	return times[i:]
+}
@@ -0,0 +1,43 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLoginLimiter(t *testing.T) {
+	l := newLoginLimiter()
+	start := time.Now()
+	for i := range 3 {
+		if err := l.take("ip", 3, start.Add(time.Duration(i)*time.Second)); err != nil {
+			t.Fatalf("attempt %d: %v", i+1, err)
+		}
+	}
+
+	err := l.take("ip", 3, start.Add(10*time.Second))
+	var throttled *LoginThrottledError
+	if !errors.As(err, &throttled) || !errors.Is(err, ErrTooManyLoginAttempts) {
+		t.Fatalf("fourth attempt: error = %v, want a LoginThrottledError", err)
+	}
+	if want := start.Add(loginWindow); !throttled.RetryAt.Equal(want) {
+		t.Errorf("RetryAt = %v, want %v", throttled.RetryAt, want)
+	}
+	// Refused attempts do not extend the wait, and the oldest attempt leaving the window frees one
+	if err := l.take("ip", 3, start.Add(loginWindow)); err != nil {
+		t.Errorf("attempt after the first left the window: %v", err)
+	}
+	if err := l.take("other", 3, start.Add(10*time.Second)); err != nil {
+		t.Errorf("other key: %v", err)
+	}
+
+	l.add("user", start)
+	l.add("user", start)
+	if err := l.check("user", 2, start); err == nil {
+		t.Error("check allowed a key at its limit")
+	}
+	l.reset("user")
+	if err := l.check("user", 2, start); err != nil {
+		t.Errorf("check after reset: %v", err)
+	}
+}
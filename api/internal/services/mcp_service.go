@@ -0,0 +1,416 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"gemiwin/api/internal/domain"
+	"gemiwin/api/internal/mcp"
+	"gemiwin/api/internal/persistence"
+)
+
+var (
+	// ErrInvalidMCPServer is returned for MCP server settings that cannot be used.
+	ErrInvalidMCPServer = errors.New("invalid MCP server")
+	// ErrMCPCommandNotAllowed is returned when a user without administrator rights registers an
+	// MCP server that runs a command on the server's machine.
+	ErrMCPCommandNotAllowed = errors.New("only administrators can register MCP servers that run commands")
+	// ErrMCPServerNotFound is returned when a chat enables an MCP server the user has not registered.
+	ErrMCPServerNotFound = errors.New("MCP server not found")
+)
+
+const (
+	// mcpConnectTimeout bounds starting or reaching a server and listing what it offers.
+	mcpConnectTimeout = 30 * time.Second
+	// mcpCallTimeout bounds one tool call or resource read.
+	mcpCallTimeout = 2 * time.Minute
+	// maxListedResources caps the resources named in a read_resource tool's description.
+	maxListedResources = 50
+	// maxToolNameLength is the longest function name the Gemini API accepts.
+	maxToolNameLength = 64
+)
+
+// mcpServerName is the format of MCP server names, which become part of tool names.
+var mcpServerName = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,31}$`)
+
+// mcpClientInfo identifies gemiwin to MCP servers and clients.
+var mcpClientInfo = mcp.Implementation{Name: "gemiwin", Version: "1.0"}
+
+// MCPServerStatus describes a configured MCP server and what it offers.
+type MCPServerStatus struct {
+	Name      string         `json:"name"`
+	Transport string         `json:"transport"`
+	Connected bool           `json:"connected"`
+	Error     string         `json:"error,omitempty"`
+	Server    string         `json:"server,omitempty"`
+	Tools     []mcp.Tool     `json:"tools"`
+	Resources []mcp.Resource `json:"resources"`
+}
+
+// mcpConnection is a live connection and what the server offered when it was made.
+type mcpConnection struct {
+	config    domain.MCPServer
+	client    *mcp.Client
+	tools     []mcp.Tool
+	resources []mcp.Resource
+}
+
+// MCPService connects to the MCP servers in each user's AppConfig and exposes their tools and
+// resources to the bot. Connections are made on first use and kept open; they are replaced when
+// the server's settings change or the connection is lost.
+type MCPService struct {
+	configs *persistence.AppConfigRepository
+	users   *UserService
+	// guarded reaches the HTTP servers of users without administrator rights under the URL policy
+	guarded *http.Client
+	open    *http.Client
+
+	mu    sync.Mutex
+	conns map[string]*mcpConnection
+}
+
+// NewMCPService creates an MCPService that reads server settings from configs and checks owners'
+// rights with users. HTTP servers registered by users without administrator rights are reached
+// through fetcher's policy, like fetched URLs.
+func NewMCPService(configs *persistence.AppConfigRepository, users *UserService, fetcher *URLFetcher) *MCPService {
+	return &MCPService{
+		configs: configs,
+		users:   users,
+		guarded: fetcher.Client(mcpCallTimeout),
+		open:    &http.Client{Timeout: mcpCallTimeout},
+		conns:   map[string]*mcpConnection{},
+	}
+}
+
+// ValidateServers checks MCP server settings before they are saved. Servers that run a command
+// require administrator rights.
+func ValidateServers(servers []domain.MCPServer, admin bool) error {
+	seen := map[string]bool{}
+	for _, server := range servers {
+		if !mcpServerName.MatchString(server.Name) {
+			return fmt.Errorf("%w: name %q must be 1-32 lowercase letters, digits, '-' or '_', starting with a letter", ErrInvalidMCPServer, server.Name)
+		}
+		if seen[server.Name] {
+			return fmt.Errorf("%w: %q is listed twice", ErrInvalidMCPServer, server.Name)
+		}
+		seen[server.Name] = true
+
+		switch {
+		case server.Command != "" && server.URL != "":
+			return fmt.Errorf("%w: %s: set either command or url, not both", ErrInvalidMCPServer, server.Name)
+		case server.Command != "":
+			if !admin {
+				return ErrMCPCommandNotAllowed
+			}
+		case server.URL != "":
+			u, err := url.Parse(server.URL)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("%w: %s: url must be an http or https URL", ErrInvalidMCPServer, server.Name)
+			}
+		default:
+			return fmt.Errorf("%w: %s: set a command or a url", ErrInvalidMCPServer, server.Name)
+		}
+	}
+	return nil
+}
+
+// CheckConfigured returns ErrMCPServerNotFound unless userID has registered every server in names.
+func (s *MCPService) CheckConfigured(userID string, names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+	cfg, err := s.configs.Load(userID)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if findMCPServer(cfg.MCPServers, name) == nil {
+			return fmt.Errorf("%w: %s", ErrMCPServerNotFound, name)
+		}
+	}
+	return nil
+}
+
+// Status connects to each of userID's servers and reports what it offers, or why it failed.
+func (s *MCPService) Status(userID string) ([]MCPServerStatus, error) {
+	cfg, err := s.configs.Load(userID)
+	if err != nil {
+		return nil, err
+	}
+	s.prune(userID, cfg.MCPServers)
+	statuses := make([]MCPServerStatus, 0, len(cfg.MCPServers))
+	for _, server := range cfg.MCPServers {
+		status := MCPServerStatus{Name: server.Name, Transport: "http", Tools: []mcp.Tool{}, Resources: []mcp.Resource{}}
+		if server.Command != "" {
+			status.Transport = "stdio"
+		}
+		conn, err := s.connection(userID, server)
+		if err != nil {
+			status.Error = err.Error()
+		} else {
+			status.Connected = true
+			status.Server = strings.TrimSpace(conn.client.Server.ServerInfo.Name + " " + conn.client.Server.ServerInfo.Version)
+			status.Tools = conn.tools
+			status.Resources = conn.resources
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// Tools returns the tools of userID's servers named in names, ready for the bot. Each server's
+// tools are prefixed with its name, and servers with resources get a read_resource tool.
+// Servers that cannot be reached are left out so the chat keeps working.
+func (s *MCPService) Tools(userID string, names []string) ([]Tool, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	cfg, err := s.configs.Load(userID)
+	if err != nil {
+		return nil, err
+	}
+	s.prune(userID, cfg.MCPServers)
+	var tools []Tool
+	for _, name := range names {
+		server := findMCPServer(cfg.MCPServers, name)
+		if server == nil {
+			continue
+		}
+		conn, err := s.connection(userID, *server)
+		if err != nil {
+			log.Printf("MCP server %s of %s is unavailable: %v", name, userID, err)
+			continue
+		}
+		tools = append(tools, conn.botTools()...)
+	}
+	return tools, nil
+}
+
+// connection returns a live connection to server, connecting if there is none or its settings
+// changed.
+func (s *MCPService) connection(userID string, server domain.MCPServer) (*mcpConnection, error) {
+	key := userID + "\x00" + server.Name
+	s.mu.Lock()
+	conn := s.conns[key]
+	if conn != nil && (!reflect.DeepEqual(conn.config, server) || !conn.client.Alive()) {
+		delete(s.conns, key)
+		go conn.client.Close()
+		conn = nil
+	}
+	s.mu.Unlock()
+	if conn != nil {
+		return conn, nil
+	}
+
+	conn, err := s.connect(userID, server)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Another request may have connected meanwhile; keep one connection
+	if existing := s.conns[key]; existing != nil && reflect.DeepEqual(existing.config, server) {
+		go conn.client.Close()
+		return existing, nil
+	}
+	s.conns[key] = conn
+	return conn, nil
+}
+
+// prune closes userID's connections to servers that are no longer configured.
+func (s *MCPService) prune(userID string, servers []domain.MCPServer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, conn := range s.conns {
+		owner, name, _ := strings.Cut(key, "\x00")
+		if owner == userID && findMCPServer(servers, name) == nil {
+			delete(s.conns, key)
+			go conn.client.Close()
+		}
+	}
+}
+
+func (s *MCPService) connect(userID string, server domain.MCPServer) (*mcpConnection, error) {
+	user, err := s.users.GetUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	admin := user != nil && user.Admin
+
+	ctx, cancel := context.WithTimeout(context.Background(), mcpConnectTimeout)
+	defer cancel()
+	var client *mcp.Client
+	if server.Command != "" {
+		// Checked again here in case the owner lost administrator rights since saving
+		if !admin {
+			return nil, ErrMCPCommandNotAllowed
+		}
+		client, err = mcp.DialStdio(ctx, mcpClientInfo, server.Command, server.Args, server.Env)
+	} else {
+		httpClient := s.guarded
+		if admin {
+			httpClient = s.open
+		}
+		client, err = mcp.DialHTTP(ctx, mcpClientInfo, server.URL, server.Headers, httpClient)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	conn := &mcpConnection{config: server, client: client}
+	if conn.tools, err = client.ListTools(ctx); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to list tools: %w", err)
+	}
+	if conn.resources, err = client.ListResources(ctx); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to list resources: %w", err)
+	}
+	return conn, nil
+}
+
+// botTools adapts the server's tools, and its resources if it has any, to the bot's tool loop.
+func (c *mcpConnection) botTools() []Tool {
+	tools := make([]Tool, 0, len(c.tools)+1)
+	taken := map[string]bool{}
+	for _, t := range c.tools {
+		remote := t.Name
+		tools = append(tools, Tool{
+			Name:        uniqueMCPToolName(c.config.Name, remote, taken),
+			Description: strings.TrimSpace(fmt.Sprintf("[MCP server %s] %s", c.config.Name, t.Description)),
+			Parameters:  t.InputSchema,
+			ReadOnly:    t.ReadOnly(),
+			Handler: func(_ ToolContext, args json.RawMessage) (string, error) {
+				ctx, cancel := context.WithTimeout(context.Background(), mcpCallTimeout)
+				defer cancel()
+				result, err := c.client.CallTool(ctx, remote, args)
+				if err != nil {
+					return "", err
+				}
+				text := mcpResultText(result.Content)
+				if result.IsError {
+					return "", errors.New(text)
+				}
+				return text, nil
+			},
+		})
+	}
+	if len(c.resources) > 0 {
+		tools = append(tools, c.readResourceTool(uniqueMCPToolName(c.config.Name, "read_resource", taken)))
+	}
+	return tools
+}
+
+func (c *mcpConnection) readResourceTool(name string) Tool {
+	var listing strings.Builder
+	for i, r := range c.resources {
+		if i == maxListedResources {
+			fmt.Fprintf(&listing, "\n(%d more)", len(c.resources)-i)
+			break
+		}
+		fmt.Fprintf(&listing, "\n- %s: %s", r.URI, r.Name)
+		if r.Description != "" {
+			listing.WriteString(" – " + r.Description)
+		}
+	}
+	return Tool{
+		Name:        name,
+		Description: fmt.Sprintf("[MCP server %s] Reads one of these resources:%s", c.config.Name, listing.String()),
+		Parameters: json.RawMessage(`{"type":"object","properties":{` +
+			`"uri":{"type":"string","description":"URI of the resource to read"}},"required":["uri"]}`),
+		ReadOnly: true,
+		Handler: func(_ ToolContext, args json.RawMessage) (string, error) {
+			var params struct {
+				URI string `json:"uri"`
+			}
+			if err := json.Unmarshal(args, &params); err != nil {
+				return "", fmt.Errorf("invalid arguments: %w", err)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), mcpCallTimeout)
+			defer cancel()
+			contents, err := c.client.ReadResource(ctx, params.URI)
+			if err != nil {
+				return "", err
+			}
+			parts := make([]mcp.Content, 0, len(contents))
+			for i := range contents {
+				parts = append(parts, mcp.Content{Type: "resource", Resource: &contents[i]})
+			}
+			return mcpResultText(parts), nil
+		},
+	}
+}
+
+// mcpToolName prefixes a server's tool with the server name, keeping to the characters and length
+// function names may have. Names too long to keep whole end in a hash of the tool's name instead,
+// so tools that share a long prefix stay apart.
+func mcpToolName(server string, tool string) string {
+	name := server + "__" + strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-' || r == '.' {
+			return r
+		}
+		return '_'
+	}, tool)
+	if len(name) > maxToolNameLength {
+		return hashedToolName(name, tool)
+	}
+	return name
+}
+
+// uniqueMCPToolName returns mcpToolName unless a tool in taken already has that name, as tools
+// differing only in replaced characters would, and then a hashed one. The name is added to taken.
+func uniqueMCPToolName(server string, tool string, taken map[string]bool) string {
+	name := mcpToolName(server, tool)
+	if taken[name] {
+		name = hashedToolName(name, tool)
+	}
+	taken[name] = true
+	return name
+}
+
+// hashedToolName cuts name short enough to end in a hash of tool.
+func hashedToolName(name string, tool string) string {
+	sum := sha256.Sum256([]byte(tool))
+	suffix := "_" + hex.EncodeToString(sum[:4])
+	return name[:min(len(name), maxToolNameLength-len(suffix))] + suffix
+}
+
+// mcpResultText renders tool or resource content as text for the model. Binary content is
+// described rather than included.
+func mcpResultText(parts []mcp.Content) string {
+	texts := make([]string, 0, len(parts))
+	for _, part := range parts {
+		switch {
+		case part.Type == "text":
+			texts = append(texts, part.Text)
+		case part.Resource != nil && part.Resource.Blob == "":
+			texts = append(texts, part.Resource.Text)
+		case part.Resource != nil:
+			texts = append(texts, fmt.Sprintf("[binary resource %s (%s), %d bytes]", part.Resource.URI, part.Resource.MIMEType, len(part.Resource.Blob)*3/4))
+		default:
+			texts = append(texts, fmt.Sprintf("[%s content (%s)]", part.Type, part.MIMEType))
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+func findMCPServer(servers []domain.MCPServer, name string) *domain.MCPServer {
+	for i := range servers {
+		if servers[i].Name == name {
+			return &servers[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"gemiwin/api/internal/domain"
+	"gemiwin/api/internal/mcp"
+)
+
+// toolNamePattern is what the Gemini API accepts as a function name.
+var toolNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_.-]{0,63}$`)
+
+func TestMCPToolName(t *testing.T) {
+	tests := []struct {
+		server string
+		tool   string
+		want   string
+	}{
+		{"files", "read", "files__read"},
+		{"files", "read file", "files__read_file"},
+		{"files", "ls/dir", "files__ls_dir"},
+		{"files", "v1.list-all", "files__v1.list-all"},
+		{"files", strings.Repeat("x", 57), "files__" + strings.Repeat("x", 57)},
+	}
+	for _, tt := range tests {
+		if got := mcpToolName(tt.server, tt.tool); got != tt.want {
+			t.Errorf("mcpToolName(%q, %q) = %q, want %q", tt.server, tt.tool, got, tt.want)
+		}
+	}
+
+	// Names that must be cut keep a prefix and end in a hash that tells them apart
+	long := strings.Repeat("x", 70)
+	a, b := mcpToolName("files", long+"a"), mcpToolName("files", long+"b")
+	if len(a) != maxToolNameLength || !strings.HasPrefix(a, "files__"+long[:48]+"_") {
+		t.Errorf("mcpToolName(long) = %q, want %d characters starting with the tool name", a, maxToolNameLength)
+	}
+	if a == b {
+		t.Errorf("long tool names collide as %q", a)
+	}
+}
+
+func TestMCPToolNamesStayApart(t *testing.T) {
+	long := strings.Repeat("search_the_documents_", 4)
+	conn := &mcpConnection{
+		config: domain.MCPServer{Name: "docs"},
+		tools: []mcp.Tool{
+			{Name: long + "by_title"},
+			{Name: long + "by_author"},
+			{Name: "get.item"},
+			{Name: "get item"},
+			{Name: "get_item"},
+			{Name: "read_resource"},
+		},
+		resources: []mcp.Resource{{URI: "file:///a", Name: "a"}},
+	}
+	tools := conn.botTools()
+	if len(tools) != len(conn.tools)+1 {
+		t.Fatalf("got %d tools, want %d", len(tools), len(conn.tools)+1)
+	}
+	seen := map[string]bool{}
+	for _, tool := range tools {
+		if !toolNamePattern.MatchString(tool.Name) {
+			t.Errorf("tool name %q is not a valid function name", tool.Name)
+		}
+		if seen[tool.Name] {
+			t.Errorf("tool name %q is used twice", tool.Name)
+		}
+		seen[tool.Name] = true
+	}
+	// Names that fit and do not collide are kept as they are
+	if tools[2].Name != "docs__get.item" || tools[3].Name != "docs__get_item" {
+		t.Errorf("got names %q and %q, want docs__get.item and docs__get_item", tools[2].Name, tools[3].Name)
+	}
+}
+
+func TestMCPToolsReadOnly(t *testing.T) {
+	conn := &mcpConnection{
+		config: domain.MCPServer{Name: "tracker"},
+		tools: []mcp.Tool{
+			{Name: "list_issues", Annotations: &mcp.ToolAnnotations{ReadOnlyHint: true}},
+			{Name: "create_issue"},
+			{Name: "close_issue", Annotations: &mcp.ToolAnnotations{Title: "Close"}},
+		},
+		resources: []mcp.Resource{{URI: "issue://1", Name: "1"}},
+	}
+	registry := NewToolRegistry().With(conn.botTools()...).ReadOnly()
+	var names []string
+	for _, tool := range registry.Tools() {
+		names = append(names, tool.Name)
+	}
+	if got := strings.Join(names, ","); got != "tracker__list_issues,tracker__read_resource" {
+		t.Errorf("read-only tools = %s, want tracker__list_issues and tracker__read_resource", got)
+	}
+}
@@ -0,0 +1,226 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"gemiwin/api/internal/domain"
+	"gemiwin/api/internal/persistence"
+)
+
+var (
+	// ErrUnknownModel is returned when a request selects a model that is not in the registry.
+	ErrUnknownModel = errors.New("unknown model")
+	// ErrInvalidModel is returned for user-defined model entries that cannot be used.
+	ErrInvalidModel = errors.New("invalid model")
+)
+
+const (
+	// modelDiscoveryTTL is how long the models reported by the backend are reused.
+	modelDiscoveryTTL = 10 * time.Minute
+	// modelDiscoveryRetry is how long to wait before asking again after discovery failed.
+	modelDiscoveryRetry = time.Minute
+)
+
+// Where a model entry comes from.
+const (
+	ModelSourceBuiltin = "builtin"
+	ModelSourceBackend = "backend"
+	ModelSourceUser    = "user"
+)
+
+// modalities are the kinds of input and output a model entry can list.
+var modalities = []string{"text", "image", "audio", "video", "pdf"}
+
+// builtinModels are the models known without asking the backend, with their published limits
+// and prices (prompts up to 200k tokens).
+var builtinModels = []domain.Model{
+	{
+		ID:               domain.ModelGemini25Pro,
+		DisplayName:      "Gemini 2.5 Pro",
+		ContextWindow:    1048576,
+		OutputTokens:     65536,
+		InputModalities:  []string{"text", "image", "audio", "video", "pdf"},
+		OutputModalities: []string{"text"},
+		Pricing:          &domain.ModelPricing{InputPerMillion: 1.25, OutputPerMillion: 10},
+	},
+	{
+		ID:               domain.ModelGemini25Flash,
+		DisplayName:      "Gemini 2.5 Flash",
+		ContextWindow:    1048576,
+		OutputTokens:     65536,
+		InputModalities:  []string{"text", "image", "audio", "video", "pdf"},
+		OutputModalities: []string{"text"},
+		Pricing:          &domain.ModelPricing{InputPerMillion: 0.30, OutputPerMillion: 2.50},
+	},
+	{
+		ID:               "gemini-2.5-flash-lite",
+		DisplayName:      "Gemini 2.5 Flash-Lite",
+		ContextWindow:    1048576,
+		OutputTokens:     65536,
+		InputModalities:  []string{"text", "image", "audio", "video", "pdf"},
+		OutputModalities: []string{"text"},
+		Pricing:          &domain.ModelPricing{InputPerMillion: 0.10, OutputPerMillion: 0.40},
+	},
+}
+
+// discoveredModels is a cached answer of the backend.
+type discoveredModels struct {
+	models  []domain.Model
+	expires time.Time
+}
+
+// ModelRegistry lists the models a user can choose from. It layers what the backend reports over
+// the built-in entries, and the user's own entries from AppConfig over both.
+type ModelRegistry struct {
+	backend      Backend
+	secrets      *persistence.SecretStore
+	configs      *persistence.AppConfigRepository
+	defaultModel string
+
+	mu sync.Mutex
+	// discovered caches the backend's answer by API key, since the key decides what is listed
+	discovered map[string]discoveredModels
+}
+
+// NewModelRegistry creates a ModelRegistry that asks backend for models with each user's API key
+// from secrets and adds the entries of their AppConfig from configs. defaultModel is marked as
+// the default.
+func NewModelRegistry(backend Backend, secrets *persistence.SecretStore, configs *persistence.AppConfigRepository, defaultModel string) *ModelRegistry {
+	return &ModelRegistry{
+		backend:      backend,
+		secrets:      secrets,
+		configs:      configs,
+		defaultModel: defaultModel,
+		discovered:   map[string]discoveredModels{},
+	}
+}
+
+// Models returns the models userID can use, sorted by id.
+func (r *ModelRegistry) Models(userID string) ([]domain.Model, error) {
+	cfg, err := r.configs.Load(userID)
+	if err != nil {
+		return nil, err
+	}
+	apiKey, err := r.secrets.Get(persistence.UserSecret(persistence.SecretGeminiApiKey, userID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load API key: %w", err)
+	}
+
+	byID := map[string]domain.Model{}
+	for _, model := range builtinModels {
+		model.Source = ModelSourceBuiltin
+		byID[model.ID] = model
+	}
+	for _, model := range r.discover(apiKey) {
+		model.Source = ModelSourceBackend
+		byID[model.ID] = overlayModel(byID[model.ID], model)
+	}
+	for _, model := range cfg.Models {
+		model.Source = ModelSourceUser
+		byID[model.ID] = overlayModel(byID[model.ID], model)
+	}
+
+	models := make([]domain.Model, 0, len(byID))
+	for _, model := range byID {
+		model.Default = model.ID == r.defaultModel
+		models = append(models, model)
+	}
+	sort.Slice(models, func(i, j int) bool { return models[i].ID < models[j].ID })
+	return models, nil
+}
+
+// Lookup returns the model called id, or ErrUnknownModel if userID cannot use it.
+func (r *ModelRegistry) Lookup(userID string, id string) (*domain.Model, error) {
+	models, err := r.Models(userID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range models {
+		if models[i].ID == id {
+			return &models[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownModel, id)
+}
+
+// discover returns the models the backend reports for apiKey, from the cache when it is recent.
+// Failures are logged and leave the built-in and user entries to stand alone.
+func (r *ModelRegistry) discover(apiKey string) []domain.Model {
+	r.mu.Lock()
+	cached, ok := r.discovered[apiKey]
+	r.mu.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.models
+	}
+
+	models, err := r.backend.ListModels(apiKey)
+	entry := discoveredModels{models: models, expires: time.Now().Add(modelDiscoveryTTL)}
+	if err != nil {
+		log.Printf("Model discovery failed: %v", err)
+		entry = discoveredModels{expires: time.Now().Add(modelDiscoveryRetry)}
+	}
+	r.mu.Lock()
+	r.discovered[apiKey] = entry
+	r.mu.Unlock()
+	return entry.models
+}
+
+// overlayModel returns base with the properties top knows replacing its own.
+func overlayModel(base domain.Model, top domain.Model) domain.Model {
+	base.ID = top.ID
+	base.Source = top.Source
+	if top.DisplayName != "" {
+		base.DisplayName = top.DisplayName
+	}
+	if top.Description != "" {
+		base.Description = top.Description
+	}
+	if top.ContextWindow > 0 {
+		base.ContextWindow = top.ContextWindow
+	}
+	if top.OutputTokens > 0 {
+		base.OutputTokens = top.OutputTokens
+	}
+	if len(top.InputModalities) > 0 {
+		base.InputModalities = top.InputModalities
+	}
+	if len(top.OutputModalities) > 0 {
+		base.OutputModalities = top.OutputModalities
+	}
+	if top.Pricing != nil {
+		base.Pricing = top.Pricing
+	}
+	return base
+}
+
+// ValidateModels checks user-defined model entries before they are saved.
+func ValidateModels(models []domain.Model) error {
+	seen := map[string]bool{}
+	for _, model := range models {
+		if !domain.ValidModelID(model.ID) {
+			return fmt.Errorf("%w: id %q must be 1-64 letters, digits, '.', '-' or '_'", ErrInvalidModel, model.ID)
+		}
+		if seen[model.ID] {
+			return fmt.Errorf("%w: %q is listed twice", ErrInvalidModel, model.ID)
+		}
+		seen[model.ID] = true
+		if model.ContextWindow < 0 || model.OutputTokens < 0 {
+			return fmt.Errorf("%w: %s: token limits must not be negative", ErrInvalidModel, model.ID)
+		}
+		if p := model.Pricing; p != nil && (p.InputPerMillion < 0 || p.OutputPerMillion < 0) {
+			return fmt.Errorf("%w: %s: prices must not be negative", ErrInvalidModel, model.ID)
+		}
+		for _, modality := range slices.Concat(model.InputModalities, model.OutputModalities) {
+			if !slices.Contains(modalities, modality) {
+				return fmt.Errorf("%w: %s: unknown modality %q", ErrInvalidModel, model.ID, modality)
+			}
+		}
+	}
+	return nil
+}
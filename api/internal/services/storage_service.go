@@ -0,0 +1,268 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"gemiwin/api/internal/domain"
+	"gemiwin/api/internal/persistence"
+)
+
+// GCReport summarises a garbage collection of the file store.
+type GCReport struct {
+	Files      int   `json:"files"`
+	Referenced int   `json:"referenced"`
+	Deleted    int   `json:"deleted"`
+	FreedBytes int64 `json:"freed_bytes"`
+}
+
+// StoredFileUsage is one file attached to a chat.
+type StoredFileUsage struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+	// References counts the documents, across all chats, that point at the file.
+	References int `json:"references"`
+}
+
+// ChatStorageUsage reports the disk space taken by a chat and its files.
+type ChatStorageUsage struct {
+	ChatID    string            `json:"chat_id"`
+	Name      string            `json:"name"`
+	ChatBytes int64             `json:"chat_bytes"`
+	FileBytes int64             `json:"file_bytes"`
+	Files     []StoredFileUsage `json:"files"`
+}
+
+// StorageUsage reports the disk space taken by a user's chats and library documents. TotalBytes
+// counts files shared by several chats or documents once.
+type StorageUsage struct {
+	Chats      []ChatStorageUsage `json:"chats"`
+	Documents  []StoredFileUsage  `json:"documents"`
+	TotalBytes int64              `json:"total_bytes"`
+}
+
+// StorageService stores uploaded files under the SHA-256 of their content, so identical uploads
+// share one file, and removes files once no chat or library document references them any more.
+type StorageService struct {
+	chats     *persistence.ChatRepository
+	documents *persistence.DocumentRepository
+	files     *persistence.FileRepository
+
+	// mu serialises storing and collecting files. Stored files stay pinned until the document
+	// that references them has been saved.
+	mu   sync.Mutex
+	pins map[string]int
+}
+
+// NewStorageService creates a StorageService for the files referenced by chats and library
+// documents.
+func NewStorageService(chats *persistence.ChatRepository, documents *persistence.DocumentRepository, files *persistence.FileRepository) *StorageService {
+	return &StorageService{chats: chats, documents: documents, files: files, pins: make(map[string]int)}
+}
+
+// storedName returns the name data uploaded as fileName is stored under: the SHA-256 of the
+// content with the file's extension.
+func storedName(fileName string, data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]) + strings.ToLower(filepath.Ext(fileName))
+}
+
+// Store saves data under its content address with the lower-cased extension of fileName and
+// returns the stored name. The file is pinned until release is called, so call release once the
+// document referencing it has been saved (or has failed to be).
+func (s *StorageService) Store(fileName string, data []byte) (name string, release func(), err error) {
+	name = storedName(fileName, data)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.files.Exists(name) {
+		if err := s.files.Save(name, data); err != nil {
+			return "", nil, err
+		}
+	}
+	s.pins[name]++
+
+	var once sync.Once
+	release = func() {
+		once.Do(func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if s.pins[name]--; s.pins[name] <= 0 {
+				delete(s.pins, name)
+			}
+		})
+	}
+	return name, release, nil
+}
+
+// RemoveUnreferenced deletes those of names that no chat or library document references any
+// more. It is called after documents were removed from a chat or the library.
+func (s *StorageService) RemoveUnreferenced(names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	refs, err := s.references()
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if name == "" || refs[name] > 0 || s.pins[name] > 0 {
+			continue
+		}
+		if err := s.files.Delete(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CollectGarbage deletes every stored file that no chat or library document references.
+func (s *StorageService) CollectGarbage() (*GCReport, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	refs, err := s.references()
+	if err != nil {
+		return nil, err
+	}
+	stored, err := s.files.List()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &GCReport{Files: len(stored)}
+	for _, f := range stored {
+		if refs[f.Name] > 0 || s.pins[f.Name] > 0 {
+			report.Referenced++
+			continue
+		}
+		if err := s.files.Delete(f.Name); err != nil {
+			return report, err
+		}
+		report.Deleted++
+		report.FreedBytes += f.Size
+	}
+	return report, nil
+}
+
+// Usage reports the disk space taken by each of userID's chats and their files, and by their
+// library documents.
+func (s *StorageService) Usage(userID string) (*StorageUsage, error) {
+	refs, err := s.references()
+	if err != nil {
+		return nil, err
+	}
+	chats, err := s.chats.FindAllByOwner(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	usage := &StorageUsage{Chats: make([]ChatStorageUsage, 0, len(chats))}
+	counted := make(map[string]bool)
+	for _, chat := range chats {
+		chatBytes, err := s.chats.Size(chat.ID)
+		if err != nil {
+			return nil, err
+		}
+		entry := ChatStorageUsage{ChatID: chat.ID, Name: chat.Name, ChatBytes: chatBytes, Files: []StoredFileUsage{}}
+		usage.TotalBytes += chatBytes
+
+		seen := make(map[string]bool)
+		for _, doc := range chatDocuments(chat) {
+			if doc.ID == "" || seen[doc.ID] {
+				continue
+			}
+			seen[doc.ID] = true
+			info, err := s.files.Stat(doc.ID)
+			if err != nil {
+				return nil, err
+			}
+			if info == nil {
+				continue
+			}
+			entry.Files = append(entry.Files, StoredFileUsage{ID: doc.ID, Name: doc.Name, Size: info.Size, References: refs[doc.ID]})
+			entry.FileBytes += info.Size
+			if !counted[doc.ID] {
+				counted[doc.ID] = true
+				usage.TotalBytes += info.Size
+			}
+		}
+		usage.Chats = append(usage.Chats, entry)
+	}
+
+	docs, err := s.documents.FindAllByOwner(userID)
+	if err != nil {
+		return nil, err
+	}
+	usage.Documents = make([]StoredFileUsage, 0, len(docs))
+	for _, doc := range docs {
+		info, err := s.files.Stat(doc.FileID)
+		if err != nil {
+			return nil, err
+		}
+		if info == nil {
+			continue
+		}
+		usage.Documents = append(usage.Documents, StoredFileUsage{ID: doc.FileID, Name: doc.Name, Size: info.Size, References: refs[doc.FileID]})
+		if !counted[doc.FileID] {
+			counted[doc.FileID] = true
+			usage.TotalBytes += info.Size
+		}
+	}
+	return usage, nil
+}
+
+// references counts the chat and library documents pointing at each stored file. Any chat or
+// library entry that cannot be read fails the scan, so a file is never treated as orphaned
+// because whatever references it was unreadable.
+func (s *StorageService) references() (map[string]int, error) {
+	chats, err := s.chats.FindAllStrict()
+	if err != nil {
+		return nil, err
+	}
+	refs := make(map[string]int)
+	for _, chat := range chats {
+		for _, doc := range chatDocuments(chat) {
+			if doc.ID != "" {
+				refs[doc.ID]++
+			}
+		}
+	}
+	docs, err := s.documents.FindAll()
+	if err != nil {
+		return nil, err
+	}
+	for _, doc := range docs {
+		refs[doc.FileID]++
+	}
+	return refs, nil
+}
+
+// chatDocuments returns the documents attached to any message of chat.
+func chatDocuments(chat *domain.Chat) []domain.Document {
+	var docs []domain.Document
+	for _, msg := range chat.Messages {
+		docs = append(docs, msg.Documents...)
+	}
+	return docs
+}
+
+// documentIDs returns the ids of the files attached to messages.
+func documentIDs(messages []domain.Message) []string {
+	var ids []string
+	for _, msg := range messages {
+		for _, doc := range msg.Documents {
+			ids = append(ids, doc.ID)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,122 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"unicode/utf8"
+
+	"gemiwin/api/internal/domain"
+)
+
+// maxToolResultBytes caps the result of a tool call kept in the chat and sent to the model.
+const maxToolResultBytes = 16 << 10
+
+// ToolContext is what a tool knows about the call it is serving.
+type ToolContext struct {
+	// UserID is the owner of the chat, whose data the tool may read.
+	UserID string
+	ChatID string
+}
+
+// Tool is a function the model can ask the server to run while answering.
+type Tool struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// Parameters is the JSON schema of the arguments object.
+	Parameters json.RawMessage `json:"parameters"`
+	// Handler runs the tool with the arguments chosen by the model and returns its result as text.
+	Handler func(ctx ToolContext, args json.RawMessage) (string, error) `json:"-"`
+	// ReadOnly marks tools without side effects, which may run more than once for one message.
+	ReadOnly bool `json:"-"`
+}
+
+// ToolRegistry holds the tools available to the bot, by name.
+type ToolRegistry struct {
+	tools map[string]Tool
+}
+
+// NewToolRegistry creates an empty ToolRegistry.
+func NewToolRegistry() *ToolRegistry {
+	return &ToolRegistry{tools: map[string]Tool{}}
+}
+
+// Register adds tool. Names must be unique.
+func (r *ToolRegistry) Register(tool Tool) error {
+	if tool.Name == "" || tool.Handler == nil {
+		return fmt.Errorf("tool %q needs a name and a handler", tool.Name)
+	}
+	if _, ok := r.tools[tool.Name]; ok {
+		return fmt.Errorf("tool %q is already registered", tool.Name)
+	}
+	if len(tool.Parameters) == 0 {
+		tool.Parameters = json.RawMessage(`{"type":"object","properties":{}}`)
+	}
+	r.tools[tool.Name] = tool
+	return nil
+}
+
+// With returns a copy of r that also holds tools, replacing registered tools of the same name.
+func (r *ToolRegistry) With(tools ...Tool) *ToolRegistry {
+	copied := &ToolRegistry{tools: make(map[string]Tool, len(r.tools)+len(tools))}
+	for name, tool := range r.tools {
+		copied.tools[name] = tool
+	}
+	for _, tool := range tools {
+		copied.tools[tool.Name] = tool
+	}
+	return copied
+}
+
+// ReadOnly returns a copy of r without the tools that are not ReadOnly.
+func (r *ToolRegistry) ReadOnly() *ToolRegistry {
+	copied := &ToolRegistry{tools: make(map[string]Tool, len(r.tools))}
+	for name, tool := range r.tools {
+		if tool.ReadOnly {
+			copied.tools[name] = tool
+		}
+	}
+	return copied
+}
+
+// Tools returns every registered tool, sorted by name.
+func (r *ToolRegistry) Tools() []Tool {
+	tools := make([]Tool, 0, len(r.tools))
+	for _, tool := range r.tools {
+		tools = append(tools, tool)
+	}
+	sort.Slice(tools, func(i, j int) bool { return tools[i].Name < tools[j].Name })
+	return tools
+}
+
+// Call runs the tool requested by call and fills in its result, or the error the model should
+// see. Unknown tools and failing handlers are reported to the model rather than aborting the
+// answer.
+func (r *ToolRegistry) Call(ctx ToolContext, call domain.ToolCall) domain.ToolCall {
+	tool, ok := r.tools[call.Name]
+	if !ok {
+		call.Error = fmt.Sprintf("unknown tool %q", call.Name)
+		return call
+	}
+	if len(call.Arguments) == 0 {
+		call.Arguments = json.RawMessage("{}")
+	}
+	result, err := tool.Handler(ctx, call.Arguments)
+	if err != nil {
+		call.Error = err.Error()
+		return call
+	}
+	if len(result) > maxToolResultBytes {
+		result = truncateUTF8(result, maxToolResultBytes) + "\n[result truncated]"
+	}
+	call.Result = result
+	return call
+}
+
+// truncateUTF8 cuts s to at most n bytes without splitting a character.
+func truncateUTF8(s string, n int) string {
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
@@ -0,0 +1,154 @@
+package services
+
+import (
+	"errors"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"gemiwin/api/internal/extract"
+)
+
+var (
+	// ErrUnsupportedFileType is returned when an upload is not on the allowlist or its content
+	// does not match its extension.
+	ErrUnsupportedFileType = errors.New("unsupported file type")
+	// ErrNoFiles is returned when a file message carries neither files nor library documents.
+	ErrNoFiles = errors.New("no files were uploaded or attached")
+)
+
+// textExtensions are accepted as UTF-8 text and served back as text/plain.
+var textExtensions = map[string]bool{
+	".txt": true, ".md": true, ".markdown": true, ".json": true, ".yaml": true, ".yml": true,
+	".xml": true, ".csv": true, ".go": true, ".js": true, ".ts": true, ".py": true, ".java": true,
+	".c": true, ".cpp": true, ".rb": true, ".rs": true,
+}
+
+const maxFileNameLength = 255
+
+// SanitizeFileName reduces a client-supplied file name to a safe base name without path
+// components or control characters.
+func SanitizeFileName(name string) string {
+	// Clients may send Windows paths regardless of the server OS
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+		name = name[i+1:]
+	}
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || r == '"' {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimLeft(strings.TrimSpace(name), ".")
+
+	if len(name) > maxFileNameLength {
+		ext := filepath.Ext(name)
+		if len(ext) > 16 {
+			ext = ""
+		}
+		name = strings.ToValidUTF8(name[:maxFileNameLength-len(ext)], "") + ext
+	}
+	if name == "" {
+		return "file"
+	}
+	return name
+}
+
+// documentType describes an accepted binary or markup format.
+type documentType struct {
+	mimeType string // MIME type the content must sniff as
+	served   string // content type the file is served with
+	inline   bool   // whether the file may be displayed inline
+}
+
+// documentTypes are accepted when extract.Detect agrees with the extension. HTML is served as
+// text so an upload can never run as a page. Images and audio have no text; they reach the model
+// only through a backend that accepts inline data.
+var documentTypes = map[string]documentType{
+	".pdf":  {"application/pdf", "application/pdf", true},
+	".docx": {extract.MIMEDocx, extract.MIMEDocx, false},
+	".xlsx": {extract.MIMEXlsx, extract.MIMEXlsx, false},
+	".pptx": {extract.MIMEPptx, extract.MIMEPptx, false},
+	".epub": {extract.MIMEEpub, extract.MIMEEpub, false},
+	".html": {"text/html", "text/plain; charset=utf-8", true},
+	".htm":  {"text/html", "text/plain; charset=utf-8", true},
+	".rtf":  {"text/rtf", "application/rtf", false},
+	".png":  {"image/png", "image/png", true},
+	".jpg":  {"image/jpeg", "image/jpeg", true},
+	".jpeg": {"image/jpeg", "image/jpeg", true},
+	".webp": {"image/webp", "image/webp", true},
+	".heic": {"image/heic", "image/heic", false},
+	".heif": {"image/heif", "image/heif", false},
+	".mp3":  {"audio/mpeg", "audio/mpeg", true},
+	".wav":  {"audio/wav", "audio/wav", true},
+	".ogg":  {"audio/ogg", "audio/ogg", true},
+	".flac": {"audio/flac", "audio/flac", true},
+	".aac":  {"audio/aac", "audio/aac", true},
+	".aiff": {"audio/aiff", "audio/aiff", false},
+}
+
+// ValidateUpload checks an upload against the allowlist of file types. The extension decides
+// what the file claims to be and the content must agree: documents must sniff as their format
+// and text files must be valid UTF-8 that does not sniff as HTML or binary data.
+func ValidateUpload(fileName string, data []byte) error {
+	ext := strings.ToLower(filepath.Ext(fileName))
+
+	if t, ok := documentTypes[ext]; ok {
+		if extract.Detect(data) != t.mimeType {
+			return ErrUnsupportedFileType
+		}
+		return nil
+	}
+	if !textExtensions[ext] {
+		return ErrUnsupportedFileType
+	}
+	sniffed := http.DetectContentType(data)
+	if !utf8.Valid(data) || !strings.HasPrefix(sniffed, "text/plain") && !strings.HasPrefix(sniffed, "text/xml") {
+		return ErrUnsupportedFileType
+	}
+	return nil
+}
+
+// ServedContentType returns the content type an uploaded file is served with and whether it may
+// be displayed inline. Text is always served as text/plain so it can never run as a page.
+func ServedContentType(fileName string) (contentType string, inline bool) {
+	ext := strings.ToLower(filepath.Ext(fileName))
+	if t, ok := documentTypes[ext]; ok {
+		return t.served, t.inline
+	}
+	if textExtensions[ext] {
+		return "text/plain; charset=utf-8", true
+	}
+	return "application/octet-stream", false
+}
+
+// sniffedExtensions name content by its sniffed type when its name has no fitting extension.
+var sniffedExtensions = map[string]string{
+	"text/plain": ".txt", "application/json": ".json", "text/xml": ".xml", "text/html": ".html",
+	"application/pdf": ".pdf", extract.MIMEDocx: ".docx", extract.MIMEXlsx: ".xlsx",
+	extract.MIMEPptx: ".pptx", extract.MIMEEpub: ".epub", "text/rtf": ".rtf",
+	"image/png": ".png", "image/jpeg": ".jpg", "image/webp": ".webp", "image/heic": ".heic",
+	"image/heif": ".heif", "audio/mpeg": ".mp3", "audio/wav": ".wav", "audio/ogg": ".ogg",
+	"audio/flac": ".flac", "audio/aac": ".aac", "audio/aiff": ".aiff",
+}
+
+// NameForContent returns a file name for data that passes ValidateUpload: name itself when its
+// extension fits the content, or name with the extension of the sniffed type appended. It is used
+// for content whose name does not come from a file, such as a fetched web page.
+func NameForContent(name string, data []byte) (string, error) {
+	name = SanitizeFileName(name)
+	if ValidateUpload(name, data) == nil {
+		return name, nil
+	}
+	ext, ok := sniffedExtensions[extract.Detect(data)]
+	if !ok {
+		return "", ErrUnsupportedFileType
+	}
+	name = SanitizeFileName(name + ext)
+	if err := ValidateUpload(name, data); err != nil {
+		return "", err
+	}
+	return name, nil
+}
@@ -0,0 +1,283 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var (
+	// ErrURLNotAllowed is returned for URLs that are not http(s) or whose host is denied, not
+	// allowlisted or resolves to a private address.
+	ErrURLNotAllowed = errors.New("URL not allowed")
+	// ErrURLTooLarge is returned when a fetched resource exceeds the size limit.
+	ErrURLTooLarge = errors.New("fetched resource is too large")
+	// ErrURLFetchFailed is returned when the resource cannot be downloaded.
+	ErrURLFetchFailed = errors.New("failed to fetch URL")
+)
+
+// maxRedirects caps how many redirects a fetch follows.
+const maxRedirects = 5
+
+// URLPolicy limits what URLFetcher may download.
+type URLPolicy struct {
+	// AllowHosts, when not empty, lists the only hosts that may be fetched. A host matches itself
+	// and its subdomains. Allowlisted hosts may resolve to private addresses.
+	AllowHosts []string
+	// DenyHosts are never fetched, even when allowlisted.
+	DenyHosts []string
+	MaxBytes  int64
+	Timeout   time.Duration
+}
+
+// FetchedURL is a downloaded web page or file.
+type FetchedURL struct {
+	// URL is the address the content was finally served from, after redirects.
+	URL  string
+	Name string
+	Data []byte
+}
+
+// URLFetcher downloads web pages and files for use as documents. Without an allowlist only public
+// addresses are reachable, so a chat cannot be used to probe the local network.
+type URLFetcher struct {
+	policy URLPolicy
+	client *http.Client
+}
+
+type privateAllowedKey struct{}
+
+// NewURLFetcher creates a URLFetcher that enforces policy.
+func NewURLFetcher(policy URLPolicy) *URLFetcher {
+	f := &URLFetcher{policy: policy}
+	f.client = &http.Client{
+		Transport: f.transport(policy.Timeout),
+		Timeout:   policy.Timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("%w: too many redirects", ErrURLFetchFailed)
+			}
+			_, err := f.checkURL(req.URL)
+			return err
+		},
+	}
+	return f
+}
+
+// Fetch downloads rawURL. The host is checked against the policy before connecting and again on
+// every redirect, and the address connected to must be public unless the host is allowlisted.
+func (f *URLFetcher) Fetch(rawURL string) (*FetchedURL, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrURLNotAllowed, err)
+	}
+	privateAllowed, err := f.checkURL(u)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.WithValue(context.Background(), privateAllowedKey{}, privateAllowed)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrURLNotAllowed, err)
+	}
+	req.Header.Set("User-Agent", "gemiwin")
+	req.Header.Set("Accept", "text/html, application/pdf, text/plain;q=0.9, */*;q=0.5")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		if errors.Is(err, ErrURLNotAllowed) {
+			return nil, policyError(err)
+		}
+		return nil, fmt.Errorf("%w: %v", ErrURLFetchFailed, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: status %d", ErrURLFetchFailed, resp.StatusCode)
+	}
+	if resp.ContentLength > f.policy.MaxBytes {
+		return nil, ErrURLTooLarge
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, f.policy.MaxBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrURLFetchFailed, err)
+	}
+	if int64(len(data)) > f.policy.MaxBytes {
+		return nil, ErrURLTooLarge
+	}
+
+	final := resp.Request.URL
+	return &FetchedURL{URL: final.String(), Name: urlFileName(final), Data: data}, nil
+}
+
+// transport returns a transport that connects through the guarded dialer and waits up to timeout
+// for response headers.
+func (f *URLFetcher) transport(timeout time.Duration) *http.Transport {
+	return &http.Transport{
+		// A proxy would hide the address actually connected to from the dial check
+		Proxy:                 nil,
+		DialContext:           f.dial,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: timeout,
+		MaxIdleConns:          4,
+		IdleConnTimeout:       30 * time.Second,
+	}
+}
+
+// Client returns an HTTP client that enforces the policy on every request like Fetch, for
+// services that talk to endpoints chosen by users. Its requests may take up to timeout, however
+// long fetches are allowed to take.
+func (f *URLFetcher) Client(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Transport: policyTransport{fetcher: f, base: f.transport(timeout)},
+		Timeout:   timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("%w: too many redirects", ErrURLFetchFailed)
+			}
+			return nil
+		},
+	}
+}
+
+// policyTransport checks each request's URL before sending it through the guarded dialer.
+type policyTransport struct {
+	fetcher *URLFetcher
+	base    http.RoundTripper
+}
+
+func (t policyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	privateAllowed, err := t.fetcher.checkURL(req.URL)
+	if err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+	ctx := context.WithValue(req.Context(), privateAllowedKey{}, privateAllowed)
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil && errors.Is(err, ErrURLNotAllowed) {
+		return nil, policyError(err)
+	}
+	return resp, err
+}
+
+// checkURL validates the scheme and host of u and reports whether the host was explicitly
+// allowlisted, which permits private addresses.
+func (f *URLFetcher) checkURL(u *url.URL) (privateAllowed bool, err error) {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return false, fmt.Errorf("%w: only http and https URLs can be fetched", ErrURLNotAllowed)
+	}
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if host == "" {
+		return false, fmt.Errorf("%w: missing host", ErrURLNotAllowed)
+	}
+	if matchHost(host, f.policy.DenyHosts) {
+		return false, fmt.Errorf("%w: %s is denied", ErrURLNotAllowed, host)
+	}
+	if len(f.policy.AllowHosts) > 0 {
+		if !matchHost(host, f.policy.AllowHosts) {
+			return false, fmt.Errorf("%w: %s is not allowlisted", ErrURLNotAllowed, host)
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// dial connects like net.Dialer but refuses private addresses unless the request's host was
+// allowlisted. The check runs on the resolved address, so DNS cannot smuggle in a local target.
+func (f *URLFetcher) dial(ctx context.Context, network, address string) (net.Conn, error) {
+	privateAllowed, _ := ctx.Value(privateAllowedKey{}).(bool)
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(_, address string, _ syscall.RawConn) error {
+			if privateAllowed {
+				return nil
+			}
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip, err := netip.ParseAddr(host); err != nil || isPrivateIP(ip) {
+				return fmt.Errorf("%w: %s is a private address", ErrURLNotAllowed, host)
+			}
+			return nil
+		},
+	}
+	return dialer.DialContext(ctx, network, address)
+}
+
+// policyError unwraps the policy violation from the request and dial errors wrapped around it.
+func policyError(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		err = urlErr.Err
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		err = opErr.Err
+	}
+	return err
+}
+
+// reservedPrefixes are not covered by the netip.Addr predicates but must not be reached either.
+var reservedPrefixes = []netip.Prefix{
+	// "This network", which some systems route to the local host
+	netip.MustParsePrefix("0.0.0.0/8"),
+	// Shared address space of carrier-grade NAT (RFC 6598)
+	netip.MustParsePrefix("100.64.0.0/10"),
+	// Benchmarking (RFC 2544) and reserved space, including the broadcast address
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	// NAT64 (RFC 6052, RFC 8215) and IPv4-translated addresses (RFC 2765), which reach IPv4
+	// addresses through a translator
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+	netip.MustParsePrefix("::ffff:0:0:0/96"),
+}
+
+// isPrivateIP reports whether ip is not a public unicast address. IPv4-mapped IPv6 addresses are
+// checked as the IPv4 address they carry.
+func isPrivateIP(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return true
+	}
+	for _, prefix := range reservedPrefixes {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchHost reports whether host equals one of hosts or is a subdomain of one.
+func matchHost(host string, hosts []string) bool {
+	for _, h := range hosts {
+		h = strings.ToLower(strings.TrimSuffix(h, "."))
+		if host == h || strings.HasSuffix(host, "."+h) {
+			return true
+		}
+	}
+	return false
+}
+
+// urlFileName names a fetched resource after the last path segment of u, or its host.
+func urlFileName(u *url.URL) string {
+	name := path.Base(u.Path)
+	if name == "." || name == "/" || name == "" {
+		name = u.Hostname()
+	}
+	return SanitizeFileName(name)
+}
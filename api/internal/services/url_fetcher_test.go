@@ -0,0 +1,273 @@
+package services
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIsPrivateIP(t *testing.T) {
+	tests := []struct {
+		ip      string
+		private bool
+	}{
+		{"8.8.8.8", false},
+		{"1.1.1.1", false},
+		{"2606:4700:4700::1111", false},
+		{"127.0.0.1", true},
+		{"10.1.2.3", true},
+		{"172.16.0.1", true},
+		{"192.168.1.1", true},
+		{"169.254.169.254", true},
+		{"100.64.0.1", true},
+		{"0.0.0.0", true},
+		{"0.1.2.3", true},
+		{"198.18.0.1", true},
+		{"240.0.0.1", true},
+		{"255.255.255.255", true},
+		{"224.0.0.1", true},
+		{"::", true},
+		{"::1", true},
+		{"fc00::1", true},
+		{"fe80::1", true},
+		{"ff02::1", true},
+		{"::ffff:127.0.0.1", true},
+		{"::ffff:10.0.0.1", true},
+		{"::ffff:8.8.8.8", false},
+		{"::ffff:0:127.0.0.1", true},
+		{"64:ff9b::a00:1", true},
+		{"64:ff9b::808:808", true},
+		{"64:ff9b:1::1", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			if got := isPrivateIP(netip.MustParseAddr(tt.ip)); got != tt.private {
+				t.Errorf("isPrivateIP(%s) = %v, want %v", tt.ip, got, tt.private)
+			}
+		})
+	}
+}
+
+func TestMatchHost(t *testing.T) {
+	tests := []struct {
+		host  string
+		hosts []string
+		match bool
+	}{
+		{"example.com", []string{"example.com"}, true},
+		{"docs.example.com", []string{"example.com"}, true},
+		{"example.com", []string{"Example.COM."}, true},
+		{"notexample.com", []string{"example.com"}, false},
+		{"example.com.evil.net", []string{"example.com"}, false},
+		{"example.com", nil, false},
+	}
+	for _, tt := range tests {
+		if got := matchHost(tt.host, tt.hosts); got != tt.match {
+			t.Errorf("matchHost(%q, %v) = %v, want %v", tt.host, tt.hosts, got, tt.match)
+		}
+	}
+}
+
+// newTestFetcher returns a fetcher with small limits that may reach hosts.
+func newTestFetcher(allow []string, deny []string) *URLFetcher {
+	return NewURLFetcher(URLPolicy{AllowHosts: allow, DenyHosts: deny, MaxBytes: 64, Timeout: 2 * time.Second})
+}
+
+// serverHost returns the host (without port) and port of srv.
+func serverHost(t *testing.T, srv *httptest.Server) (string, string) {
+	t.Helper()
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u.Hostname(), u.Port()
+}
+
+func TestFetchPolicy(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello"))
+	}))
+	defer srv.Close()
+	host, port := serverHost(t, srv)
+
+	tests := []struct {
+		name  string
+		allow []string
+		deny  []string
+		url   string
+		err   error
+	}{
+		{"private address without allowlist", nil, nil, srv.URL, ErrURLNotAllowed},
+		{"private host name without allowlist", nil, nil, "http://localhost:" + port + "/", ErrURLNotAllowed},
+		{"IPv4-mapped private address", nil, nil, "http://[::ffff:127.0.0.1]:" + port + "/", ErrURLNotAllowed},
+		{"unspecified address", nil, nil, "http://0.0.0.0:" + port + "/", ErrURLNotAllowed},
+		{"other scheme", nil, nil, "ftp://" + host + "/", ErrURLNotAllowed},
+		{"missing host", nil, nil, "http:///path", ErrURLNotAllowed},
+		{"allowlisted private address", []string{host}, nil, srv.URL, nil},
+		{"host outside allowlist", []string{"example.com"}, nil, srv.URL, ErrURLNotAllowed},
+		{"denied host", nil, []string{host}, srv.URL, ErrURLNotAllowed},
+		{"deny wins over allow", []string{host}, []string{host}, srv.URL, ErrURLNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fetched, err := newTestFetcher(tt.allow, tt.deny).Fetch(tt.url)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Fetch(%s) error = %v, want %v", tt.url, err, tt.err)
+			}
+			if err == nil && string(fetched.Data) != "hello" {
+				t.Errorf("Fetch(%s) data = %q, want %q", tt.url, fetched.Data, "hello")
+			}
+		})
+	}
+}
+
+func TestFetchRedirects(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("target"))
+	}))
+	defer target.Close()
+	_, targetPort := serverHost(t, target)
+
+	redirects := map[string]string{
+		// Another private host than the allowlisted one
+		"/private": "http://localhost:" + targetPort + "/",
+		"/mapped":  "http://[::ffff:127.0.0.1]:" + targetPort + "/",
+		"/scheme":  "file:///etc/passwd",
+		"/same":    target.URL + "/",
+	}
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/loop" {
+			http.Redirect(w, r, srv.URL+"/loop", http.StatusFound)
+			return
+		}
+		http.Redirect(w, r, redirects[r.URL.Path], http.StatusFound)
+	}))
+	defer srv.Close()
+	host, _ := serverHost(t, srv)
+
+	tests := []struct {
+		path string
+		err  error
+	}{
+		{"/private", ErrURLNotAllowed},
+		{"/mapped", ErrURLNotAllowed},
+		{"/scheme", ErrURLNotAllowed},
+		{"/loop", ErrURLFetchFailed},
+		{"/same", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			// Only the first hop's host is allowlisted, which permits its private address
+			fetched, err := newTestFetcher([]string{host}, nil).Fetch(srv.URL + tt.path)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Fetch(%s) error = %v, want %v", tt.path, err, tt.err)
+			}
+			if err == nil && fetched.URL != target.URL+"/" {
+				t.Errorf("Fetch(%s) URL = %s, want %s/", tt.path, fetched.URL, target.URL)
+			}
+		})
+	}
+}
+
+func TestFetchLimits(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/fits":
+			w.Write([]byte(strings.Repeat("a", 64)))
+		case "/large":
+			w.Write([]byte(strings.Repeat("a", 65)))
+		case "/streamed":
+			// Without a Content-Length the limit applies while reading
+			w.Header().Set("Content-Type", "text/plain")
+			for range 10 {
+				w.Write([]byte(strings.Repeat("a", 10)))
+				w.(http.Flusher).Flush()
+			}
+		case "/slow":
+			time.Sleep(500 * time.Millisecond)
+			w.Write([]byte("late"))
+		case "/missing":
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	host, _ := serverHost(t, srv)
+
+	fetcher := NewURLFetcher(URLPolicy{AllowHosts: []string{host}, MaxBytes: 64, Timeout: 200 * time.Millisecond})
+	tests := []struct {
+		path string
+		err  error
+	}{
+		{"/fits", nil},
+		{"/large", ErrURLTooLarge},
+		{"/streamed", ErrURLTooLarge},
+		{"/slow", ErrURLFetchFailed},
+		{"/missing", ErrURLFetchFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if _, err := fetcher.Fetch(srv.URL + tt.path); !errors.Is(err, tt.err) {
+				t.Fatalf("Fetch(%s) error = %v, want %v", tt.path, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestFetchName(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("report"))
+	}))
+	defer srv.Close()
+	host, _ := serverHost(t, srv)
+
+	fetched, err := newTestFetcher([]string{host}, nil).Fetch(srv.URL + "/docs/report.txt?x=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fetched.Name != "report.txt" {
+		t.Errorf("Name = %q, want report.txt", fetched.Name)
+	}
+}
+
+func TestClientEnforcesPolicy(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+	host, _ := serverHost(t, srv)
+
+	if _, err := newTestFetcher(nil, nil).Client(time.Second).Post(srv.URL, "application/json", strings.NewReader("{}")); !errors.Is(err, ErrURLNotAllowed) {
+		t.Errorf("POST to a private address: error = %v, want %v", err, ErrURLNotAllowed)
+	}
+	resp, err := newTestFetcher([]string{host}, nil).Client(time.Second).Post(srv.URL, "application/json", strings.NewReader("{}"))
+	if err != nil {
+		t.Fatalf("POST to an allowlisted host: %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestClientTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(300 * time.Millisecond)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+	host, _ := serverHost(t, srv)
+
+	// Fetches give up after 100ms, but the client has its own, longer timeout
+	fetcher := NewURLFetcher(URLPolicy{AllowHosts: []string{host}, MaxBytes: 64, Timeout: 100 * time.Millisecond})
+	resp, err := fetcher.Client(2*time.Second).Post(srv.URL, "application/json", strings.NewReader("{}"))
+	if err != nil {
+		t.Fatalf("POST slower than the fetch timeout: %v", err)
+	}
+	resp.Body.Close()
+	if _, err := fetcher.Client(100*time.Millisecond).Post(srv.URL, "application/json", strings.NewReader("{}")); err == nil {
+		t.Error("POST slower than the client timeout succeeded")
+	}
+}
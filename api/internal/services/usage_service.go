@@ -0,0 +1,294 @@
+package services
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"gemiwin/api/internal/domain"
+	"gemiwin/api/internal/persistence"
+)
+
+// usageDateLayout formats the days of a usage report, in the server's time zone.
+const usageDateLayout = "2006-01-02"
+
+// UsageTotals adds up the usage of a set of bot answers.
+type UsageTotals struct {
+	Messages     int `json:"messages"`
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+	// EstimatedMessages counts answers whose token counts were estimated from the text length.
+	EstimatedMessages int   `json:"estimated_messages"`
+	LatencyMs         int64 `json:"latency_ms"`
+	// CostUSD adds up the answers' costs at the prices of the time. UnpricedMessages counts the
+	// answers of models without a price, which it leaves out.
+	CostUSD          float64 `json:"cost_usd"`
+	UnpricedMessages int     `json:"unpriced_messages"`
+}
+
+// ChatUsage is the usage of one chat.
+type ChatUsage struct {
+	ChatID string `json:"chat_id"`
+	Name   string `json:"name"`
+	UsageTotals
+}
+
+// DailyUsage is the usage of one day.
+type DailyUsage struct {
+	Date string `json:"date"`
+	UsageTotals
+}
+
+// ModelUsage is the usage of one model.
+type ModelUsage struct {
+	Model string `json:"model"`
+	UsageTotals
+}
+
+// UsageReport breaks down a user's usage by chat, day and model. Chats and models are sorted by
+// cost, most expensive first, and days by date.
+type UsageReport struct {
+	Total   UsageTotals  `json:"total"`
+	ByChat  []ChatUsage  `json:"by_chat"`
+	ByDay   []DailyUsage `json:"by_day"`
+	ByModel []ModelUsage `json:"by_model"`
+}
+
+// UsageFilter selects the answers a report covers. Zero values do not filter.
+type UsageFilter struct {
+	// From and To bound the answers' timestamps; To is exclusive.
+	From   time.Time
+	To     time.Time
+	ChatID string
+}
+
+// UsageService records the tokens and cost of each answer in its owner's usage ledger, and
+// reports them.
+type UsageService struct {
+	ledger *persistence.UsageRepository
+	chats  *persistence.ChatRepository
+	models *ModelRegistry
+}
+
+// NewUsageService creates a UsageService that keeps usage in ledger, prices answers with the
+// models in models and names the chats in its reports from chats.
+func NewUsageService(ledger *persistence.UsageRepository, chats *persistence.ChatRepository, models *ModelRegistry) *UsageService {
+	return &UsageService{ledger: ledger, chats: chats, models: models}
+}
+
+// Record prices answers given in chatID at the current prices, setting their CostUSD, and adds
+// them to userID's ledger. chatID is empty for completions that are not saved.
+func (s *UsageService) Record(userID string, chatID string, answers ...*domain.Usage) error {
+	prices, err := s.pricing(userID)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	records := make([]domain.UsageRecord, 0, len(answers))
+	for _, answer := range answers {
+		priceUsage(answer, prices[answer.Model])
+		records = append(records, domain.UsageRecord{ChatID: chatID, Usage: *answer, Timestamp: now})
+	}
+	return s.ledger.Append(userID, records...)
+}
+
+// Total adds up userID's answers selected by filter.
+func (s *UsageService) Total(userID string, filter UsageFilter) (UsageTotals, error) {
+	var total UsageTotals
+	records, err := s.records(userID, filter)
+	if err != nil {
+		return total, err
+	}
+	for _, record := range records {
+		total.add(&record.Usage)
+	}
+	total.roundCost()
+	return total, nil
+}
+
+// Report returns the usage of userID's answers selected by filter. Answers of deleted chats and
+// of completions that were not saved are included. ErrChatNotFound is returned if filter names a
+// chat userID neither has nor had answers in.
+func (s *UsageService) Report(userID string, filter UsageFilter) (*UsageReport, error) {
+	records, err := s.records(userID, filter)
+	if err != nil {
+		return nil, err
+	}
+	if filter.ChatID != "" && len(records) == 0 {
+		chat, err := s.chats.FindByIDForOwner(filter.ChatID, userID)
+		if err != nil {
+			return nil, err
+		}
+		if chat == nil {
+			return nil, ErrChatNotFound
+		}
+	}
+
+	report := &UsageReport{ByChat: []ChatUsage{}, ByDay: []DailyUsage{}, ByModel: []ModelUsage{}}
+	byChat := map[string]*UsageTotals{}
+	days := map[string]*UsageTotals{}
+	byModel := map[string]*UsageTotals{}
+	for _, record := range records {
+		day := record.Timestamp.Local().Format(usageDateLayout)
+		for key, totals := range map[string]map[string]*UsageTotals{record.ChatID: byChat, day: days, record.Model: byModel} {
+			if totals[key] == nil {
+				totals[key] = &UsageTotals{}
+			}
+		}
+		for _, totals := range []*UsageTotals{&report.Total, byChat[record.ChatID], days[day], byModel[record.Model]} {
+			totals.add(&record.Usage)
+		}
+	}
+
+	report.Total.roundCost()
+	for chatID, totals := range byChat {
+		totals.roundCost()
+		usage := ChatUsage{ChatID: chatID, UsageTotals: *totals}
+		// Deleted chats and unsaved completions keep their usage but have no name
+		if chatID != "" {
+			if chat, err := s.chats.FindByIDForOwner(chatID, userID); err == nil && chat != nil {
+				usage.Name = chat.Name
+			}
+		}
+		report.ByChat = append(report.ByChat, usage)
+	}
+	for day, totals := range days {
+		totals.roundCost()
+		report.ByDay = append(report.ByDay, DailyUsage{Date: day, UsageTotals: *totals})
+	}
+	for model, totals := range byModel {
+		totals.roundCost()
+		report.ByModel = append(report.ByModel, ModelUsage{Model: model, UsageTotals: *totals})
+	}
+	sort.Slice(report.ByDay, func(i, j int) bool { return report.ByDay[i].Date < report.ByDay[j].Date })
+	sort.Slice(report.ByChat, func(i, j int) bool {
+		if report.ByChat[i].CostUSD != report.ByChat[j].CostUSD {
+			return report.ByChat[i].CostUSD > report.ByChat[j].CostUSD
+		}
+		return report.ByChat[i].ChatID < report.ByChat[j].ChatID
+	})
+	sort.Slice(report.ByModel, func(i, j int) bool {
+		if report.ByModel[i].CostUSD != report.ByModel[j].CostUSD {
+			return report.ByModel[i].CostUSD > report.ByModel[j].CostUSD
+		}
+		return report.ByModel[i].Model < report.ByModel[j].Model
+	})
+	return report, nil
+}
+
+// records returns the ledger records of userID selected by filter.
+func (s *UsageService) records(userID string, filter UsageFilter) ([]domain.UsageRecord, error) {
+	records, err := s.ledger.FindByOwner(userID, filter.From, filter.To)
+	if err != nil || filter.ChatID == "" {
+		return records, err
+	}
+	selected := []domain.UsageRecord{}
+	for _, record := range records {
+		if record.ChatID == filter.ChatID {
+			selected = append(selected, record)
+		}
+	}
+	return selected, nil
+}
+
+// Backfill starts the ledger of each of userIDs that has none from the answers stored in their
+// chats, priced at the current prices. Usage recorded before the ledger existed is kept this way.
+func (s *UsageService) Backfill(userIDs []string) error {
+	for _, userID := range userIDs {
+		started, err := s.ledger.HasOwner(userID)
+		if err != nil {
+			return err
+		}
+		if started {
+			continue
+		}
+		chats, err := s.chats.FindAllByOwner(userID)
+		if err != nil {
+			return err
+		}
+		prices, err := s.pricing(userID)
+		if err != nil {
+			return err
+		}
+		var records []domain.UsageRecord
+		for _, chat := range chats {
+			for _, msg := range chat.Messages {
+				for _, usage := range messageUsage(msg) {
+					record := domain.UsageRecord{ChatID: chat.ID, Usage: *usage, Timestamp: msg.Timestamp}
+					priceUsage(&record.Usage, prices[usage.Model])
+					records = append(records, record)
+				}
+			}
+		}
+		if err := s.ledger.Append(userID, records...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// messageUsage returns the usage of each answer msg holds: every alternate of a comparison, which
+// were all paid for, or the message's own.
+func messageUsage(msg domain.Message) []*domain.Usage {
+	if len(msg.Alternates) == 0 {
+		if msg.Usage == nil {
+			return nil
+		}
+		return []*domain.Usage{msg.Usage}
+	}
+	usages := make([]*domain.Usage, 0, len(msg.Alternates))
+	for _, alternate := range msg.Alternates {
+		if alternate.Usage != nil {
+			usages = append(usages, alternate.Usage)
+		}
+	}
+	return usages
+}
+
+// pricing returns the prices of the models userID can use, by id; models without a price are nil.
+func (s *UsageService) pricing(userID string) (map[string]*domain.ModelPricing, error) {
+	models, err := s.models.Models(userID)
+	if err != nil {
+		return nil, err
+	}
+	prices := make(map[string]*domain.ModelPricing, len(models))
+	for _, model := range models {
+		prices[model.ID] = model.Pricing
+	}
+	return prices, nil
+}
+
+// add counts one answer.
+func (t *UsageTotals) add(usage *domain.Usage) {
+	t.Messages++
+	t.InputTokens += usage.InputTokens
+	t.OutputTokens += usage.OutputTokens
+	t.LatencyMs += usage.LatencyMs
+	if usage.Estimated {
+		t.EstimatedMessages++
+	}
+	if usage.CostUSD == nil {
+		t.UnpricedMessages++
+		return
+	}
+	t.CostUSD += *usage.CostUSD
+}
+
+// roundCost rounds CostUSD to millionths of a dollar, hiding floating point noise.
+func (t *UsageTotals) roundCost() {
+	t.CostUSD = math.Round(t.CostUSD*1e6) / 1e6
+}
+
+// priceUsage sets the CostUSD of usage at pricing, unless the model has no price or it is set.
+func priceUsage(usage *domain.Usage, pricing *domain.ModelPricing) {
+	if usage.CostUSD != nil || pricing == nil {
+		return
+	}
+	cost := math.Round(usageCost(usage, pricing)*1e9) / 1e9
+	usage.CostUSD = &cost
+}
+
+// usageCost is what usage costs at pricing, in US dollars.
+func usageCost(usage *domain.Usage, pricing *domain.ModelPricing) float64 {
+	return (float64(usage.InputTokens)*pricing.InputPerMillion + float64(usage.OutputTokens)*pricing.OutputPerMillion) / 1e6
+}
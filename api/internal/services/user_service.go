@@ -0,0 +1,314 @@
+package services
+
+import (
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gemiwin/api/internal/domain"
+	"gemiwin/api/internal/persistence"
+
+	"github.com/google/uuid"
+)
+
+// Errors returned by UserService.
+var (
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	ErrUsernameTaken      = errors.New("username already exists")
+	ErrUserNotFound       = errors.New("user not found")
+	ErrTokenNotFound      = errors.New("token not found")
+	ErrLocalUser          = errors.New("the local user is managed through the launch token")
+	ErrInvalidUsername    = errors.New("username must be 1-64 letters, digits, '.', '_' or '-'")
+	ErrWeakPassword       = errors.New("password is too short")
+)
+
+const (
+	passwordIterations = 600000
+	minPasswordLength  = 8
+	apiTokenPrefix     = "gmw_"
+)
+
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// UserService manages local accounts and resolves bearer tokens to users.
+type UserService struct {
+	repo        *persistence.UserRepository
+	launchToken string
+	logins      *loginLimiter
+}
+
+// NewUserService creates a UserService. Requests presenting launchToken act as the local user.
+func NewUserService(repo *persistence.UserRepository, launchToken string) *UserService {
+	return &UserService{repo: repo, launchToken: launchToken, logins: newLoginLimiter()}
+}
+
+// LocalUser returns the built-in administrator behind the per-launch token.
+func LocalUser() *domain.User {
+	return &domain.User{ID: domain.LocalUserID, Username: domain.LocalUserID, Admin: true}
+}
+
+// Authenticate returns the user a bearer token belongs to, or nil if it is not valid.
+func (s *UserService) Authenticate(token string) (*domain.User, error) {
+	if token == "" {
+		return nil, nil
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(s.launchToken)) == 1 {
+		return LocalUser(), nil
+	}
+	if !strings.HasPrefix(token, apiTokenPrefix) {
+		return nil, nil
+	}
+	return s.repo.FindByTokenHash(hashToken(token))
+}
+
+// Login checks a username and password sent from the address ip and issues a new API token named
+// name. Addresses and usernames with too many recent attempts get a LoginThrottledError before
+// the password is checked.
+func (s *UserService) Login(username string, password string, name string, ip string) (string, *domain.PublicAPIToken, error) {
+	now := time.Now()
+	userKey := "user\x00" + username
+	if err := s.logins.check(userKey, maxLoginFailuresPerUser, now); err != nil {
+		return "", nil, err
+	}
+	if err := s.logins.take("ip\x00"+ip, maxLoginAttemptsPerIP, now); err != nil {
+		return "", nil, err
+	}
+
+	user, err := s.repo.FindByUsername(username)
+	if err != nil {
+		return "", nil, err
+	}
+	if user == nil {
+		// Spend the same time as a real check so usernames cannot be probed
+		verifyPassword("", password)
+		s.logins.add(userKey, now)
+		return "", nil, ErrInvalidCredentials
+	}
+	if !verifyPassword(user.PasswordHash, password) {
+		s.logins.add(userKey, now)
+		return "", nil, ErrInvalidCredentials
+	}
+	s.logins.reset(userKey)
+	if name == "" {
+		name = "login"
+	}
+	return s.addToken(user.ID, name)
+}
+
+// GetUser returns the public view of the user with the given id.
+func (s *UserService) GetUser(id string) (*domain.PublicUser, error) {
+	if id == domain.LocalUserID {
+		return LocalUser().Public(), nil
+	}
+	user, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	return user.Public(), nil
+}
+
+// ListUsers returns every account, starting with the local user.
+func (s *UserService) ListUsers() ([]*domain.PublicUser, error) {
+	users, err := s.repo.FindAll()
+	if err != nil {
+		return nil, err
+	}
+	out := []*domain.PublicUser{LocalUser().Public()}
+	for _, u := range users {
+		out = append(out, u.Public())
+	}
+	return out, nil
+}
+
+// CreateUser adds an account with the given password.
+func (s *UserService) CreateUser(username string, password string, admin bool) (*domain.PublicUser, error) {
+	if !usernamePattern.MatchString(username) || username == domain.LocalUserID {
+		return nil, ErrInvalidUsername
+	}
+	if len(password) < minPasswordLength {
+		return nil, fmt.Errorf("%w: at least %d characters are required", ErrWeakPassword, minPasswordLength)
+	}
+
+	hash, err := hashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+	user := &domain.User{
+		ID:           uuid.New().String(),
+		Username:     username,
+		Admin:        admin,
+		CreatedAt:    time.Now(),
+		PasswordHash: hash,
+	}
+	// Checked and saved in one step, so two requests cannot both take the name
+	created, err := s.repo.Create(user)
+	if err != nil {
+		return nil, err
+	}
+	if !created {
+		return nil, ErrUsernameTaken
+	}
+	return user.Public(), nil
+}
+
+// DeleteUser removes an account and revokes its tokens. Its chats and files are kept on disk.
+func (s *UserService) DeleteUser(id string) error {
+	if id == domain.LocalUserID {
+		return ErrLocalUser
+	}
+	user, err := s.repo.FindByID(id)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return ErrUserNotFound
+	}
+	return s.repo.Delete(id)
+}
+
+// ChangePassword replaces the password of userID after checking the current one.
+func (s *UserService) ChangePassword(userID string, current string, password string) error {
+	user, err := s.storedUser(userID)
+	if err != nil {
+		return err
+	}
+	// Hashing is slow, so it happens before taking the repository lock
+	if !verifyPassword(user.PasswordHash, current) {
+		return ErrInvalidCredentials
+	}
+	if len(password) < minPasswordLength {
+		return fmt.Errorf("%w: at least %d characters are required", ErrWeakPassword, minPasswordLength)
+	}
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	return s.update(userID, func(u *domain.User) error {
+		// The password checked above must still be the current one
+		if u.PasswordHash != user.PasswordHash {
+			return ErrInvalidCredentials
+		}
+		u.PasswordHash = hash
+		return nil
+	})
+}
+
+// CreateToken issues a new API token for userID. The token is only returned once.
+func (s *UserService) CreateToken(userID string, name string) (string, *domain.PublicAPIToken, error) {
+	if userID == domain.LocalUserID {
+		return "", nil, ErrLocalUser
+	}
+	return s.addToken(userID, name)
+}
+
+// RevokeToken deletes one of userID's API tokens.
+func (s *UserService) RevokeToken(userID string, tokenID string) error {
+	if userID == domain.LocalUserID {
+		return ErrLocalUser
+	}
+	return s.update(userID, func(u *domain.User) error {
+		for i, t := range u.Tokens {
+			if t.ID == tokenID {
+				u.Tokens = append(u.Tokens[:i], u.Tokens[i+1:]...)
+				return nil
+			}
+		}
+		return ErrTokenNotFound
+	})
+}
+
+func (s *UserService) storedUser(id string) (*domain.User, error) {
+	if id == domain.LocalUserID {
+		return nil, ErrLocalUser
+	}
+	user, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	return user, nil
+}
+
+// update changes the stored account id through fn without losing concurrent changes.
+func (s *UserService) update(id string, fn func(*domain.User) error) error {
+	found, err := s.repo.Update(id, fn)
+	if err == nil && !found {
+		return ErrUserNotFound
+	}
+	return err
+}
+
+func (s *UserService) addToken(userID string, name string) (string, *domain.PublicAPIToken, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", nil, err
+	}
+	token := apiTokenPrefix + hex.EncodeToString(buf)
+
+	entry := domain.APIToken{ID: uuid.New().String(), Name: name, Hash: hashToken(token), CreatedAt: time.Now()}
+	err := s.update(userID, func(u *domain.User) error {
+		u.Tokens = append(u.Tokens, entry)
+		return nil
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	return token, &domain.PublicAPIToken{ID: entry.ID, Name: entry.Name, CreatedAt: entry.CreatedAt}, nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// hashPassword returns "pbkdf2-sha256$<iterations>$<salt>$<hash>" with base64 salt and hash.
+func hashPassword(password string) (string, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key, err := pbkdf2.Key(sha256.New, password, salt, passwordIterations, 32)
+	if err != nil {
+		return "", err
+	}
+	enc := base64.RawStdEncoding
+	return fmt.Sprintf("pbkdf2-sha256$%d$%s$%s", passwordIterations, enc.EncodeToString(salt), enc.EncodeToString(key)), nil
+}
+
+func verifyPassword(encoded string, password string) bool {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 4 || parts[0] != "pbkdf2-sha256" {
+		// Burn the same amount of work as a real check
+		pbkdf2.Key(sha256.New, password, []byte("gemiwin"), passwordIterations, 32)
+		return false
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations <= 0 {
+		return false
+	}
+	enc := base64.RawStdEncoding
+	salt, err := enc.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	want, err := enc.DecodeString(parts[3])
+	if err != nil {
+		return false
+	}
+	got, err := pbkdf2.Key(sha256.New, password, salt, iterations, len(want))
+	return err == nil && subtle.ConstantTimeCompare(got, want) == 1
+}
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"gemiwin/api/internal/persistence"
+)
+
+func TestConcurrentTokenChanges(t *testing.T) {
+	users := NewUserService(persistence.NewUserRepository(filepath.Join(t.TempDir(), "users.json")), "launch")
+	user, err := users.CreateUser("alice", "correct horse", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const n = 10
+	revoked := make([]string, n)
+	revokedIDs := make([]string, n)
+	for i := range n {
+		token, info, err := users.CreateToken(user.ID, "old")
+		if err != nil {
+			t.Fatal(err)
+		}
+		revoked[i], revokedIDs[i] = token, info.ID
+	}
+
+	// Revoke the old tokens while new ones are issued; no change may be lost
+	created := make([]string, n)
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if err := users.RevokeToken(user.ID, revokedIDs[i]); err != nil {
+				t.Errorf("RevokeToken: %v", err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			token, _, err := users.CreateToken(user.ID, "new")
+			if err != nil {
+				t.Errorf("CreateToken: %v", err)
+			}
+			created[i] = token
+		}()
+	}
+	wg.Wait()
+
+	for _, token := range revoked {
+		if u, err := users.Authenticate(token); err != nil || u != nil {
+			t.Errorf("revoked token authenticates: %v, %v", u, err)
+		}
+	}
+	for _, token := range created {
+		if u, err := users.Authenticate(token); err != nil || u == nil || u.ID != user.ID {
+			t.Errorf("new token does not authenticate: %v, %v", u, err)
+		}
+	}
+	stored, err := users.GetUser(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored.Tokens) != n {
+		t.Errorf("got %d tokens, want %d", len(stored.Tokens), n)
+	}
+}
+
+func TestRevokeUnknownToken(t *testing.T) {
+	users := NewUserService(persistence.NewUserRepository(filepath.Join(t.TempDir(), "users.json")), "launch")
+	if err := users.RevokeToken("missing", "token"); err != ErrUserNotFound {
+		t.Errorf("RevokeToken of an unknown user: error = %v, want %v", err, ErrUserNotFound)
+	}
+	user, err := users.CreateUser("bob", "correct horse", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := users.RevokeToken(user.ID, "token"); err != ErrTokenNotFound {
+		t.Errorf("RevokeToken of an unknown token: error = %v, want %v", err, ErrTokenNotFound)
+	}
+}
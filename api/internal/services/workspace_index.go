@@ -0,0 +1,317 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"gemiwin/api/internal/domain"
+	"gemiwin/api/internal/gitignore"
+)
+
+const (
+	// maxWorkspaceFiles caps how many files one workspace indexes.
+	maxWorkspaceFiles = 5000
+	// maxWorkspaceFileBytes skips larger files, which are usually generated or data.
+	maxWorkspaceFileBytes = 512 << 10
+	// maxWorkspaceBytes caps the contents stored for one workspace.
+	maxWorkspaceBytes = 32 << 20
+	// workspaceChunkLines is the number of lines per chunk.
+	workspaceChunkLines = 60
+	// workspaceContextBytes is the excerpt budget shared by the workspaces of one prompt.
+	workspaceContextBytes = 48 << 10
+	// workspaceTreeBytes caps the file tree listed for each workspace.
+	workspaceTreeBytes = 8 << 10
+)
+
+// skippedWorkspaceDirs are never indexed, whatever .gitignore says. Hidden directories are
+// skipped as well, as they usually hold tool state and credentials rather than project files.
+var skippedWorkspaceDirs = map[string]bool{"node_modules": true}
+
+// credentialNames are parts of file names that suggest credentials, and credentialStems whole
+// names without extension; such files are never indexed.
+var (
+	credentialNames = []string{"credential", "secret", "oauth", "service-account", "service_account", "id_rsa", "id_ed25519"}
+	credentialStems = map[string]bool{"token": true, "tokens": true, "auth": true, "apikey": true, "api_key": true, "passwords": true}
+)
+
+// credentialFile reports whether the file called name is hidden or looks like it holds credentials.
+func credentialFile(name string) bool {
+	if strings.HasPrefix(name, ".") {
+		return true
+	}
+	lower := strings.ToLower(name)
+	for _, part := range credentialNames {
+		if strings.Contains(lower, part) {
+			return true
+		}
+	}
+	return credentialStems[strings.TrimSuffix(lower, path.Ext(lower))]
+}
+
+// scanWorkspace lists the text files under root, applying .gitignore files and the size and count
+// limits. The second result counts text files left out because of a limit.
+func scanWorkspace(root string) ([]domain.WorkspaceFile, int, error) {
+	var matcher gitignore.Matcher
+	if data, err := os.ReadFile(filepath.Join(root, ".git", "info", "exclude")); err == nil {
+		matcher.Add("", data)
+	}
+
+	files := []domain.WorkspaceFile{}
+	skipped := 0
+	var total int64
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Unreadable subdirectories are left out rather than failing the whole index
+			if p != root && d != nil && d.IsDir() {
+				return fs.SkipDir
+			}
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if d.IsDir() {
+			if rel != "." {
+				if skippedWorkspaceDirs[d.Name()] || strings.HasPrefix(d.Name(), ".") || matcher.Match(rel, true) {
+					return fs.SkipDir
+				}
+			} else {
+				rel = ""
+			}
+			// WalkDir visits a directory before its contents, so parents' rules are added first
+			if data, err := os.ReadFile(filepath.Join(p, ".gitignore")); err == nil {
+				matcher.Add(rel, data)
+			}
+			return nil
+		}
+		// Symbolic links are not followed, so a workspace cannot reach outside its root
+		if !d.Type().IsRegular() || !textExtensions[strings.ToLower(path.Ext(rel))] || credentialFile(d.Name()) || matcher.Match(rel, false) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		if len(files) >= maxWorkspaceFiles || info.Size() > maxWorkspaceFileBytes || total+info.Size() > maxWorkspaceBytes {
+			skipped++
+			return nil
+		}
+		total += info.Size()
+		files = append(files, domain.WorkspaceFile{Path: rel, Size: info.Size(), ModTime: info.ModTime().UTC()})
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return files, skipped, nil
+}
+
+// chunkWorkspace reads files and splits them into chunks of workspaceChunkLines lines. Files that
+// are not valid UTF-8 text stay in the tree but contribute no chunks.
+func chunkWorkspace(root string, files []domain.WorkspaceFile) []domain.WorkspaceChunk {
+	chunks := []domain.WorkspaceChunk{}
+	for _, f := range files {
+		data, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(f.Path)))
+		if err != nil || !utf8.Valid(data) || bytes.IndexByte(data, 0) >= 0 {
+			continue
+		}
+		lines := strings.SplitAfter(strings.TrimSuffix(string(data), "\n"), "\n")
+		for start := 0; start < len(lines); start += workspaceChunkLines {
+			end := min(start+workspaceChunkLines, len(lines))
+			text := strings.Join(lines[start:end], "")
+			if strings.TrimSpace(text) == "" {
+				continue
+			}
+			chunks = append(chunks, domain.WorkspaceChunk{Path: f.Path, StartLine: start + 1, EndLine: end, Text: text})
+		}
+	}
+	return chunks
+}
+
+// gitHead returns the checked-out branch of the git repository at root, or the abbreviated
+// commit for a detached head, or "" if root is not a repository.
+func gitHead(root string) string {
+	data, err := os.ReadFile(filepath.Join(root, ".git", "HEAD"))
+	if err != nil {
+		return ""
+	}
+	head := strings.TrimSpace(string(data))
+	if ref, ok := strings.CutPrefix(head, "ref: "); ok {
+		return strings.TrimPrefix(ref, "refs/heads/")
+	}
+	if len(head) > 12 {
+		head = head[:12]
+	}
+	return head
+}
+
+// sameFiles reports whether two scans found the same files with the same sizes and times.
+func sameFiles(a []domain.WorkspaceFile, b []domain.WorkspaceFile) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Path != b[i].Path || a[i].Size != b[i].Size || !a[i].ModTime.Equal(b[i].ModTime) {
+			return false
+		}
+	}
+	return true
+}
+
+// stopWords are too common in questions and code to say which files are relevant.
+var stopWords = map[string]bool{
+	"the": true, "and": true, "for": true, "with": true, "this": true, "that": true, "what": true,
+	"how": true, "does": true, "are": true, "from": true, "can": true, "why": true, "where": true,
+	"when": true, "which": true, "into": true, "not": true, "you": true, "your": true, "there": true,
+	"file": true, "code": true, "use": true, "used": true, "func": true, "return": true, "var": true,
+	"int": true, "string": true, "nil": true, "null": true, "true": true, "false": true,
+}
+
+// tokenize splits text into lowercase words for ranking, breaking identifiers at camelCase and
+// snake_case boundaries and dropping short and common words.
+func tokenize(text string) []string {
+	var tokens []string
+	var word []rune
+	flush := func() {
+		if len(word) >= 3 {
+			if t := strings.ToLower(string(word)); !stopWords[t] {
+				tokens = append(tokens, t)
+			}
+		}
+		word = word[:0]
+	}
+	var prev rune
+	for _, r := range text {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if unicode.IsUpper(r) && unicode.IsLower(prev) {
+				flush()
+			}
+			word = append(word, r)
+		default:
+			flush()
+		}
+		prev = r
+	}
+	flush()
+	return tokens
+}
+
+// relevantChunks returns the chunks that best match query, within budget bytes, ordered by path
+// and line. Chunks are scored by the inverse document frequency of each query term they contain,
+// dampened by how often it occurs; terms in the file path count extra.
+func relevantChunks(chunks []domain.WorkspaceChunk, query string, budget int) []domain.WorkspaceChunk {
+	terms := map[string]bool{}
+	for _, t := range tokenize(query) {
+		terms[t] = true
+	}
+	if len(terms) == 0 || len(chunks) == 0 {
+		return nil
+	}
+
+	type scored struct {
+		index int
+		tf    map[string]int
+		path  map[string]bool
+		score float64
+	}
+	var candidates []scored
+	df := map[string]int{}
+	for i, chunk := range chunks {
+		c := scored{index: i, tf: map[string]int{}, path: map[string]bool{}}
+		for _, t := range tokenize(chunk.Text) {
+			if terms[t] {
+				c.tf[t]++
+			}
+		}
+		for _, t := range tokenize(chunk.Path) {
+			if terms[t] {
+				c.path[t] = true
+			}
+		}
+		if len(c.tf) == 0 && len(c.path) == 0 {
+			continue
+		}
+		for t := range terms {
+			if c.tf[t] > 0 || c.path[t] {
+				df[t]++
+			}
+		}
+		candidates = append(candidates, c)
+	}
+
+	n := float64(len(chunks))
+	for i := range candidates {
+		c := &candidates[i]
+		for t := range terms {
+			if df[t] == 0 {
+				continue
+			}
+			idf := math.Log(1 + n/float64(df[t]))
+			if tf := c.tf[t]; tf > 0 {
+				c.score += idf * (1 + math.Log(float64(tf)))
+			}
+			if c.path[t] {
+				c.score += 2 * idf
+			}
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].score > candidates[j].score })
+
+	var selected []domain.WorkspaceChunk
+	used := 0
+	for _, c := range candidates {
+		chunk := chunks[c.index]
+		if used+len(chunk.Text) > budget {
+			continue
+		}
+		used += len(chunk.Text)
+		selected = append(selected, chunk)
+	}
+	sort.Slice(selected, func(i, j int) bool {
+		if selected[i].Path != selected[j].Path {
+			return selected[i].Path < selected[j].Path
+		}
+		return selected[i].StartLine < selected[j].StartLine
+	})
+	return selected
+}
+
+// workspacePromptText renders the file tree of ws and the chunks relevant to query for the
+// prompt, spending at most budget bytes on excerpts.
+func workspacePromptText(ws *domain.Workspace, query string, budget int) string {
+	var b strings.Builder
+	b.WriteString("Files:\n")
+	for i, f := range ws.Files {
+		if b.Len()+len(f.Path) > workspaceTreeBytes {
+			fmt.Fprintf(&b, "[%d more files not listed]\n", len(ws.Files)-i)
+			break
+		}
+		b.WriteString(f.Path + "\n")
+	}
+	excerpts := relevantChunks(ws.Chunks, query, budget)
+	if len(excerpts) == 0 {
+		return b.String()
+	}
+	b.WriteString("\nRelevant excerpts:\n")
+	for _, chunk := range excerpts {
+		fmt.Fprintf(&b, "--- %s (lines %d-%d)\n", chunk.Path, chunk.StartLine, chunk.EndLine)
+		b.WriteString(chunk.Text)
+		if !strings.HasSuffix(chunk.Text, "\n") {
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
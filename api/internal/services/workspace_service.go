@@ -0,0 +1,274 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gemiwin/api/internal/domain"
+	"gemiwin/api/internal/persistence"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrWorkspacePathNotAllowed is returned for workspace paths outside the configured roots.
+	ErrWorkspacePathNotAllowed = errors.New("path is outside the allowed workspace roots")
+	// ErrWorkspaceNotDirectory is returned when a workspace path is missing or not a directory.
+	ErrWorkspaceNotDirectory = errors.New("path is not a directory")
+	// ErrWorkspaceNotFound is returned when a chat selects a workspace the user does not have.
+	ErrWorkspaceNotFound = errors.New("workspace not found")
+)
+
+// WorkspaceService indexes local directories so chats can use their files as context. Indexes
+// are kept up to date by WatchChanges.
+type WorkspaceService struct {
+	repo  *persistence.WorkspaceRepository
+	roots []string
+	// dataDir holds every user's chats, settings and secrets, so no workspace may include it
+	dataDir string
+	// mu serializes indexing, so a manual and a periodic reindex cannot overwrite each other
+	mu sync.Mutex
+}
+
+// NewWorkspaceService creates a WorkspaceService that only indexes directories inside roots, and
+// never the data directory dataDir or a directory containing it.
+func NewWorkspaceService(repo *persistence.WorkspaceRepository, roots []string, dataDir string) *WorkspaceService {
+	return &WorkspaceService{repo: repo, roots: roots, dataDir: dataDir}
+}
+
+// Create indexes the directory at path as a new workspace of userID. name defaults to the
+// directory's base name.
+func (s *WorkspaceService) Create(userID string, path string, name string) (*domain.Workspace, error) {
+	root, err := s.resolveRoot(path)
+	if err != nil {
+		return nil, err
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = filepath.Base(root)
+	}
+
+	ws := &domain.Workspace{
+		ID:        uuid.New().String(),
+		OwnerID:   userID,
+		Name:      name,
+		Root:      root,
+		CreatedAt: time.Now(),
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.index(ws); err != nil {
+		return nil, err
+	}
+	return withoutChunks(ws), nil
+}
+
+// resolveRoot cleans path, resolves symbolic links and checks that it is a directory inside one
+// of the allowed roots.
+func (s *WorkspaceService) resolveRoot(path string) (string, error) {
+	if !filepath.IsAbs(path) {
+		return "", fmt.Errorf("%w: %q is not an absolute path", ErrWorkspacePathNotAllowed, path)
+	}
+	root, err := filepath.EvalSymlinks(filepath.Clean(path))
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", ErrWorkspaceNotDirectory, path)
+	}
+	if info, err := os.Stat(root); err != nil || !info.IsDir() {
+		return "", fmt.Errorf("%w: %s", ErrWorkspaceNotDirectory, path)
+	}
+	if err := s.checkDataDir(root); err != nil {
+		return "", err
+	}
+	for _, allowed := range s.roots {
+		if resolved, err := filepath.EvalSymlinks(allowed); err == nil {
+			allowed = resolved
+		}
+		if within(allowed, root) {
+			return root, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %s", ErrWorkspacePathNotAllowed, path)
+}
+
+// checkDataDir refuses root if it is the data directory, contains it or sits inside it.
+func (s *WorkspaceService) checkDataDir(root string) error {
+	if s.dataDir == "" {
+		return nil
+	}
+	dataDir := s.dataDir
+	if abs, err := filepath.Abs(dataDir); err == nil {
+		dataDir = abs
+	}
+	if resolved, err := filepath.EvalSymlinks(dataDir); err == nil {
+		dataDir = resolved
+	}
+	if within(dataDir, root) || within(root, dataDir) {
+		return fmt.Errorf("%w: %s overlaps the data directory", ErrWorkspacePathNotAllowed, root)
+	}
+	return nil
+}
+
+// within reports whether p is dir or below it.
+func within(dir string, p string) bool {
+	rel, err := filepath.Rel(dir, p)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// index scans ws.Root and saves ws with its new file tree and chunks. s.mu must be held.
+func (s *WorkspaceService) index(ws *domain.Workspace) error {
+	// Workspaces created before the data directory moved, or before the check existed, are refused too
+	if err := s.checkDataDir(ws.Root); err != nil {
+		return err
+	}
+	files, skipped, err := scanWorkspace(ws.Root)
+	if err != nil {
+		return fmt.Errorf("failed to index %s: %w", ws.Root, err)
+	}
+	ws.Files = files
+	ws.Skipped = skipped
+	ws.Chunks = chunkWorkspace(ws.Root, files)
+	ws.GitHead = gitHead(ws.Root)
+	ws.IndexedAt = time.Now()
+	return s.repo.Save(ws)
+}
+
+// List returns userID's workspaces, newest first, without their chunks.
+func (s *WorkspaceService) List(userID string) ([]*domain.Workspace, error) {
+	workspaces, err := s.repo.FindAllByOwner(userID)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(workspaces, func(i, j int) bool { return workspaces[i].CreatedAt.After(workspaces[j].CreatedAt) })
+	for _, ws := range workspaces {
+		withoutChunks(ws)
+	}
+	return workspaces, nil
+}
+
+// Get returns the workspace with the given id without its chunks, or nil if userID has none.
+func (s *WorkspaceService) Get(userID string, id string) (*domain.Workspace, error) {
+	ws, err := s.repo.FindByIDForOwner(id, userID)
+	if err != nil || ws == nil {
+		return nil, err
+	}
+	return withoutChunks(ws), nil
+}
+
+// Delete removes the index of the workspace with the given id and reports whether userID had it.
+// The directory itself is left alone.
+func (s *WorkspaceService) Delete(userID string, id string) (bool, error) {
+	ws, err := s.repo.FindByIDForOwner(id, userID)
+	if err != nil || ws == nil {
+		return false, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return true, s.repo.Delete(ws.ID)
+}
+
+// Reindex scans the workspace with the given id again, or returns nil if userID has none.
+func (s *WorkspaceService) Reindex(userID string, id string) (*domain.Workspace, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ws, err := s.repo.FindByIDForOwner(id, userID)
+	if err != nil || ws == nil {
+		return nil, err
+	}
+	if err := s.index(ws); err != nil {
+		return nil, err
+	}
+	return withoutChunks(ws), nil
+}
+
+// ReindexChanged reindexes every workspace whose files or git head changed since it was last
+// indexed, and returns how many were reindexed.
+func (s *WorkspaceService) ReindexChanged() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	workspaces, err := s.repo.FindAll()
+	if err != nil {
+		return 0, err
+	}
+	reindexed := 0
+	var errs []error
+	for _, ws := range workspaces {
+		files, _, err := scanWorkspace(ws.Root)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("workspace %s: %w", ws.ID, err))
+			continue
+		}
+		if sameFiles(files, ws.Files) && gitHead(ws.Root) == ws.GitHead {
+			continue
+		}
+		if err := s.index(ws); err != nil {
+			errs = append(errs, fmt.Errorf("workspace %s: %w", ws.ID, err))
+			continue
+		}
+		reindexed++
+	}
+	return reindexed, errors.Join(errs...)
+}
+
+// WatchChanges calls ReindexChanged every interval until the process exits. Runs are skipped
+// while paused returns true, such as while encrypted data is locked.
+func (s *WorkspaceService) WatchChanges(interval time.Duration, paused func() bool) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if paused() {
+			continue
+		}
+		if n, err := s.ReindexChanged(); err != nil {
+			log.Printf("Workspace reindexing failed: %v", err)
+		} else if n > 0 {
+			log.Printf("Reindexed %d changed workspaces", n)
+		}
+	}
+}
+
+// CheckOwned returns ErrWorkspaceNotFound unless userID has every workspace in ids.
+func (s *WorkspaceService) CheckOwned(userID string, ids []string) error {
+	for _, id := range ids {
+		ws, err := s.repo.FindByIDForOwner(id, userID)
+		if err != nil {
+			return err
+		}
+		if ws == nil {
+			return fmt.Errorf("%w: %s", ErrWorkspaceNotFound, id)
+		}
+	}
+	return nil
+}
+
+// PromptContext renders the file tree of each of userID's workspaces in ids and the excerpts
+// most relevant to query. Workspaces deleted since the chat selected them are skipped.
+func (s *WorkspaceService) PromptContext(userID string, ids []string, query string) (string, error) {
+	if len(ids) == 0 {
+		return "", nil
+	}
+	budget := workspaceContextBytes / len(ids)
+	var b strings.Builder
+	for _, id := range ids {
+		ws, err := s.repo.FindByIDForOwner(id, userID)
+		if err != nil {
+			return "", err
+		}
+		if ws == nil {
+			continue
+		}
+		fmt.Fprintf(&b, " <workspace:%s>\n%s</workspace>", ws.Name, workspacePromptText(ws, query, budget))
+	}
+	return b.String(), nil
+}
+
+func withoutChunks(ws *domain.Workspace) *domain.Workspace {
+	ws.Chunks = nil
+	return ws
+}
@@ -1,6 +1,14 @@
 package server
 
 import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gemiwin/api/internal/config"
+	"gemiwin/api/internal/extract"
 	"gemiwin/api/internal/handlers"
 	"gemiwin/api/internal/middlewares"
 	"gemiwin/api/internal/persistence"
@@ -9,37 +17,281 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func New() *gin.Engine {
-	r := gin.Default()
+// PassphraseEnv holds the passphrase used to unlock encrypted data at startup.
+const PassphraseEnv = "GEMIWIN_PASSPHRASE"
 
-	r.Use(middlewares.CORSMiddleware())
+// New builds the HTTP server from the effective configuration. Every repository and the file
+// server are rooted at cfg.DataDir.
+func New(cfg *config.Config) (*gin.Engine, error) {
+	if cfg.LogLevel == config.LogLevelDebug {
+		gin.SetMode(gin.DebugMode)
+	} else {
+		gin.SetMode(gin.ReleaseMode)
+	}
 
-	// Serve uploaded files statically
-	r.Static("/files", "./data/files")
+	r := gin.New()
+	// Request logs are informational, so they are skipped at warn and error levels
+	if cfg.LogLevel == config.LogLevelDebug || cfg.LogLevel == config.LogLevelInfo {
+		r.Use(gin.Logger())
+	}
+	r.Use(gin.Recovery())
 
-	// Initialize repositories and services
-	appConfigRepo := persistence.NewAppConfigRepository()
-	botService := services.NewBotService(appConfigRepo)
-	chatRepo := persistence.NewChatRepository()
-	chatService := services.NewChatService(chatRepo, botService)
-	appConfigService := services.NewAppConfigService(appConfigRepo)
-
-	r.GET("/chats", handlers.ListChats(chatService))
-	r.POST("/chats", handlers.SendMessage(chatService))
-	r.GET("/chats/:id", handlers.GetChat(chatService))
-	r.POST("/chats/:id/messages", handlers.AddMessageToChat(chatService))
-	r.POST("/chats/files", handlers.UploadFileToChat(chatService))
-	r.POST("/chats/:id/files", handlers.UploadFileToChat(chatService))
-	r.DELETE("/chats/:id", handlers.DeleteChat(chatService))
-	r.DELETE("/chats/:id/messages/:index", handlers.DeleteMessagesFromChat(chatService))
+	r.Use(middlewares.CORSMiddleware(cfg.CORSOrigins))
+
+	// The launch token authenticates as the local administrator
+	token, err := apiToken(cfg.TokenFile)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("API token written to %s", cfg.TokenFile)
+
+	svc, err := NewServices(cfg, token)
+	if err != nil {
+		return nil, err
+	}
+	vault := svc.Vault
+
+	// Remove files left behind by deleted chats. Encrypted chats cannot be scanned while locked.
+	if vault.Locked() {
+		log.Printf("Skipping file garbage collection: data is locked")
+	} else if report, err := svc.Storage.CollectGarbage(); err != nil {
+		log.Printf("File garbage collection failed: %v", err)
+	} else if report.Deleted > 0 {
+		log.Printf("Removed %d unreferenced files (%d bytes)", report.Deleted, report.FreedBytes)
+	}
+
+	// Keep workspace indexes in step with the directories they were built from
+	if cfg.WorkspaceReindex > 0 {
+		go svc.Workspaces.WatchChanges(time.Duration(cfg.WorkspaceReindex)*time.Second, vault.Locked)
+	}
+
+	// Every request needs the launch token or a user's API token, except logging in
+	r.Use(middlewares.AuthMiddleware(svc.Users, "/auth/login"))
+	r.POST("/auth/login", handlers.Login(svc.Users))
+
+	// Unlocking and inspecting the encryption status work while the data is locked
+	r.Use(middlewares.RequireUnlocked(vault, "/unlock", "/encryption", "/config/effective"))
+	r.POST("/unlock", middlewares.RequireAdmin(), handlers.UnlockData(svc.Encryption))
+	r.GET("/encryption", handlers.GetEncryptionStatus(svc.Encryption))
+
+	// The current user's account and API tokens
+	r.GET("/me", handlers.GetCurrentUser(svc.Users))
+	r.PUT("/me/password", handlers.ChangePassword(svc.Users))
+	r.POST("/me/tokens", handlers.CreateAPIToken(svc.Users))
+	r.DELETE("/me/tokens/:id", handlers.DeleteAPIToken(svc.Users))
+
+	// Serve files attached to the current user's chats, decrypting them when needed
+	r.GET("/files/:name", handlers.GetFile(svc.Chats))
+
+	r.GET("/chats", handlers.ListChats(svc.Chats))
+	r.POST("/chats", handlers.SendMessage(svc.Chats))
+	r.GET("/chats/:id", handlers.GetChat(svc.Chats))
+	r.POST("/chats/:id/messages", handlers.AddMessageToChat(svc.Chats))
+	r.POST("/chats/files", handlers.UploadFileToChat(svc.Chats, cfg.MaxUploadBytes))
+	r.POST("/chats/:id/files", handlers.UploadFileToChat(svc.Chats, cfg.MaxUploadBytes))
+	r.POST("/chats/:id/urls", handlers.AddURLToChat(svc.Chats))
+	r.DELETE("/chats/:id", handlers.DeleteChat(svc.Chats))
+	r.DELETE("/chats/:id/messages/:index", handlers.DeleteMessagesFromChat(svc.Chats))
+	// Model comparison: several answers to one message, one of them kept in the history
+	r.POST("/chats/:id/compare", handlers.CompareModels(svc.Chats))
+	r.POST("/chats/:id/messages/:index/select", handlers.SelectAlternate(svc.Chats))
+
+	// Document library: files uploaded once and attached to messages by id
+	r.GET("/documents", handlers.ListDocuments(svc.Documents))
+	r.POST("/documents", handlers.UploadDocument(svc.Documents, cfg.MaxUploadBytes))
+	r.GET("/documents/:id", handlers.GetDocument(svc.Documents))
+	r.DELETE("/documents/:id", handlers.DeleteDocument(svc.Documents))
+
+	// Workspaces: local directories indexed as context for chats
+	r.GET("/workspaces", handlers.ListWorkspaces(svc.Workspaces))
+	r.POST("/workspaces", handlers.CreateWorkspace(svc.Workspaces))
+	r.GET("/workspaces/:id", handlers.GetWorkspace(svc.Workspaces))
+	r.DELETE("/workspaces/:id", handlers.DeleteWorkspace(svc.Workspaces))
+	r.POST("/workspaces/:id/reindex", handlers.ReindexWorkspace(svc.Workspaces))
+
+	// Models the current user can select, from the backend and their configuration
+	r.GET("/models", handlers.ListModels(svc.Models))
+
+	// Tools the bot can run while answering
+	r.GET("/tools", handlers.ListTools(svc.Tools))
+	// MCP servers from the current user's configuration and what they offer
+	r.GET("/mcp/servers", handlers.ListMCPServers(svc.MCP))
+	// The current user's chats offered to other agents as an MCP server
+	r.Any("/mcp", handlers.ServeMCP(svc.Chats))
+
+	// OpenAI-compatible gateway for scripts that speak the chat completions protocol
+	r.POST("/v1/chat/completions", handlers.ChatCompletions(svc.Chats))
+	r.GET("/v1/models", handlers.OpenAIListModels(svc.Models))
+
+	// Disk space taken by the current user's chats and their files
+	r.GET("/storage", handlers.GetStorageUsage(svc.Storage))
+	// Tokens and estimated cost of the current user's answers
+	r.GET("/usage", handlers.GetUsage(svc.Usage))
+	// The current user's budgets and how much of them is used
+	r.GET("/budgets", handlers.ListBudgets(svc.Budgets))
+
+	// Export a single chat or every chat as a zip archive
+	r.GET("/chats/:id/export", handlers.ExportChat(svc.Export))
+	r.GET("/export", handlers.ExportAllChats(svc.Export))
+
+	// Import conversations from ChatGPT, Gemini Takeout or a gemiwin export
+	r.POST("/import", handlers.ImportChats(svc.Import))
 
 	// Update chat-specific configuration
-	r.PUT("/chats/:id/config", handlers.UpdateChatConfig(chatService))
+	r.PUT("/chats/:id/config", handlers.UpdateChatConfig(svc.Chats))
+
+	// Endpoint for retrieving the current user's configuration
+	r.GET("/config", handlers.GetAppConfig(svc.AppConfig))
+	// Endpoint for updating the current user's configuration
+	r.PUT("/config", handlers.UpdateAppConfig(svc.AppConfig))
+	// Write-only endpoints for the current user's Gemini API key
+	r.PUT("/config/secrets/gemini-api-key", handlers.SetGeminiApiKey(svc.AppConfig))
+	r.DELETE("/config/secrets/gemini-api-key", handlers.DeleteGeminiApiKey(svc.AppConfig))
+
+	// Server-wide operations are reserved for administrators
+	admin := r.Group("", middlewares.RequireAdmin())
+
+	// Effective startup configuration (defaults, file, env and flags merged)
+	admin.GET("/config/effective", handlers.GetEffectiveConfig(cfg))
+
+	// Full backup and restore of the data directory
+	admin.POST("/admin/backup", handlers.CreateBackup(svc.Backup))
+	admin.POST("/admin/restore", handlers.RestoreBackup(svc.Backup))
+
+	// Delete stored files that no chat or library document references
+	admin.POST("/admin/gc", handlers.CollectGarbage(svc.Storage))
+
+	// Account management
+	admin.GET("/users", handlers.ListUsers(svc.Users))
+	admin.POST("/users", handlers.CreateUser(svc.Users))
+	admin.DELETE("/users/:id", handlers.DeleteUser(svc.Users))
+
+	return r, nil
+}
+
+// Services are the application services built on the data directory, shared by the HTTP server
+// and the MCP server mode.
+type Services struct {
+	Vault      *persistence.Vault
+	Users      *services.UserService
+	Chats      *services.ChatService
+	Documents  *services.DocumentService
+	Workspaces *services.WorkspaceService
+	Tools      *services.ToolRegistry
+	MCP        *services.MCPService
+	Models     *services.ModelRegistry
+	Storage    *services.StorageService
+	Usage      *services.UsageService
+	Budgets    *services.BudgetService
+	AppConfig  *services.AppConfigService
+	Export     *services.ExportService
+	Import     *services.ImportService
+	Backup     *services.BackupService
+	Encryption *services.EncryptionService
+}
+
+// NewServices opens the data directory of cfg and builds the services on top of it. launchToken
+// authenticates as the local administrator; an empty one disables that login.
+func NewServices(cfg *config.Config, launchToken string) (*Services, error) {
+	dataDir := cfg.DataDir
+
+	// Encrypted data stays locked until a passphrase is supplied via the env or POST /unlock
+	vault, err := persistence.NewVault(filepath.Join(dataDir, services.EncryptionMetaFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load encryption settings: %w", err)
+	}
+	if passphrase := os.Getenv(PassphraseEnv); passphrase != "" && vault.Enabled() {
+		if err := vault.Unlock(passphrase); err != nil {
+			return nil, fmt.Errorf("failed to unlock data with %s: %w", PassphraseEnv, err)
+		}
+	}
+
+	// Initialize repositories and services
+	userRepo := persistence.NewUserRepository(filepath.Join(dataDir, services.UsersFile))
+	appConfigRepo := persistence.NewAppConfigRepository(dataDir, vault)
+	secretStore := persistence.NewSecretStore(filepath.Join(dataDir, services.SecretsFile), cfg.SecretKeyFile, vault)
+	chatRepo := persistence.NewChatRepository(filepath.Join(dataDir, "chats"), vault)
+	fileRepo := persistence.NewFileRepository(filepath.Join(dataDir, "files"), vault)
+	documentRepo := persistence.NewDocumentRepository(filepath.Join(dataDir, "documents"), vault)
+	workspaceRepo := persistence.NewWorkspaceRepository(filepath.Join(dataDir, "workspaces"), vault)
+	usageRepo := persistence.NewUsageRepository(filepath.Join(dataDir, "usage"), vault)
+	var backend services.Backend = services.NewGeminiCLIBackend()
+	if cfg.Backend == config.BackendAPI {
+		backend = services.NewGeminiAPIBackend(cfg.GeminiAPIURL)
+	}
+	workspaceService := services.NewWorkspaceService(workspaceRepo, cfg.WorkspaceRoots, dataDir)
+	toolRegistry := services.NewToolRegistry()
+	for _, tool := range services.BuiltinTools(chatRepo) {
+		if err := toolRegistry.Register(tool); err != nil {
+			return nil, err
+		}
+	}
+	userService := services.NewUserService(userRepo, launchToken)
+	urlFetcher := services.NewURLFetcher(services.URLPolicy{
+		AllowHosts: cfg.URLAllowHosts,
+		DenyHosts:  cfg.URLDenyHosts,
+		MaxBytes:   cfg.MaxUploadBytes,
+		Timeout:    time.Duration(cfg.URLFetchTimeout) * time.Second,
+	})
+	mcpService := services.NewMCPService(appConfigRepo, userService, urlFetcher)
+	modelRegistry := services.NewModelRegistry(backend, secretStore, appConfigRepo, cfg.DefaultModel)
+	botService := services.NewBotService(secretStore, fileRepo, workspaceService, toolRegistry, mcpService, backend, cfg.DefaultModel)
+	storageService := services.NewStorageService(chatRepo, documentRepo, fileRepo)
+	documentService := services.NewDocumentService(documentRepo, storageService, extract.Default(), urlFetcher)
+	usageService := services.NewUsageService(usageRepo, chatRepo, modelRegistry)
+	budgetService := services.NewBudgetService(appConfigRepo, usageService, userService, urlFetcher)
+	chatService := services.NewChatService(chatRepo, fileRepo, storageService, documentService, workspaceService, mcpService, modelRegistry, budgetService, botService, cfg.DefaultModel)
+	appConfigService := services.NewAppConfigService(appConfigRepo, secretStore)
+	exportService := services.NewExportService(chatRepo, fileRepo)
+	importService := services.NewImportService(chatRepo, storageService, cfg.DefaultModel)
+	backupService := services.NewBackupService(dataDir, vault, secretStore)
+	encryptionService := services.NewEncryptionService(dataDir, vault)
 
-	// Endpoint for retrieving global configuration
-	r.GET("/config", handlers.GetAppConfig(appConfigService))
-	// Endpoint for updating global configuration
-	r.PUT("/config", handlers.UpdateAppConfig(appConfigService))
+	// Keys saved in plaintext by older versions are moved to the secret store, whose key older
+	// versions kept in the data directory, and usage recorded before the usage ledger existed is
+	// added to it, once the data can be read
+	migrate := func() {
+		if err := secretStore.MigrateKey(filepath.Join(dataDir, "secret.key")); err != nil {
+			log.Printf("Failed to move the secret key out of the data directory: %v", err)
+		}
+		users, err := userService.ListUsers()
+		if err != nil {
+			log.Printf("Failed to list users for migration: %v", err)
+			return
+		}
+		ids := make([]string, 0, len(users))
+		for _, user := range users {
+			ids = append(ids, user.ID)
+		}
+		if err := appConfigService.MigrateApiKeys(ids); err != nil {
+			log.Printf("Failed to migrate API keys: %v", err)
+		}
+		if err := usageService.Backfill(ids); err != nil {
+			log.Printf("Failed to start the usage ledger: %v", err)
+		}
+	}
+	if vault.Locked() {
+		encryptionService.OnUnlock(migrate)
+	} else {
+		migrate()
+	}
 
-	return r
+	return &Services{
+		Vault:      vault,
+		Users:      userService,
+		Chats:      chatService,
+		Documents:  documentService,
+		Workspaces: workspaceService,
+		Tools:      toolRegistry,
+		MCP:        mcpService,
+		Models:     modelRegistry,
+		Storage:    storageService,
+		Usage:      usageService,
+		Budgets:    budgetService,
+		AppConfig:  appConfigService,
+		Export:     exportService,
+		Import:     importService,
+		Backup:     backupService,
+		Encryption: encryptionService,
+	}, nil
 }
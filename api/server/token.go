@@ -0,0 +1,31 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+
+	"gemiwin/api/internal/persistence"
+)
+
+// APITokenEnv lets the launching process choose the API token instead of a random one.
+const APITokenEnv = "GEMIWIN_API_TOKEN"
+
+// apiToken returns the bearer token for this launch and writes it to tokenFile (mode 0600) so
+// local clients can pick it up.
+func apiToken(tokenFile string) (string, error) {
+	token := os.Getenv(APITokenEnv)
+	if token == "" {
+		buf := make([]byte, 32)
+		if _, err := rand.Read(buf); err != nil {
+			return "", err
+		}
+		token = hex.EncodeToString(buf)
+	}
+
+	if err := persistence.WritePrivateFile(tokenFile, []byte(token+"\n")); err != nil {
+		return "", fmt.Errorf("failed to write API token to %s: %w", tokenFile, err)
+	}
+	return token, nil
+}
@@ -6,21 +6,25 @@ import { Paperclip, X } from 'lucide-react';
 interface ChatInputProps {
   message: string;
   onChange: (value: string) => void;
-  onSend: (message: string, file: File | null) => void;
+  onSend: (message: string, files: File[]) => void;
   isLoading: boolean;
 }
 
 export const ChatInput: React.FC<ChatInputProps> = ({ message, onChange, onSend, isLoading }) => {
-  const [file, setFile] = useState<File | null>(null);
+  const [files, setFiles] = useState<File[]>([]);
   const fileInputRef = useRef<HTMLInputElement>(null);
 
   // Allowed file extensions (lower-case)
   const allowedExtensions = new Set([
     '.txt', '.md', '.markdown', '.json', '.yaml', '.yml', '.xml', '.csv',
     '.go', '.js', '.ts', '.py', '.java', '.c', '.cpp', '.rb', '.rs', '.pdf',
+    '.docx', '.xlsx', '.pptx', '.html', '.htm', '.epub', '.rtf',
+    '.png', '.jpg', '.jpeg', '.webp', '.heic', '.heif',
+    '.mp3', '.wav', '.ogg', '.flac', '.aac', '.aiff',
   ]);
 
   const MAX_FILE_SIZE = 1 * 1024 * 1024; // 1 MB
+  const MAX_FILES = 10; // Per message, matches the API
 
   const isAllowedFile = (filename: string) => {
     const idx = filename.lastIndexOf('.');
@@ -31,35 +35,39 @@ export const ChatInput: React.FC<ChatInputProps> = ({ message, onChange, onSend,
 
   const isFileSizeValid = (file: File) => file.size <= MAX_FILE_SIZE;
 
-  const handleFileChange = (e: React.ChangeEvent<HTMLInputElement>) => {
-    const selected = e.target.files?.[0] ?? null;
-    if (selected) {
-      if (!isAllowedFile(selected.name)) {
-        toast.error('Unsupported file type');
-        return;
+  // Add the valid files from a selection or drop, skipping the rest with a toast
+  const addFiles = (list: FileList | null) => {
+    const accepted: File[] = [];
+    for (const f of Array.from(list ?? [])) {
+      if (!isAllowedFile(f.name)) {
+        toast.error(`Unsupported file type: ${f.name}`);
+        continue;
       }
-      if (!isFileSizeValid(selected)) {
-        toast.error('File too large (max 1 MB)');
-        return;
+      if (!isFileSizeValid(f)) {
+        toast.error(`File too large (max 1 MB): ${f.name}`);
+        continue;
       }
+      accepted.push(f);
     }
-    setFile(selected);
+    setFiles(prev => {
+      const next = [...prev, ...accepted];
+      if (next.length > MAX_FILES) {
+        toast.error(`At most ${MAX_FILES} files per message`);
+        return next.slice(0, MAX_FILES);
+      }
+      return next;
+    });
+  };
+
+  const handleFileChange = (e: React.ChangeEvent<HTMLInputElement>) => {
+    addFiles(e.target.files);
+    // Allow selecting the same file again after removing it
+    e.target.value = '';
   };
 
   const handleDrop = (e: DragEvent<HTMLDivElement>) => {
     e.preventDefault();
-    const droppedFile = e.dataTransfer.files?.[0];
-    if (droppedFile) {
-      if (!isAllowedFile(droppedFile.name)) {
-        toast.error('Unsupported file type');
-        return;
-      }
-      if (!isFileSizeValid(droppedFile)) {
-        toast.error('File too large (max 1 MB)');
-        return;
-      }
-      setFile(droppedFile);
-    }
+    addFiles(e.dataTransfer.files);
   };
 
   const handleDragOver = (e: DragEvent<HTMLDivElement>) => {
@@ -69,19 +77,19 @@ export const ChatInput: React.FC<ChatInputProps> = ({ message, onChange, onSend,
   const handleSend = () => {
     if (isLoading) return;
 
-    // When uploading a file, a message is now mandatory
-    if (file && message.trim() === '') {
+    // When uploading files, a message is now mandatory
+    if (files.length > 0 && message.trim() === '') {
       return;
     }
 
-    onSend(message, file);
-    // Do not clear file immediately; wait until request finishes (isLoading becomes false)
+    onSend(message, files);
+    // Do not clear files immediately; wait until request finishes (isLoading becomes false)
   };
 
-  // Clear file once loading finishes (after successful upload)
+  // Clear files once loading finishes (after successful upload)
   useEffect(() => {
     if (!isLoading) {
-      setFile(null);
+      setFiles([]);
     }
   }, [isLoading]);
 
@@ -157,9 +165,10 @@ export const ChatInput: React.FC<ChatInputProps> = ({ message, onChange, onSend,
         <input
           ref={fileInputRef}
           type="file"
+          multiple
           className="hidden"
           onChange={handleFileChange}
-          accept=".txt,.md,.markdown,.json,.yaml,.yml,.xml,.csv,.go,.js,.ts,.py,.java,.c,.cpp,.rb,.rs,.pdf"
+          accept=".txt,.md,.markdown,.json,.yaml,.yml,.xml,.csv,.go,.js,.ts,.py,.java,.c,.cpp,.rb,.rs,.pdf,.docx,.xlsx,.pptx,.html,.htm,.epub,.rtf,.png,.jpg,.jpeg,.webp,.heic,.heif,.mp3,.wav,.ogg,.flac,.aac,.aiff"
         />
 
         <Button
@@ -167,20 +176,24 @@ export const ChatInput: React.FC<ChatInputProps> = ({ message, onChange, onSend,
           type="button"
           onClick={() => fileInputRef.current?.click()}
           disabled={isLoading}
-          aria-label="Attach file"
+          aria-label="Attach files"
         >
           <Paperclip className="w-4 h-4" />
         </Button>
 
-        <Button onClick={handleSend} disabled={isLoading || (file ? message.trim() === '' : false)}>Send</Button>
+        <Button onClick={handleSend} disabled={isLoading || (files.length > 0 ? message.trim() === '' : false)}>Send</Button>
       </div>
 
-      {file && (
-        <div className="mt-2 flex items-center gap-2 text-sm text-muted-foreground">
-          <span className="truncate max-w-xs">{file.name}</span>
-          <button onClick={() => setFile(null)} aria-label="Remove file">
-            <X className="w-4 h-4" />
-          </button>
+      {files.length > 0 && (
+        <div className="mt-2 flex flex-wrap gap-x-4 gap-y-1 text-sm text-muted-foreground">
+          {files.map((f, index) => (
+            <div key={`${f.name}-${index}`} className="flex items-center gap-2">
+              <span className="truncate max-w-xs">{f.name}</span>
+              <button onClick={() => setFiles(prev => prev.filter((_, i) => i !== index))} aria-label={`Remove ${f.name}`}>
+                <X className="w-4 h-4" />
+              </button>
+            </div>
+          ))}
         </div>
       )}
     </footer>
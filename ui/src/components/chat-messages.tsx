@@ -2,7 +2,7 @@ import React from 'react';
 import { isMarkdown } from '@/lib/utils';
 import { MarkdownRenderer } from './markdown-renderer';
 import * as api from '@/services/api';
-import { Copy, Trash2, FileText, X } from 'lucide-react';
+import { Copy, Trash2, FileText, X, AlertTriangle, Wrench } from 'lucide-react';
 import type { ModelName } from '@/services/api';
 import toast from 'react-hot-toast';
 
@@ -18,13 +18,15 @@ interface ChatMessagesProps {
   messages: api.Message[];
   isLoading: boolean;
   loadingText: string;
+  models: api.Model[];
   currentModel: ModelName;
   onModelChange: (model: ModelName) => void;
   onDeleteMessage?: (index: number) => void;
+  onSelectAlternate?: (index: number, alternate: number) => void;
   onCancel?: () => void;
 }
 
-export const ChatMessages: React.FC<ChatMessagesProps> = ({ messages, isLoading, loadingText, currentModel, onModelChange, onDeleteMessage, onCancel }) => {
+export const ChatMessages: React.FC<ChatMessagesProps> = ({ messages, isLoading, loadingText, models, currentModel, onModelChange, onDeleteMessage, onSelectAlternate, onCancel }) => {
   const handleCopy = (text: string) => {
     if (navigator?.clipboard) {
       navigator.clipboard.writeText(text).then(() => toast('Copied')).catch(() => {});
@@ -44,18 +46,25 @@ export const ChatMessages: React.FC<ChatMessagesProps> = ({ messages, isLoading,
     }
   };
 
-  // Handle opening or downloading documents
-  const handleDocumentClick = (doc: api.Document) => {
-    const url = `${api.API_URL}${doc.url}`;
-    const isPDF = /\.pdf$/i.test(doc.name);
+  // Describe incomplete text extraction so the user knows what the bot could not read
+  const extractionNote = (doc: api.Document): string | null => {
+    if (!doc.extraction || doc.extraction.status === 'ok') return null;
+    // Images and audio have no text; the API backend sends them to the model as they are
+    if (doc.mime_type?.startsWith('image/') || doc.mime_type?.startsWith('audio/')) return null;
+    const warnings = doc.extraction.warnings?.length ? `: ${doc.extraction.warnings.join('; ')}` : '';
+    return `Text extraction ${doc.extraction.status}${warnings}`;
+  };
 
-    if (isPDF) {
-      const viewer = `https://mozilla.github.io/pdf.js/web/viewer.html?file=${encodeURIComponent(
-        url,
-      )}`;
-      window.open(viewer, '_blank', 'noopener,noreferrer');
-    } else {
+  // Handle opening documents. Files need the API token, so they are fetched and opened as blobs
+  // (PDFs render in the built-in viewer).
+  const handleDocumentClick = async (doc: api.Document) => {
+    try {
+      const blob = await api.fetchDocument(doc);
+      const url = URL.createObjectURL(blob);
       window.open(url, '_blank', 'noopener,noreferrer');
+      setTimeout(() => URL.revokeObjectURL(url), 60_000);
+    } catch (err) {
+      toast.error(err instanceof Error ? err.message : 'Failed to open document');
     }
   };
 
@@ -70,45 +79,114 @@ export const ChatMessages: React.FC<ChatMessagesProps> = ({ messages, isLoading,
         <select
           id="model-select"
           value={currentModel}
-          onChange={(e) => onModelChange(e.target.value as ModelName)}
+          onChange={(e) => onModelChange(e.target.value)}
           className="border border-border rounded px-2 py-1 text-sm bg-background"
         >
-          <option value="gemini-2.5-flash">gemini-2.5-flash</option>
-          <option value="gemini-2.5-pro">gemini-2.5-pro</option>
+          {/* Keep a chat's model selectable even if it is no longer listed */}
+          {currentModel && !models.some((m) => m.id === currentModel) && (
+            <option value={currentModel}>{currentModel}</option>
+          )}
+          {models.map((m) => (
+            <option key={m.id} value={m.id} title={m.description}>
+              {m.display_name ? `${m.display_name} (${m.id})` : m.id}
+            </option>
+          ))}
         </select>
       </div>
       {messages.map((msg, index) => (
         <div key={index} className={`flex my-2 message-appear max-w-full ${msg.role === 'user' ? 'justify-end' : 'justify-start'}`}>
           <div className={`relative p-2 rounded-lg flex flex-col max-w-[80%] min-w-0 overflow-auto ${msg.role === 'user' ? 'bg-primary text-primary-foreground' : 'bg-secondary'}`}>
             <div>
-              {msg.type === 'doc' && msg.document ? (
+              {msg.type === 'doc' && msg.documents?.length ? (
                 <div className="flex flex-col gap-1">
-                  {msg.document.url ? (
-                    <button
-                      type="button"
-                      onClick={() => handleDocumentClick(msg.document!)}
-                      className="flex items-center gap-2 hover:underline text-left"
-                    >
-                      <FileText className="w-4 h-4" />
-                      {msg.document.name}
-                    </button>
-                  ) : (
-                    <div className="flex items-center gap-2 text-muted-foreground">
-                      <FileText className="w-4 h-4" />
-                      {msg.document.name}
-                    </div>
+                  {msg.documents.map((doc, docIndex) =>
+                    doc.url ? (
+                      <button
+                        key={docIndex}
+                        type="button"
+                        onClick={() => handleDocumentClick(doc)}
+                        className="flex items-center gap-2 hover:underline text-left"
+                        title={doc.source_url}
+                      >
+                        <FileText className="w-4 h-4" />
+                        {doc.name}
+                        {extractionNote(doc) && (
+                          <span title={extractionNote(doc)!} aria-label={extractionNote(doc)!}>
+                            <AlertTriangle className="w-4 h-4 text-yellow-500" />
+                          </span>
+                        )}
+                      </button>
+                    ) : (
+                      <div key={docIndex} className="flex items-center gap-2 text-muted-foreground">
+                        <FileText className="w-4 h-4" />
+                        {doc.name}
+                      </div>
+                    ),
                   )}
                   {msg.content && (
                     <span className="whitespace-pre-wrap">{msg.content}</span>
                   )}
                 </div>
+              ) : msg.type === 'tool' && msg.tool_call ? (
+                <details className="text-sm text-muted-foreground">
+                  <summary className="flex items-center gap-2 cursor-pointer">
+                    <Wrench className="w-4 h-4" />
+                    {msg.tool_call.error ? `${msg.tool_call.name} failed` : `Ran ${msg.tool_call.name}`}
+                  </summary>
+                  <pre className="whitespace-pre-wrap break-all mt-1">
+                    {JSON.stringify(msg.tool_call.arguments ?? {})}
+                    {'\n→ '}
+                    {msg.tool_call.error ?? msg.tool_call.result}
+                  </pre>
+                </details>
+              ) : msg.alternates?.length ? (
+                <div className="flex flex-col gap-2">
+                  {msg.alternates.map((alt, altIndex) => (
+                    <details key={altIndex} open={alt.selected} className={`rounded border p-2 ${alt.selected ? 'border-primary' : 'border-border'}`}>
+                      <summary className="flex items-center gap-2 cursor-pointer text-sm">
+                        <span className="font-medium">{alt.model}</span>
+                        {alt.usage && (
+                          <span className="text-xs opacity-60">
+                            {alt.usage.estimated ? '~' : ''}{alt.usage.input_tokens} → {alt.usage.output_tokens} tokens · {(alt.usage.latency_ms / 1000).toFixed(1)}s
+                          </span>
+                        )}
+                        {alt.selected ? (
+                          <span className="text-xs opacity-60">kept</span>
+                        ) : !alt.error && onSelectAlternate && (
+                          <button
+                            type="button"
+                            onClick={(e) => { e.preventDefault(); onSelectAlternate(index, altIndex); }}
+                            className="text-xs underline opacity-60 hover:opacity-100"
+                          >
+                            Keep this answer
+                          </button>
+                        )}
+                      </summary>
+                      {alt.error ? (
+                        <span className="text-sm text-red-500">{alt.error}</span>
+                      ) : alt.content && isMarkdown(alt.content) ? (
+                        <MarkdownRenderer content={alt.content} className="markdown" />
+                      ) : (
+                        alt.content
+                      )}
+                    </details>
+                  ))}
+                </div>
               ) : msg.role === 'bot' && isMarkdown(msg.content) ? (
                 <MarkdownRenderer content={msg.content} className="markdown" />
               ) : (
                 msg.content
               )}
             </div>
-            <div className="flex gap-1 self-end mt-1">
+            <div className="flex gap-1 self-end mt-1 items-center">
+              {msg.usage && !msg.alternates?.length && (
+                <span
+                  className="text-xs opacity-60 mr-1"
+                  title={`${msg.usage.model}${msg.usage.downgraded_from ? ` instead of ${msg.usage.downgraded_from} (budget used up)` : ''}${msg.usage.estimated ? ' (estimated)' : ''}`}
+                >
+                  {msg.usage.estimated ? '~' : ''}{msg.usage.input_tokens} → {msg.usage.output_tokens} tokens · {(msg.usage.latency_ms / 1000).toFixed(1)}s
+                </span>
+              )}
               {(msg.type === 'text' || (msg.type === 'doc' && msg.content)) && (
                 <button
                   onClick={() => handleCopy(msg.content)}
@@ -26,10 +26,12 @@ export const ChatSidebar: React.FC<ChatSidebarProps> = ({
   const { theme, setTheme } = useTheme();
 
   const [isConfigOpen, setIsConfigOpen] = React.useState(false);
-  const [config, setConfig] = React.useState<api.AppConfig>({ gemini_api_key: '' });
+  const [config, setConfig] = React.useState<api.AppConfig | null>(null);
+  const [apiKey, setApiKey] = React.useState('');
   const [isSaving, setIsSaving] = React.useState(false);
 
   const openConfigModal = async () => {
+    setApiKey('');
     try {
       const cfg = await api.getAppConfig();
       setConfig(cfg);
@@ -43,7 +45,10 @@ export const ChatSidebar: React.FC<ChatSidebarProps> = ({
   const handleSave = async () => {
     setIsSaving(true);
     try {
-      await api.updateAppConfig(config);
+      // The key is write-only: an empty field keeps the stored one
+      if (apiKey.trim()) {
+        setConfig(await api.setGeminiApiKey(apiKey.trim()));
+      }
       setIsConfigOpen(false);
     } catch (error) {
       console.error('Failed to save config', error);
@@ -53,6 +58,18 @@ export const ChatSidebar: React.FC<ChatSidebarProps> = ({
     }
   };
 
+  const handleRemoveKey = async () => {
+    setIsSaving(true);
+    try {
+      setConfig(await api.deleteGeminiApiKey());
+    } catch (error) {
+      console.error('Failed to remove API key', error);
+      toast.error(`Failed to remove API key: ${(error instanceof Error ? error.message : String(error))}`);
+    } finally {
+      setIsSaving(false);
+    }
+  };
+
   return (
     <>
     <aside className="w-64 flex flex-col border-r border-border">
@@ -125,11 +142,19 @@ export const ChatSidebar: React.FC<ChatSidebarProps> = ({
           <div className="space-y-4">
             <div>
               <label className="block text-sm font-medium mb-1" htmlFor="gemini-api-key">Gemini API Key</label>
-              <Input
-                id="gemini-api-key"
-                value={config.gemini_api_key}
-                onChange={(e) => setConfig({ ...config, gemini_api_key: e.target.value })}
-              />
+              <div className="flex gap-2">
+                <Input
+                  id="gemini-api-key"
+                  type="password"
+                  autoComplete="off"
+                  placeholder={config?.has_key ? `Saved (${config.gemini_api_key_masked})` : 'Not set'}
+                  value={apiKey}
+                  onChange={(e) => setApiKey(e.target.value)}
+                />
+                {config?.has_key && (
+                  <Button variant="ghost" onClick={handleRemoveKey} disabled={isSaving}>Remove</Button>
+                )}
+              </div>
             </div>
           </div>
           <div className="mt-6 flex justify-end gap-2">
@@ -13,7 +13,9 @@ export const Layout: React.FC = () => {
   const [isLoading, setIsLoading] = useState(false);
   const [loadingText, setLoadingText] = useState('Thinking.');
   const [loadingChatId, setLoadingChatId] = useState<string | null>(null);
-  const [selectedModel, setSelectedModel] = useState<api.ModelName>('gemini-2.5-flash');
+  const [models, setModels] = useState<api.Model[]>([]);
+  // Empty until the models are loaded; the server then picks its default model
+  const [selectedModel, setSelectedModel] = useState<api.ModelName>('');
 
   useEffect(() => {
     const fetchChats = async () => {
@@ -28,6 +30,19 @@ export const Layout: React.FC = () => {
     fetchChats();
   }, []);
 
+  useEffect(() => {
+    api.listModels()
+      .then((available) => {
+        setModels(available);
+        const preferred = available.find((m) => m.default) ?? available[0];
+        if (preferred) setSelectedModel((current) => current || preferred.id);
+      })
+      .catch((error) => {
+        console.error('Failed to list models', error);
+        toast.error(`Failed to load models: ${(error instanceof Error ? error.message : String(error))}`);
+      });
+  }, []);
+
   // Rotate loading messages and dots while waiting for the bot response
   useEffect(() => {
     if (!isLoading) return;
@@ -97,6 +112,17 @@ export const Layout: React.FC = () => {
     }
   };
 
+  const handleSelectAlternate = async (messageIndex: number, alternate: number) => {
+    if (!currentChat) return;
+    try {
+      const updatedChat = await api.selectAlternate(currentChat.id, messageIndex, alternate);
+      setCurrentChat(updatedChat);
+      setChats(prev => prev.map(chat => (chat.id === updatedChat.id ? updatedChat : chat)));
+    } catch (error) {
+      toast.error(`Failed to select answer: ${(error instanceof Error ? error.message : String(error))}`);
+    }
+  };
+
   // Ref to manage request cancellation
   const abortRef = useRef<AbortController | null>(null);
 
@@ -154,13 +180,13 @@ export const Layout: React.FC = () => {
     }
   };
 
-  const handleSendMessage = async (content: string, file: File | null) => {
+  const handleSendMessage = async (content: string, files: File[]) => {
     if (isLoading) return;
 
-    // Require content when uploading a file
-    if (file && content.trim() === '') return;
+    // Require content when uploading files
+    if (files.length > 0 && content.trim() === '') return;
 
-    if (!file && content.trim() === '') return;
+    if (files.length === 0 && content.trim() === '') return;
 
     setMessage('');
     setIsLoading(true);
@@ -177,18 +203,18 @@ export const Layout: React.FC = () => {
     pendingRef.current = {
       chatId: currentChat?.id ?? null,
       message: content,
-      type: file ? 'doc' : 'text',
+      type: files.length > 0 ? 'doc' : 'text',
       createdTempChat: !currentChat,
     };
 
     try {
-      // If a file is being uploaded, a message is mandatory
-      if (file) {
+      // If files are being uploaded, a message is mandatory
+      if (files.length > 0) {
         const tempDocMsg: api.Message = {
           role: 'user',
           type: 'doc',
           content: content,
-          document: { id: 'temp', name: file.name, url: '' },
+          documents: files.map((f, i) => ({ id: `temp-${i}`, name: f.name, url: '' })),
           timestamp: new Date().toISOString(),
         };
 
@@ -197,14 +223,14 @@ export const Layout: React.FC = () => {
           setCurrentChat(prev => ({ ...prev!, messages: [...prev!.messages, tempDocMsg] }));
           setChats(prev => prev.map(c => (c.id === currentChat.id ? { ...c, messages: [...c.messages, tempDocMsg] } : c)));
 
-          const updatedChat = await api.uploadFileToChat(currentChat.id, file, content, controller.signal);
+          const updatedChat = await api.uploadFileToChat(currentChat.id, files, content, controller.signal);
           setCurrentChat(updatedChat);
           setChats(prev => prev.map(c => (c.id === updatedChat.id ? updatedChat : c)));
         } else {
           // Create temp chat with optimistic message
           const tempChat: api.Chat = {
             id: `temp-${Date.now()}`,
-            name: content || file.name,
+            name: content || files[0].name,
             created_at: new Date().toISOString(),
             messages: [tempDocMsg],
             config: { model: selectedModel },
@@ -215,20 +241,19 @@ export const Layout: React.FC = () => {
           // Associate loading with this temporary chat
           setLoadingChatId(tempChat.id);
 
-          // Upload file and create new chat
-          const newChat = await api.uploadFileNewChat(file, content, { model: selectedModel }, controller.signal);
+          // Upload files and create new chat
+          const newChat = await api.uploadFileNewChat(files, content, { model: selectedModel }, controller.signal);
           setCurrentChat(newChat);
           setChats(prev => prev.map(c => (c.id === tempChat.id ? newChat : c)));
           setLoadingChatId(newChat.id);
         }
       } else if (content.trim()) {
-        // Text-only flow (no file)
+        // Text-only flow (no files)
         if (currentChat) {
           const newUserMessage: api.Message = {
             role: 'user',
             type: 'text',
             content: content,
-            document: null,
             timestamp: new Date().toISOString(),
           };
           setCurrentChat(prev => ({ ...prev!, messages: [...prev!.messages, newUserMessage] }));
@@ -247,8 +272,7 @@ export const Layout: React.FC = () => {
                 role: 'user',
                 type: 'text',
                 content: content,
-                document: null,
-                timestamp: new Date().toISOString(),
+                    timestamp: new Date().toISOString(),
               },
             ],
             config: { model: selectedModel },
@@ -323,9 +347,11 @@ export const Layout: React.FC = () => {
           messages={currentChat?.messages ?? []}
           isLoading={isLoading && currentChat?.id === loadingChatId}
           loadingText={loadingText}
+          models={models}
           currentModel={currentChat?.config.model ?? selectedModel}
           onModelChange={handleModelChange}
           onDeleteMessage={handleDeleteMessage}
+          onSelectAlternate={handleSelectAlternate}
           onCancel={handleCancelRequest}
         />
         <ChatInput
@@ -1,5 +1,6 @@
 import { app, BrowserWindow, session, screen } from 'electron';
 import { spawn, type ChildProcess } from 'child_process';
+import { randomBytes } from 'crypto';
 import net from 'net';
 import path from 'path';
 // This allows TypeScript to pick up the magic constants that's auto-generated by Forge's Webpack
@@ -31,7 +32,13 @@ const findAvailablePort = (): Promise<number> =>
  * Launches geminiapi executable and resolves once the process has spawned successfully.
  * If the executable is missing or fails to start, it still resolves so the app can continue.
  */
-const startGeminiApi = (port: number): Promise<void> =>
+// Origin of the renderer, allowed by the API's CORS policy (file:// when packaged)
+const rendererOrigin = (): string => {
+  const url = new URL(MAIN_WINDOW_WEBPACK_ENTRY);
+  return url.protocol === 'file:' ? 'file://' : url.origin;
+};
+
+const startGeminiApi = (port: number, token: string): Promise<void> =>
   new Promise((resolve) => {
     try {
       let exePath: string | null = null;
@@ -49,10 +56,16 @@ const startGeminiApi = (port: number): Promise<void> =>
         return resolve();
       }
 
-      geminiApiProcess = spawn(exePath, ['-port', port.toString()], {
-        detached: false,
-        stdio: 'ignore',
-      });
+      geminiApiProcess = spawn(
+        exePath,
+        ['-port', port.toString(), '-bind', '127.0.0.1', '-cors-origins', rendererOrigin()],
+        {
+          detached: false,
+          stdio: 'ignore',
+          // The token is passed through the environment so it does not show up in process listings
+          env: { ...process.env, GEMIWIN_API_TOKEN: token },
+        },
+      );
 
       // Resolve when the process successfully starts
       geminiApiProcess.once('spawn', () => resolve());
@@ -123,17 +136,21 @@ const createWindow = (): void => {
 app.on('ready', async () => {
   const apiPort = await findAvailablePort();
 
-  // Expose the chosen port to the renderer via env var (read in preload)
+  // Per-launch token required by the API on every request
+  const apiToken = randomBytes(32).toString('hex');
+
+  // Expose the chosen port and token to the renderer via env vars (read in preload)
   process.env.API_PORT = apiPort.toString();
+  process.env.API_TOKEN = apiToken;
 
-  await startGeminiApi(apiPort);
+  await startGeminiApi(apiPort, apiToken);
 
   session.defaultSession.webRequest.onHeadersReceived((details, callback) => {
     callback({
       responseHeaders: {
         ...details.responseHeaders,
         'Content-Security-Policy': [
-          `default-src 'self' 'unsafe-inline' 'unsafe-eval' data: blob:; connect-src 'self' http://localhost:${apiPort}`,
+          `default-src 'self' 'unsafe-inline' 'unsafe-eval' data: blob:; connect-src 'self' http://127.0.0.1:${apiPort}`,
         ],
       },
     });
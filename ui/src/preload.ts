@@ -5,8 +5,9 @@ contextBridge.exposeInMainWorld('electronAPI', {
   openExternal: (url: string) => shell.openExternal(url),
 });
 
-// Expose the dynamically chosen Gemini API port & URL
+// Expose the dynamically chosen Gemini API port, URL and per-launch token
 contextBridge.exposeInMainWorld('geminiAPI', {
   port: Number(process.env.API_PORT ?? 8080),
-  url: `http://localhost:${process.env.API_PORT ?? 8080}`,
+  url: `http://127.0.0.1:${process.env.API_PORT ?? 8080}`,
+  token: process.env.API_TOKEN ?? '',
 });
@@ -3,13 +3,24 @@ declare global {
     geminiAPI?: {
       url: string;
       port: number;
+      token: string;
     };
   }
 }
 
 export const API_URL: string = (typeof window !== 'undefined' && window.geminiAPI?.url)
   ? window.geminiAPI.url
-  : 'http://localhost:8080';
+  : 'http://127.0.0.1:8080';
+
+// Bearer token the API requires on every request
+const API_TOKEN: string = (typeof window !== 'undefined' && window.geminiAPI?.token) || '';
+
+// fetch wrapper that targets the API and adds the Authorization header
+const apiFetch = (path: string, init: RequestInit = {}): Promise<Response> => {
+  const headers = new Headers(init.headers);
+  if (API_TOKEN) headers.set('Authorization', `Bearer ${API_TOKEN}`);
+  return apiFetch(`${path}`, { ...init, headers });
+};
 
 // Extract error message returned by API if present { error: string }
 const getApiError = async (response: Response, fallback: string): Promise<string> => {
@@ -24,22 +35,121 @@ const getApiError = async (response: Response, fallback: string): Promise<string
   return fallback;
 };
 
+// Outcome of extracting the text of an uploaded document
+export interface Extraction {
+  status: 'ok' | 'partial' | 'empty' | 'failed' | 'unsupported';
+  warnings?: string[];
+}
+
 // Document metadata for messages of type 'doc'
 export interface Document {
   id: string;
   name: string;
   url: string;
+  mime_type?: string;
+  extraction?: Extraction;
+  // Library entry the document was attached from
+  library_id?: string;
+  // Address the document was fetched from, for documents added by URL
+  source_url?: string;
+}
+
+// A file in the user's document library, reusable across chats
+export interface LibraryDocument {
+  id: string;
+  name: string;
+  file_id: string;
+  url: string;
+  mime_type?: string;
+  size: number;
+  // Only returned by getDocument, not by listDocuments
+  content?: string;
+  extraction?: Extraction;
+  source_url?: string;
+  created_at: string;
+}
+
+// A local folder indexed as context for chats
+export interface Workspace {
+  id: string;
+  name: string;
+  root: string;
+  git_head?: string;
+  created_at: string;
+  indexed_at: string;
+  files: { path: string; size: number; mod_time: string }[];
+  skipped: number;
 }
 
 export interface Message {
   role: 'user' | 'bot';
-  // Distinguish between plain text and document messages
-  type: 'text' | 'doc';
+  // Distinguish between plain text, document messages and tools the bot ran
+  type: 'text' | 'doc' | 'tool';
   content: string;
-  document?: Document | null;
+  documents?: Document[];
+  tool_call?: ToolCall;
+  // Set on bot answers: tokens and time it took to generate them
+  usage?: Usage;
+  // Every answer of a model comparison; content and usage repeat the selected one
+  alternates?: Alternate[];
   timestamp: string;
 }
 
+export interface Alternate {
+  model: string;
+  content?: string;
+  tool_calls?: ToolCall[];
+  usage?: Usage;
+  // Why the model gave no answer
+  error?: string;
+  selected?: boolean;
+}
+
+export interface Usage {
+  model: string;
+  // The chat's model, when a used-up budget had a cheaper model answer
+  downgraded_from?: string;
+  input_tokens: number;
+  output_tokens: number;
+  // Counts derived from the text length because the backend reported none
+  estimated?: boolean;
+  latency_ms: number;
+  // Cost at the model's price when the answer was generated; unset for unpriced models
+  cost_usd?: number;
+}
+
+export interface UsageTotals {
+  messages: number;
+  input_tokens: number;
+  output_tokens: number;
+  estimated_messages: number;
+  latency_ms: number;
+  cost_usd: number;
+  unpriced_messages: number;
+}
+
+export interface UsageReport {
+  total: UsageTotals;
+  by_chat: (UsageTotals & { chat_id: string; name: string })[];
+  by_day: (UsageTotals & { date: string })[];
+  by_model: (UsageTotals & { model: string })[];
+}
+
+// A tool the bot ran before answering
+export interface ToolCall {
+  name: string;
+  arguments?: Record<string, unknown>;
+  result?: string;
+  error?: string;
+}
+
+// A tool the bot can run, as listed by /tools
+export interface Tool {
+  name: string;
+  description: string;
+  parameters: Record<string, unknown>;
+}
+
 export interface Chat {
   id: string;
   name: string;
@@ -48,16 +158,90 @@ export interface Chat {
   config: ChatConfig;
 }
 
-// Allowed model names
-export type ModelName = 'gemini-2.5-flash' | 'gemini-2.5-pro';
+// Model id, one of those listed by /models
+export type ModelName = string;
+
+// A model from the registry; properties that are not known are omitted
+export interface Model {
+  id: ModelName;
+  display_name?: string;
+  description?: string;
+  context_window?: number;
+  output_tokens?: number;
+  input_modalities?: string[];
+  output_modalities?: string[];
+  // US dollars per million tokens
+  pricing?: { input_per_million: number; output_per_million: number };
+  readonly source?: 'builtin' | 'backend' | 'user';
+  readonly default?: boolean;
+}
 
 export interface ChatConfig {
   model: ModelName;
+  // Workspaces added as context; an empty list clears them, omitting it keeps them
+  workspace_ids?: string[];
+  // MCP servers whose tools the bot can run; same clearing rules as workspace_ids
+  mcp_servers?: string[];
+  // Set from the system messages of chats stored through /v1/chat/completions
+  readonly instructions?: string;
+}
+
+// A Model Context Protocol server: a local command (administrators only) or an HTTP endpoint
+export interface MCPServer {
+  name: string;
+  command?: string;
+  args?: string[];
+  env?: Record<string, string>;
+  url?: string;
+  headers?: Record<string, string>;
 }
 
-// Global configuration object returned by /config
+export interface MCPServerStatus {
+  name: string;
+  transport: 'stdio' | 'http';
+  connected: boolean;
+  error?: string;
+  server?: string;
+  tools: {
+    name: string;
+    description?: string;
+    inputSchema: Record<string, unknown>;
+    annotations?: { title?: string; readOnlyHint?: boolean };
+  }[];
+  resources: { uri: string; name: string; description?: string; mimeType?: string }[];
+}
+
+// Global configuration object returned by /config. The API key itself is never returned.
 export interface AppConfig {
-  gemini_api_key: string;
+  has_key: boolean;
+  gemini_api_key_masked?: string;
+  key_storage: string;
+  mcp_servers?: MCPServer[];
+  // Models added to the registry or overriding what the backend reports
+  models?: Model[];
+  // Saved together: sending budgets replaces the webhook too
+  budgets?: Budget[];
+  budget_webhook_url?: string;
+}
+
+// Daily or monthly limit on tokens or estimated cost, for all chats or one
+export interface Budget {
+  period: 'daily' | 'monthly';
+  max_tokens?: number;
+  max_cost_usd?: number;
+  chat_id?: string;
+  // Cheaper model that answers once the budget is used up; without it messages are refused
+  downgrade_to?: ModelName;
+  // Fractions of the budget reported to the webhook, e.g. [0.8]
+  alert_at?: number[];
+}
+
+export interface BudgetStatus extends Budget {
+  used_tokens: number;
+  used_cost_usd: number;
+  period_start: string;
+  resets_at: string;
+  exceeded: boolean;
 }
 
 export const createChat = async (
@@ -68,7 +252,7 @@ export const createChat = async (
   const body: Record<string, any> = { content };
   if (config) body.config = config;
 
-  const response = await fetch(`${API_URL}/chats`, {
+  const response = await apiFetch(`/chats`, {
     method: 'POST',
     headers: {
       'Content-Type': 'application/json',
@@ -83,7 +267,7 @@ export const createChat = async (
 };
 
 export const listChats = async (): Promise<Chat[]> => {
-  const response = await fetch(`${API_URL}/chats`);
+  const response = await apiFetch(`/chats`);
   if (!response.ok) {
     throw new Error(await getApiError(response, 'Failed to list chats'));
   }
@@ -91,7 +275,7 @@ export const listChats = async (): Promise<Chat[]> => {
 };
 
 export const getChat = async (id: string): Promise<Chat> => {
-  const response = await fetch(`${API_URL}/chats/${id}`);
+  const response = await apiFetch(`/chats/${id}`);
   if (!response.ok) {
     throw new Error(await getApiError(response, 'Failed to get chat'));
   }
@@ -103,7 +287,7 @@ export const addMessageToChat = async (
   content: string,
   signal?: AbortSignal,
 ): Promise<Chat> => {
-  const response = await fetch(`${API_URL}/chats/${id}/messages`, {
+  const response = await apiFetch(`/chats/${id}/messages`, {
     method: 'POST',
     headers: {
       'Content-Type': 'application/json',
@@ -118,7 +302,7 @@ export const addMessageToChat = async (
 };
 
 export const deleteChat = async (id: string): Promise<void> => {
-  const response = await fetch(`${API_URL}/chats/${id}`, {
+  const response = await apiFetch(`/chats/${id}`, {
     method: 'DELETE',
   });
   if (!response.ok) {
@@ -127,7 +311,7 @@ export const deleteChat = async (id: string): Promise<void> => {
 };
 
 export const deleteMessagesFromChat = async (id: string, index: number): Promise<Chat> => {
-  const response = await fetch(`${API_URL}/chats/${id}/messages/${index}`, {
+  const response = await apiFetch(`/chats/${id}/messages/${index}`, {
     method: 'DELETE',
   });
   if (!response.ok) {
@@ -136,18 +320,48 @@ export const deleteMessagesFromChat = async (id: string, index: number): Promise
   return response.json();
 };
 
-// Upload a file to an existing chat (or pass a placeholder id like 'new' to create a chat)
+// Ask 2-4 models the same message at once; the answers come back as alternates of one bot message
+export const compareModels = async (id: string, content: string, models: ModelName[], signal?: AbortSignal): Promise<Chat> => {
+  const response = await apiFetch(`/chats/${id}/compare`, {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({ content, models }),
+    signal,
+  });
+  if (!response.ok) {
+    throw new Error(await getApiError(response, 'Failed to compare models'));
+  }
+  return response.json();
+};
+
+// Keep another answer of a comparison as the message's content
+export const selectAlternate = async (id: string, index: number, alternate: number): Promise<Chat> => {
+  const response = await apiFetch(`/chats/${id}/messages/${index}/select`, {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({ alternate }),
+  });
+  if (!response.ok) {
+    throw new Error(await getApiError(response, 'Failed to select answer'));
+  }
+  return response.json();
+};
+
+// Upload files to an existing chat (or pass a placeholder id like 'new' to create a chat).
+// All files, the library documents in documentIds and the content become a single message.
 export const uploadFileToChat = async (
   id: string,
-  file: File,
+  files: File[],
   content: string,
   signal?: AbortSignal,
+  documentIds: string[] = [],
 ): Promise<Chat> => {
   const formData = new FormData();
-  formData.append('file', file);
+  files.forEach(file => formData.append('file', file));
+  documentIds.forEach(documentId => formData.append('document_id', documentId));
   formData.append('content', content);
 
-  const response = await fetch(`${API_URL}/chats/${id}/files`, {
+  const response = await apiFetch(`/chats/${id}/files`, {
     method: 'POST',
     body: formData,
     signal,
@@ -158,22 +372,24 @@ export const uploadFileToChat = async (
   return response.json();
 };
 
-// Upload a file and create a new chat when no ID exists.
+// Upload files and create a new chat when no ID exists.
 export const uploadFileNewChat = async (
-  file: File,
+  files: File[],
   content: string,
   config?: ChatConfig,
   signal?: AbortSignal,
+  documentIds: string[] = [],
 ): Promise<Chat> => {
   const formData = new FormData();
-  formData.append('file', file);
+  files.forEach(file => formData.append('file', file));
+  documentIds.forEach(documentId => formData.append('document_id', documentId));
   formData.append('content', content);
 
   if (config) {
     formData.append('config', JSON.stringify(config));
   }
 
-  const response = await fetch(`${API_URL}/chats/files`, {
+  const response = await apiFetch(`/chats/files`, {
     method: 'POST',
     body: formData,
     signal,
@@ -184,12 +400,33 @@ export const uploadFileNewChat = async (
   return response.json();
 };
 
+// Fetch a web page or file into the chat as a document message
+export const addUrlToChat = async (
+  id: string,
+  url: string,
+  content: string,
+  signal?: AbortSignal,
+): Promise<Chat> => {
+  const response = await apiFetch(`/chats/${id}/urls`, {
+    method: 'POST',
+    headers: {
+      'Content-Type': 'application/json',
+    },
+    body: JSON.stringify({ url, content }),
+    signal,
+  });
+  if (!response.ok) {
+    throw new Error(await getApiError(response, 'Failed to add URL'));
+  }
+  return response.json();
+};
+
 // Update chat-specific configuration (e.g., model)
 export const updateChatConfig = async (
   id: string,
   config: ChatConfig,
 ): Promise<Chat> => {
-  const response = await fetch(`${API_URL}/chats/${id}/config`, {
+  const response = await apiFetch(`/chats/${id}/config`, {
     method: 'PUT',
     headers: {
       'Content-Type': 'application/json',
@@ -204,24 +441,212 @@ export const updateChatConfig = async (
 
 // Retrieve the global application configuration
 export const getAppConfig = async (): Promise<AppConfig> => {
-  const response = await fetch(`${API_URL}/config`);
+  const response = await apiFetch(`/config`);
   if (!response.ok) {
     throw new Error(await getApiError(response, 'Failed to load configuration'));
   }
   return response.json();
 };
 
-// Update or create the global application configuration
-export const updateAppConfig = async (config: AppConfig): Promise<AppConfig> => {
-  const response = await fetch(`${API_URL}/config`, {
+// Replace the list of MCP servers; an empty list removes them all
+export const updateMcpServers = async (mcp_servers: MCPServer[]): Promise<AppConfig> => {
+  const response = await apiFetch(`/config`, {
     method: 'PUT',
     headers: {
       'Content-Type': 'application/json',
     },
-    body: JSON.stringify(config),
+    body: JSON.stringify({ mcp_servers }),
   });
   if (!response.ok) {
-    throw new Error(await getApiError(response, 'Failed to update configuration'));
+    throw new Error(await getApiError(response, 'Failed to save MCP servers'));
+  }
+  return response.json();
+};
+
+// Connect to the configured MCP servers and list what each offers
+export const listMcpServers = async (): Promise<MCPServerStatus[]> => {
+  const response = await apiFetch(`/mcp/servers`);
+  if (!response.ok) {
+    throw new Error(await getApiError(response, 'Failed to load MCP servers'));
   }
   return response.json();
-}; 
\ No newline at end of file
+};
+
+// List the models chats can use, sorted by id
+export const listModels = async (): Promise<Model[]> => {
+  const response = await apiFetch(`/models`);
+  if (!response.ok) {
+    throw new Error(await getApiError(response, 'Failed to load models'));
+  }
+  return response.json();
+};
+
+// Add up tokens and cost; from and to are YYYY-MM-DD dates, both included
+export const getUsage = async (filter: { from?: string; to?: string; chat_id?: string } = {}): Promise<UsageReport> => {
+  const params = new URLSearchParams();
+  Object.entries(filter).forEach(([key, value]) => {
+    if (value) params.set(key, value);
+  });
+  const query = params.toString();
+  const response = await apiFetch(`/usage${query ? `?${query}` : ''}`);
+  if (!response.ok) {
+    throw new Error(await getApiError(response, 'Failed to load usage'));
+  }
+  return response.json();
+};
+
+// Replace the budgets and their webhook; an empty list removes every budget
+export const updateBudgets = async (budgets: Budget[], budget_webhook_url?: string): Promise<AppConfig> => {
+  const response = await apiFetch(`/config`, {
+    method: 'PUT',
+    headers: {
+      'Content-Type': 'application/json',
+    },
+    body: JSON.stringify({ budgets, budget_webhook_url }),
+  });
+  if (!response.ok) {
+    throw new Error(await getApiError(response, 'Failed to save budgets'));
+  }
+  return response.json();
+};
+
+// List the budgets with how much of each is used in the current period
+export const listBudgets = async (): Promise<BudgetStatus[]> => {
+  const response = await apiFetch(`/budgets`);
+  if (!response.ok) {
+    throw new Error(await getApiError(response, 'Failed to load budgets'));
+  }
+  return response.json();
+};
+
+// Store the Gemini API key (write-only)
+export const setGeminiApiKey = async (value: string): Promise<AppConfig> => {
+  const response = await apiFetch(`/config/secrets/gemini-api-key`, {
+    method: 'PUT',
+    headers: {
+      'Content-Type': 'application/json',
+    },
+    body: JSON.stringify({ value }),
+  });
+  if (!response.ok) {
+    throw new Error(await getApiError(response, 'Failed to save API key'));
+  }
+  return response.json();
+};
+
+// Remove the stored Gemini API key
+export const deleteGeminiApiKey = async (): Promise<AppConfig> => {
+  const response = await apiFetch(`/config/secrets/gemini-api-key`, {
+    method: 'DELETE',
+  });
+  if (!response.ok) {
+    throw new Error(await getApiError(response, 'Failed to remove API key'));
+  }
+  return response.json();
+}; 
+
+// Download an uploaded document; files require the API token so they cannot be linked directly
+export const fetchDocument = async (doc: Document): Promise<Blob> => {
+  const response = await apiFetch(doc.url);
+  if (!response.ok) {
+    throw new Error(await getApiError(response, 'Failed to download document'));
+  }
+  return response.blob();
+};
+
+// List the document library (without extracted text)
+export const listDocuments = async (): Promise<LibraryDocument[]> => {
+  const response = await apiFetch(`/documents`);
+  if (!response.ok) {
+    throw new Error(await getApiError(response, 'Failed to load documents'));
+  }
+  return response.json();
+};
+
+// Get a library document including its extracted text
+export const getDocument = async (id: string): Promise<LibraryDocument> => {
+  const response = await apiFetch(`/documents/${id}`);
+  if (!response.ok) {
+    throw new Error(await getApiError(response, 'Failed to load document'));
+  }
+  return response.json();
+};
+
+// Add a file to the document library without sending it to a chat
+export const uploadDocument = async (file: File): Promise<LibraryDocument> => {
+  const formData = new FormData();
+  formData.append('file', file);
+
+  const response = await apiFetch(`/documents`, {
+    method: 'POST',
+    body: formData,
+  });
+  if (!response.ok) {
+    throw new Error(await getApiError(response, 'Failed to upload document'));
+  }
+  return response.json();
+};
+
+// Remove a document from the library; messages it was attached to keep it
+export const deleteDocument = async (id: string): Promise<void> => {
+  const response = await apiFetch(`/documents/${id}`, {
+    method: 'DELETE',
+  });
+  if (!response.ok) {
+    throw new Error(await getApiError(response, 'Failed to delete document'));
+  }
+};
+
+// List the user's workspaces with their file trees
+export const listWorkspaces = async (): Promise<Workspace[]> => {
+  const response = await apiFetch(`/workspaces`);
+  if (!response.ok) {
+    throw new Error(await getApiError(response, 'Failed to load workspaces'));
+  }
+  return response.json();
+};
+
+// Index a folder on the server's machine as a workspace
+export const createWorkspace = async (path: string, name?: string): Promise<Workspace> => {
+  const response = await apiFetch(`/workspaces`, {
+    method: 'POST',
+    headers: {
+      'Content-Type': 'application/json',
+    },
+    body: JSON.stringify({ path, name }),
+  });
+  if (!response.ok) {
+    throw new Error(await getApiError(response, 'Failed to create workspace'));
+  }
+  return response.json();
+};
+
+// Scan a workspace's folder again right away
+export const reindexWorkspace = async (id: string): Promise<Workspace> => {
+  const response = await apiFetch(`/workspaces/${id}/reindex`, {
+    method: 'POST',
+  });
+  if (!response.ok) {
+    throw new Error(await getApiError(response, 'Failed to reindex workspace'));
+  }
+  return response.json();
+};
+
+// Remove a workspace's index; the folder itself is left alone
+export const deleteWorkspace = async (id: string): Promise<void> => {
+  const response = await apiFetch(`/workspaces/${id}`, {
+    method: 'DELETE',
+  });
+  if (!response.ok) {
+    throw new Error(await getApiError(response, 'Failed to delete workspace'));
+  }
+};
+
+// List the tools the bot can run while answering
+export const listTools = async (): Promise<Tool[]> => {
+  const response = await apiFetch(`/tools`);
+  if (!response.ok) {
+    throw new Error(await getApiError(response, 'Failed to load tools'));
+  }
+  return response.json();
+};